@@ -0,0 +1,421 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/soyersoyer/rightana/internal/config"
+	"github.com/soyersoyer/rightana/internal/service"
+)
+
+// output prints v as JSON or calls the text printer
+func output(asJSON bool, v interface{}, text func()) {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	text()
+}
+
+func formatCreated(created int64) string {
+	return time.Unix(0, created).Format("2006-01-02 15:04")
+}
+
+// ListUsers lists the users
+func ListUsers(asJSON bool) {
+	inits()
+	users, err := service.GetUsers()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, users, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tEMAIL\tADMIN\tCOLLECTIONS\tLIMIT\tCREATED")
+		for _, u := range users {
+			limit := "-"
+			if u.LimitCollections {
+				limit = fmt.Sprint(u.CollectionLimit)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%v\t%d\t%s\t%s\n", u.Name, u.Email, u.IsAdmin, u.CollectionCount, limit, formatCreated(u.Created))
+		}
+		w.Flush()
+	})
+}
+
+// ListCollections lists the collections with the owners, teammates and shard sizes
+func ListCollections(asJSON bool) {
+	inits()
+	collections, err := service.GetCollectionDetails()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, collections, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tOWNER\tTEAMMATES\tSHARDS\tSIZE\tCREATED")
+		for _, c := range collections {
+			teammates := []string{}
+			for _, t := range c.Teammates {
+				teammates = append(teammates, t.Email)
+			}
+			size := 0
+			for _, s := range c.Shards {
+				size += s.Size
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", c.ID, c.Name, c.OwnerName,
+				strings.Join(teammates, ","), len(c.Shards), size, formatCreated(c.Created))
+		}
+		w.Flush()
+	})
+}
+
+// DeleteUser deletes a user with the collections
+func DeleteUser(name string, asJSON bool) {
+	inits()
+	if err := service.DeleteUserByAdmin(name); err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, name, func() { log.Println("user deleted:", name) })
+}
+
+// DeleteCollection deletes a collection
+func DeleteCollection(collectionID string, asJSON bool) {
+	inits()
+	collection, err := service.GetCollection(collectionID)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := service.DeleteCollection(collection); err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, collectionID, func() { log.Println("collection deleted:", collectionID) })
+}
+
+// SetAdmin promotes or demotes a user
+func SetAdmin(name string, isAdmin bool, asJSON bool) {
+	inits()
+	if err := service.SetAdmin(name, isAdmin); err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, name, func() { log.Println("user", name, "admin:", isAdmin) })
+}
+
+// SetCollectionLimit sets a user's collection limit
+func SetCollectionLimit(name string, limit int, asJSON bool) {
+	inits()
+	if err := service.SetCollectionLimit(name, limit); err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, name, func() { log.Println("user", name, "collection limit:", limit) })
+}
+
+// AddTeammate adds a teammate to a collection
+func AddTeammate(collectionID string, email string, asJSON bool) {
+	inits()
+	collection, err := service.GetCollection(collectionID)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := service.AddTeammate(collection, service.TeammateT{Email: email}); err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, email, func() { log.Println("teammate added:", email) })
+}
+
+// RemoveTeammate removes a teammate from a collection
+func RemoveTeammate(collectionID string, email string, asJSON bool) {
+	inits()
+	collection, err := service.GetCollection(collectionID)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := service.RemoveTeammate(collection, email); err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, email, func() { log.Println("teammate removed:", email) })
+}
+
+// TransferCollection transfers a collection to another user
+func TransferCollection(collectionID string, user string, removePreviousOwner bool, asJSON bool) {
+	inits()
+	collection, err := service.GetCollection(collectionID)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	previousOwner, err := service.GetUserByID(collection.OwnerID)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := service.TransferCollection(collection, user, !removePreviousOwner); err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, collectionID, func() {
+		log.Println("collection", collectionID, "transferred to", user)
+		if removePreviousOwner {
+			log.Println("the previous owner", previousOwner.Name, "has no access to it anymore")
+		} else {
+			log.Println("the previous owner", previousOwner.Name, "is kept as a teammate")
+		}
+	})
+}
+
+func parseDate(date string) time.Time {
+	if date == "" {
+		return time.Time{}
+	}
+	layout := "2006-01-02"
+	if len(date) > len(layout) {
+		layout = "2006-01-02 15:04"
+	}
+	t, err := time.ParseInLocation(layout, date, time.Local)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return t
+}
+
+// EraseSessions deletes the matching sessions of a collection and records it in the audit trail
+func EraseSessions(collectionID string, sessionKey string, ip string, ipRange string, from string, to string, dryRun bool, asJSON bool) {
+	inits()
+	collection, err := service.GetCollection(collectionID)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	input := &service.ErasureInputT{
+		SessionKey: sessionKey,
+		IP:         ip,
+		IPRange:    ipRange,
+		From:       parseDate(from),
+		To:         parseDate(to),
+	}
+	erasure, err := service.EraseSessions(collection, input, "cli", dryRun)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, erasure, func() {
+		verb := "deleted"
+		if dryRun {
+			verb = "would delete"
+		}
+		fmt.Printf("%v %d sessions and %d pageviews from %v\n", verb, erasure.Sessions, erasure.Pageviews, collectionID)
+	})
+}
+
+// ListErasures lists the erasure audit trail of a collection
+func ListErasures(collectionID string, asJSON bool) {
+	inits()
+	collection, err := service.GetCollection(collectionID)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	erasures, err := service.GetErasures(collection)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	formatBound := func(t int64) string {
+		if t == 0 {
+			return "-"
+		}
+		return formatCreated(t)
+	}
+	output(asJSON, erasures, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCREATED\tACTOR\tCRITERION\tFROM\tTO\tSESSIONS\tPAGEVIEWS")
+		for _, e := range erasures {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\n", e.ID, formatCreated(e.Created), e.Actor,
+				e.Criterion, formatBound(e.From), formatBound(e.To), e.Sessions, e.Pageviews)
+		}
+		w.Flush()
+	})
+}
+
+// PurgeCollection deletes the sessions started in a time window and compacts the shards
+func PurgeCollection(collectionID string, from string, to string, asJSON bool) {
+	inits()
+	collection, err := service.GetCollection(collectionID)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	input := &service.PurgeInputT{From: parseDate(from), To: parseDate(to)}
+	report, err := service.PurgeCollection(collection, input)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if report.Sessions > 0 || report.Pageviews > 0 {
+		if report.Shards, err = service.CompactCollection(collection, input); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	output(asJSON, report, func() {
+		fmt.Printf("deleted %d sessions and %d pageviews from %v\n", report.Sessions, report.Pageviews, collectionID)
+		for _, s := range report.Shards {
+			fmt.Printf("shard %v compacted: %d -> %d bytes\n", s.ID, s.Before, s.After)
+		}
+	})
+}
+
+// ReshardCollection rewrites a collection's shards in a new granularity
+func ReshardCollection(collectionID string, granularity string, asJSON bool) {
+	inits()
+	collection, err := service.GetCollection(collectionID)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	report, err := service.ReshardCollection(collection, granularity)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, report, func() {
+		fmt.Printf("collection %v resharded: %v -> %v, %d shards -> %d shards, %d records\n",
+			report.CollectionID, report.From, report.To, report.OldShards, report.NewShards, report.Records)
+	})
+}
+
+// VerifyBackup checks a backup and reports the collections' contents
+func VerifyBackup(dir string, asJSON bool) {
+	report, err := service.VerifyBackup(dir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, report, func() {
+		fmt.Println("users:", report.Users)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tSHARDS\tSESSIONS\tFROM\tTO\tERROR")
+		for _, c := range report.Collections {
+			errs := []string{}
+			if c.Error != "" {
+				errs = append(errs, c.Error)
+			}
+			for _, s := range c.Shards {
+				if s.Error != "" {
+					errs = append(errs, s.ID+": "+s.Error)
+				}
+			}
+			from, to := "-", "-"
+			if c.Sessions > 0 {
+				from, to = formatCreated(c.From.UnixNano()), formatCreated(c.To.UnixNano())
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n", c.ID, c.Name, len(c.Shards), c.Sessions, from, to, strings.Join(errs, "; "))
+		}
+		w.Flush()
+		for _, e := range report.Errors {
+			fmt.Println("error:", e)
+		}
+	})
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+// RestoreBackup restores a whole backup, a collection or a shard into the data dir
+func RestoreBackup(dir string, collectionID string, shardID string, force bool) {
+	config.ReadConfig()
+	err := service.RestoreBackup(dir, service.RestoreOptionsT{
+		CollectionID: collectionID,
+		ShardID:      shardID,
+		Force:        force,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Println("backup restored from", dir)
+}
+
+// ExportCollection writes the collection's export into the file, "-" means stdout
+func ExportCollection(collectionID string, file string) {
+	inits()
+	collection, err := service.GetCollection(collectionID)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	w := os.Stdout
+	if file != "-" {
+		w, err = os.Create(file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if err := service.ExportCollection(w, collection); err != nil {
+		log.Fatalln(err)
+	}
+	if err := w.Close(); err != nil {
+		log.Fatalln(err)
+	}
+	if file != "-" {
+		log.Println("collection", collectionID, "exported to", file)
+	}
+}
+
+// ImportCollection imports an export file into a new or an existing collection, "-" means stdin
+func ImportCollection(file string, owner string, collectionID string, name string, asJSON bool) {
+	inits()
+	user, err := service.GetUserByName(owner)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	r := os.Stdin
+	if file != "-" {
+		r, err = os.Open(file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer r.Close()
+	}
+	report, err := service.ImportCollection(r, user, collectionID, name)
+	if err != nil && report == nil {
+		log.Fatalln(err)
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	output(asJSON, report, func() {
+		fmt.Printf("collection: %s\nsessions: %d\npageviews: %d\nupdated sessions: %d\nremapped pageviews: %d\nskipped records: %d\n",
+			report.CollectionID, report.Sessions, report.Pageviews, report.Updated, report.RemappedPageviews, report.Skipped)
+	})
+	if err != nil {
+		log.Fatalln("the import failed after the reported records:", err)
+	}
+}
+
+// RebuildIndexes rebuilds the user and collection indexes
+func RebuildIndexes() {
+	inits()
+	if err := service.RebuildIndexes(); err != nil {
+		log.Fatalln(err)
+	}
+	log.Println("indexes rebuilt")
+}
+
+// Migrate runs or lists the pending database migrations
+func Migrate(dryRun bool, asJSON bool) {
+	initDB()
+	report, err := service.Migrate(dryRun)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	output(asJSON, report, func() {
+		fmt.Printf("schema version: %d -> %d\n", report.From, report.To)
+		for _, m := range report.Migrations {
+			fmt.Println("migration:", m)
+		}
+		for _, s := range report.Shards {
+			fmt.Println("shard:", s)
+		}
+		switch {
+		case !report.Pending():
+			fmt.Println("the database is up to date")
+		case dryRun:
+			fmt.Println("dry run, nothing changed")
+		default:
+			fmt.Println("migrated, the backup is in", report.Backup)
+		}
+	})
+}
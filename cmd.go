@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"syscall"
 	"time"
 
@@ -19,9 +22,22 @@ import (
 	"github.com/soyersoyer/rightana/internal/service"
 )
 
+// inits opens the databases for the commands, they refuse to run on an outdated schema
 func inits() {
+	initDB()
+	if err := service.CheckSchema(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// initDB reads the config and opens the databases without checking their schema
+func initDB() {
 	config.ReadConfig()
 	geoip.OpenDB(config.ActualConfig.GeoIPCityFile, config.ActualConfig.GeoIPASNFile)
+	db.SetMaxOpenShards(config.ActualConfig.MaxOpenShards)
+	if err := db.SetStorage(config.ActualConfig.Storage); err != nil {
+		log.Fatalln(err)
+	}
 	db.InitDatabase(config.ActualConfig.DataDir)
 	mail.Configure(mail.SMTPConfig{
 		Hostname: config.ActualConfig.SMTPHostname,
@@ -36,7 +52,10 @@ func inits() {
 
 // Serve starts a http server
 func Serve() {
-	inits()
+	initDB()
+	if err := service.MigrateOnStart(); err != nil {
+		log.Fatalln(err)
+	}
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
@@ -47,9 +66,44 @@ func Serve() {
 
 	api.Wire(r)
 
+	service.StartAlertScheduler(time.Duration(config.ActualConfig.AlertCheckMinutes) * time.Minute)
+	service.StartDigestScheduler(time.Duration(config.ActualConfig.DigestCheckMinutes) * time.Minute)
+	service.StartWebhookScheduler(time.Duration(config.ActualConfig.WebhookSeconds) * time.Second)
+	service.StartBackupScheduler()
+	service.StartCompactionQueue()
+
+	srv := &http.Server{Addr: config.ActualConfig.Listening, Handler: r}
+	stopped := make(chan struct{})
+	var shutdownErr error
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		log.Println("received", <-sig, "shutting down")
+		timeout := time.Duration(config.ActualConfig.ShutdownSeconds) * time.Second
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if shutdownErr = srv.Shutdown(ctx); shutdownErr != nil {
+			log.Println("HTTP server shutdown:", shutdownErr)
+		}
+		if err := service.StopSchedulers(ctx); err != nil {
+			log.Println("the running background jobs are left behind:", err)
+		}
+		close(stopped)
+	}()
+
 	log.Println("HTTP server will now start listening on", config.ActualConfig.Listening)
-	err := http.ListenAndServe(config.ActualConfig.Listening, r)
-	log.Fatal(err)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-stopped
+	if shutdownErr != nil {
+		// the running requests may still use the databases, bolt recovers the files at the next start
+		log.Fatalln("the running requests are left behind, the databases aren't closed")
+	}
+	if err := db.Close(); err != nil {
+		log.Fatalln(err)
+	}
+	log.Println("shutdown complete")
 }
 
 // Seed seed a collection with count session
@@ -103,9 +157,9 @@ func ChangePassword(name string) {
 }
 
 // CreateCollection creates a collection with name and the owner's username
-func CreateCollection(collectionID string, name string, user string) {
+func CreateCollection(collectionID string, name string, granularity string, user string) {
 	inits()
-	collection, err := service.CreateCollectionByID(collectionID, name, user)
+	collection, err := service.CreateCollectionByID(collectionID, name, granularity, user)
 	if err != nil {
 		log.Fatalln(err)
 	}
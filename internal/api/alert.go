@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/soyersoyer/rightana/internal/service"
+)
+
+func getAlertsE(w http.ResponseWriter, r *http.Request) error {
+	collection := getCollectionCtx(r.Context())
+	alerts, err := service.GetAlerts(collection)
+	if err != nil {
+		return err
+	}
+	return respond(w, alerts)
+}
+
+var getAlerts = handleError(getAlertsE)
+
+func createAlertE(w http.ResponseWriter, r *http.Request) error {
+	var input service.AlertT
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return service.ErrInputDecodeFailed.Wrap(err)
+	}
+
+	collection := getCollectionCtx(r.Context())
+	alert, err := service.CreateAlert(collection, &input)
+	if err != nil {
+		return err
+	}
+	return respond(w, alert)
+}
+
+var createAlert = handleError(createAlertE)
+
+func updateAlertE(w http.ResponseWriter, r *http.Request) error {
+	var input service.AlertT
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return service.ErrInputDecodeFailed.Wrap(err)
+	}
+
+	collection := getCollectionCtx(r.Context())
+	alertID := chi.URLParam(r, "alertID")
+	alert, err := service.UpdateAlert(collection, alertID, &input)
+	if err != nil {
+		return err
+	}
+	return respond(w, alert)
+}
+
+var updateAlert = handleError(updateAlertE)
+
+func deleteAlertE(w http.ResponseWriter, r *http.Request) error {
+	collection := getCollectionCtx(r.Context())
+	alertID := chi.URLParam(r, "alertID")
+	if err := service.DeleteAlert(collection, alertID); err != nil {
+		return err
+	}
+	return respond(w, alertID)
+}
+
+var deleteAlert = handleError(deleteAlertE)
+
+func getAlertEventsE(w http.ResponseWriter, r *http.Request) error {
+	collection := getCollectionCtx(r.Context())
+	alertID := chi.URLParam(r, "alertID")
+	events, err := service.GetAlertEvents(collection, alertID)
+	if err != nil {
+		return err
+	}
+	return respond(w, events)
+}
+
+var getAlertEvents = handleError(getAlertEventsE)
@@ -62,6 +62,9 @@ func Wire(r *chi.Mux) {
 	} else {
 		r.Get("/*", webAppFileServer("frontend/dist"))
 	}
+	r.Get("/metrics", getMetrics)
+	r.Get("/healthz", getHealthz)
+	r.Get("/readyz", getReadyz)
 	r.Route("/api", func(r chi.Router) {
 		cors := cors.New(cors.Options{
 			AllowedOrigins:   []string{"*"},
@@ -74,9 +77,12 @@ func Wire(r *chi.Mux) {
 		r.Use(cors.Handler)
 		r.Get("/config", getPublicConfig)
 		r.With(loggedOnlyHandler).With(adminAccessHandler).Get("/backups", getBackups)
-		r.Get("/backups/{backupID}/run", runBackup)
+		r.With(loggedOnlyHandler).With(adminAccessHandler).Get("/backups/download", downloadBackup)
+		r.With(loggedOnlyHandler).With(adminAccessHandler).Get("/backups/{backupID}/run", runBackup)
+		r.With(loggedOnlyHandler).With(adminAccessHandler).Get("/backups/{backupID}/runs", getBackupRuns)
 		r.Post("/sessions", createSession)
 		r.Post("/sessions/update", updateSession)
+		r.Post("/sessions/heartbeat", sessionHeartbeat)
 		r.Post("/pageviews", createPageview)
 		r.Post("/authtokens", createToken)
 		r.Delete("/authtokens/{token}", deleteToken)
@@ -101,6 +107,8 @@ func userRouter() http.Handler {
 			r.Patch("/password", updateUserPassword)
 			r.Post("/delete", deleteUser)
 			r.Post("/send-verify-email", sendVerifyEmail)
+			r.Get("/digest", getDigest)
+			r.Put("/digest", updateDigest)
 		})
 	})
 
@@ -112,17 +120,37 @@ func collectionRouter() http.Handler {
 	r.Use(loggedOnlyHandler)
 	r.Post("/", getCollectionSummaries)
 	r.With(collectionCreateAccessHandler).Post("/create-new", createCollection)
+	r.With(collectionCreateAccessHandler).Post("/import", importCollection)
 	r.Route("/{collectionName}", func(r chi.Router) {
 		r.Use(collectionBaseHandler)
 		r.Use(collectionReadAccessHandler)
 		r.With(collectionWriteAccessHandler).Get("/", getCollection)
 		r.With(collectionWriteAccessHandler).Put("/", updateCollection)
 		r.With(collectionWriteAccessHandler).Delete("/", deleteCollection)
+		r.With(collectionWriteAccessHandler).Get("/session-settings", getSessionSettings)
+		r.With(collectionWriteAccessHandler).Put("/session-settings", updateSessionSettings)
+		r.With(collectionWriteAccessHandler).Get("/property-limits", getPropertyLimits)
+		r.With(collectionWriteAccessHandler).Put("/property-limits", updatePropertyLimits)
 		r.With(collectionWriteAccessHandler).Get("/shards", getCollectionShards)
 		r.With(collectionWriteAccessHandler).Delete("/shards/{shardID}", deleteCollectionShard)
+		r.With(collectionWriteAccessHandler).Get("/backup", downloadCollectionBackup)
+		r.With(collectionWriteAccessHandler).Get("/export", exportCollection)
+		r.With(collectionWriteAccessHandler).Post("/import", importIntoCollection)
+		r.With(collectionWriteAccessHandler).Get("/erasures", getErasures)
+		r.With(collectionWriteAccessHandler).Post("/erasures", eraseSessions)
+		r.With(collectionWriteAccessHandler).Post("/purge", purgeCollection)
 		r.With(collectionWriteAccessHandler).Get("/teammates", getTeammates)
 		r.With(collectionWriteAccessHandler).Post("/teammates", addTeammate)
 		r.With(collectionWriteAccessHandler).Delete("/teammates/{email}", removeTeammate)
+		r.With(collectionWriteAccessHandler).Get("/alerts", getAlerts)
+		r.With(collectionWriteAccessHandler).Post("/alerts", createAlert)
+		r.With(collectionWriteAccessHandler).Put("/alerts/{alertID}", updateAlert)
+		r.With(collectionWriteAccessHandler).Delete("/alerts/{alertID}", deleteAlert)
+		r.With(collectionWriteAccessHandler).Get("/alerts/{alertID}/events", getAlertEvents)
+		r.With(collectionWriteAccessHandler).Get("/webhooks", getWebhooks)
+		r.With(collectionWriteAccessHandler).Post("/webhooks", createWebhook)
+		r.With(collectionWriteAccessHandler).Put("/webhooks/{webhookID}", updateWebhook)
+		r.With(collectionWriteAccessHandler).Delete("/webhooks/{webhookID}", deleteWebhook)
 		r.Post("/data", getCollectionData)
 		r.Post("/stat", getCollectionStatData)
 		r.Post("/sessions", getSessions)
@@ -1,15 +1,20 @@
 package api
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -18,6 +23,7 @@ import (
 
 	"github.com/soyersoyer/rightana/internal/config"
 	"github.com/soyersoyer/rightana/internal/db"
+	"github.com/soyersoyer/rightana/internal/mail"
 	"github.com/soyersoyer/rightana/internal/service"
 )
 
@@ -52,10 +58,10 @@ var (
 		DeviceType:       deviceType,
 		Referrer:         "http://irl.hu",
 	}
-	sessionUpdateData = updateSessionInputT{
+	sessionUpdateData = sessionHeartbeatInputT{
 		SessionKey: "",
 	}
-	badSessionUpdateData = updateSessionInputT{
+	badSessionUpdateData = sessionHeartbeatInputT{
 		SessionKey: "badsessionkey",
 	}
 	pageViewData = createPageviewInputT{
@@ -411,6 +417,134 @@ func TestCollectionLimit(t *testing.T) {
 	testBody(t, w, "Collection limit exceeded (0)\n")
 }
 
+func TestSetAdmin(t *testing.T) {
+	newUser := service.CreateUserT{
+		Name:     "setadmin",
+		Email:    "setadmin@irl.hu",
+		Password: "setadminlong",
+	}
+	testRegisterUserSuccess(t, newUser)
+
+	if err := service.SetAdmin(newUser.Name, true); err != nil {
+		t.Fatal(err)
+	}
+	if user := getDbUserByName(newUser.Name); !user.IsAdmin {
+		t.Error("not promoted", user)
+	}
+	if err := service.SetAdmin(newUser.Name, false); err != nil {
+		t.Fatal(err)
+	}
+	if user := getDbUserByName(newUser.Name); user.IsAdmin {
+		t.Error("not demoted", user)
+	}
+	if err := service.SetAdmin("notexistinguser", true); err == nil {
+		t.Error("promoted a missing user")
+	}
+}
+
+func TestSetCollectionLimit(t *testing.T) {
+	newUser := service.CreateUserT{
+		Name:     "setcollectionlimit",
+		Email:    "setcollectionlimit@irl.hu",
+		Password: "setcollectionlimit",
+	}
+	testRegisterUserSuccess(t, newUser)
+	collection := collectionT{
+		Name: "limited.org",
+	}
+
+	if err := service.SetCollectionLimit(newUser.Name, 0); err != nil {
+		t.Fatal(err)
+	}
+	if user := getDbUserByName(newUser.Name); !user.LimitCollections || user.CollectionLimit != 0 {
+		t.Error(user)
+	}
+	w, r := postJSON(collection)
+	r = setUserName(r, newUser.Name)
+	userBaseHandler(http.HandlerFunc(createCollection)).ServeHTTP(w, r)
+	testCode(t, w, 403)
+	testBody(t, w, "Collection limit exceeded (0)\n")
+
+	if err := service.SetCollectionLimit(newUser.Name, -1); err != nil {
+		t.Fatal(err)
+	}
+	if user := getDbUserByName(newUser.Name); user.LimitCollections || user.CollectionLimit != 0 {
+		t.Error(user)
+	}
+	createCollectionSuccess(t, newUser.Name, &collection)
+	created, err := db.GetCollectionByName(getDbUserByName(newUser.Name).ID, collection.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.DeleteCollection(created); err != nil {
+		t.Error(err)
+	}
+
+	if err := service.SetCollectionLimit("notexistinguser", 1); err == nil {
+		t.Error("limited a missing user")
+	}
+}
+
+func TestTransferCollection(t *testing.T) {
+	from := service.CreateUserT{
+		Name:     "transferfrom",
+		Email:    "transferfrom@irl.hu",
+		Password: "transferfrom",
+	}
+	to := service.CreateUserT{
+		Name:     "transferto",
+		Email:    "transferto@irl.hu",
+		Password: "transferto",
+	}
+	testRegisterUserSuccess(t, from)
+	testRegisterUserSuccess(t, to)
+	createCollectionSuccess(t, from.Name, &collectionT{Name: "transfer.org"})
+	fromUser := getDbUserByName(from.Name)
+	toUser := getDbUserByName(to.Name)
+	collection, err := db.GetCollectionByName(fromUser.ID, "transfer.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.TransferCollection(collection, "notexistinguser", true); err == nil {
+		t.Error("transferred to a missing user")
+	}
+	if stored, err := db.GetCollection(collection.ID); err != nil || stored.OwnerID != fromUser.ID {
+		t.Error(stored, err)
+	}
+	if err := service.SetCollectionLimit(to.Name, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err, ok := service.TransferCollection(collection, to.Name, true).(*service.Error); !ok || err.Message != service.ErrCollectionLimitExceeded.Message {
+		t.Error("transferred over the new owner's collection limit", err)
+	}
+	if err := service.SetCollectionLimit(to.Name, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.TransferCollection(collection, to.Name, true); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := db.GetCollection(collection.ID); err != nil || stored.OwnerID != toUser.ID || !db.UserIsTeammate(stored, fromUser.ID) {
+		t.Error("the previous owner isn't kept as a teammate", stored, err)
+	}
+	if _, err := db.GetCollectionByName(fromUser.ID, "transfer.org"); err == nil {
+		t.Error("the previous owner's index entry remained")
+	}
+	if moved, err := db.GetCollectionByName(toUser.ID, "transfer.org"); err != nil || moved.ID != collection.ID {
+		t.Error("the new owner's index entry is missing", moved, err)
+	}
+	if err := service.TransferCollection(collection, from.Name, false); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := db.GetCollection(collection.ID); err != nil || stored.OwnerID != fromUser.ID || len(stored.Teammates) != 0 {
+		t.Error("the teammate owner or the removed previous owner is a teammate", stored, err)
+	}
+	if err := service.DeleteCollection(collection); err != nil {
+		t.Error(err)
+	}
+}
+
 func createCollectionSuccess(t *testing.T, username string, collection *collectionT) {
 	collName := collection.Name
 	w, r := postJSON(collection)
@@ -569,6 +703,33 @@ func TestUpdateCollection(t *testing.T) {
 	}
 }
 
+func TestSessionSettings(t *testing.T) {
+	w, r := postJSON(service.SessionSettingsT{Timeout: 20, MidnightTimezone: "Mars/Olympus"})
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(updateSessionSettings))).ServeHTTP(w, r)
+	testCode(t, w, 400)
+
+	w, r = postJSON(service.SessionSettingsT{Timeout: 20, MidnightTimezone: timezone, VisitCounter: true})
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(updateSessionSettings))).ServeHTTP(w, r)
+	testCode(t, w, 200)
+	var settings service.SessionSettingsT
+	testJSONBody(t, w, &settings)
+	if settings.Timeout != 20 || !settings.VisitCounter {
+		t.Error(settings)
+	}
+
+	w, r = postJSON(service.SessionSettingsT{})
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(updateSessionSettings))).ServeHTTP(w, r)
+	testCode(t, w, 200)
+	settings = service.SessionSettingsT{}
+	testJSONBody(t, w, &settings)
+	if settings.Timeout != 30 || settings.MidnightTimezone != "" || settings.VisitCounter {
+		t.Error(settings)
+	}
+}
+
 func TestDeleteCollection(t *testing.T) {
 	collection := collectionT{
 		Name: "newname",
@@ -592,6 +753,54 @@ func TestCreateSession(t *testing.T) {
 	testCode(t, w, 200)
 }
 
+func TestMetrics(t *testing.T) {
+	w, r := postJSON(nil)
+	getMetrics(w, r)
+	testCode(t, w, 404)
+
+	config.ActualConfig.MetricsToken = "metricstoken"
+	defer func() { config.ActualConfig.MetricsToken = "" }()
+
+	w, r = postJSON(nil)
+	r.Header.Set("Authorization", "Bearer badtoken")
+	getMetrics(w, r)
+	testCode(t, w, 403)
+
+	w, r = postJSON(nil)
+	r.Header.Set("Authorization", "Bearer metricstoken")
+	getMetrics(w, r)
+	testCode(t, w, 200)
+	body := w.Body.String()
+	for _, s := range []string{
+		`rightana_collect_requests_total{endpoint="sessions",outcome="ok"} 1`,
+		"rightana_shard_upsert_duration_seconds_count 1",
+		"rightana_shard_dbs_open",
+		"rightana_auth_failures_total",
+		`rightana_shard_size_bytes{collection="` + collectionData.ID + `",shard="`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Error(s, body)
+		}
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	w, r := postJSON(nil)
+	getHealthz(w, r)
+	testCode(t, w, 200)
+
+	w, r = postJSON(nil)
+	getReadyz(w, r)
+	testCode(t, w, 503)
+	var readiness service.ReadinessT
+	testJSONBody(t, w, &readiness)
+	if readiness.Checks["db"].Status != service.StatusOK ||
+		readiness.Checks["datadir"].Status != service.StatusOK ||
+		readiness.Checks["geoip_city"].Status != service.StatusFail {
+		t.Error(readiness)
+	}
+}
+
 func TestCollectionBaseHandler(t *testing.T) {
 	w, r := postJSON(nil)
 	r = setCollectionName(r, userData.Name, collectionData.Name)
@@ -808,6 +1017,11 @@ func TestCreatePageView(t *testing.T) {
 	r.Header.Set("User-Agent", userAgent)
 	createPageview(w, r)
 	testCode(t, w, 200)
+	var key string
+	testJSONBody(t, w, &key)
+	if key != sessionKey {
+		t.Error(key, sessionKey)
+	}
 }
 
 func TestUpdateSession(t *testing.T) {
@@ -818,6 +1032,17 @@ func TestUpdateSession(t *testing.T) {
 	testCode(t, w, 200)
 }
 
+func TestSessionHeartbeat(t *testing.T) {
+	w, r := postJSON(sessionUpdateData)
+	sessionHeartbeat(w, r)
+	testCode(t, w, 200)
+
+	badSessionUpdateData.CollectionID = collectionData.ID
+	w, r = postJSON(badSessionUpdateData)
+	sessionHeartbeat(w, r)
+	testCode(t, w, 404)
+}
+
 func TestGetPageViews(t *testing.T) {
 	pageviewInput.SessionKey = sessionKey
 	w, r := postJSON(pageviewInput)
@@ -832,6 +1057,446 @@ func TestGetPageViews(t *testing.T) {
 	}
 }
 
+func TestCustomProperties(t *testing.T) {
+	w, r := postJSON(service.PropertyLimitsT{MaxKeys: 100})
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(updatePropertyLimits))).ServeHTTP(w, r)
+	testCode(t, w, 400)
+
+	w, r = postJSON(service.PropertyLimitsT{MaxKeys: 2, MaxValueLength: 5})
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(updatePropertyLimits))).ServeHTTP(w, r)
+	testCode(t, w, 200)
+
+	input := pageViewData
+	input.Properties = map[string]string{"plan": "enterprise", "author": "soyer", "variant": "b"}
+	w, r = postJSON(input)
+	r.Header.Set("User-Agent", userAgent)
+	createPageview(w, r)
+	testCode(t, w, 200)
+
+	collection, err := service.GetCollection(collectionData.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := db.GetStatistics(collection, &db.CollectionDataInputT{
+		From:   fromTime,
+		To:     toTime,
+		Filter: map[string]string{db.PageviewPropertyFilter + "plan": "enter"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.PageviewTotal.Count != 1 || len(stat.PageviewPropertySums) != 2 || len(stat.PageviewPropertySums["variant"]) != 0 {
+		t.Error(stat.PageviewTotal, stat.PageviewPropertySums)
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	fired := make(chan []byte, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := &bytes.Buffer{}
+		b.ReadFrom(r.Body)
+		fired <- b.Bytes()
+	}))
+	defer ts.Close()
+
+	badAlert := service.AlertT{Name: "bad", Metric: "visits", Condition: service.AlertAbove, WindowMinutes: 60, WebhookURL: ts.URL}
+	w, r := postJSON(badAlert)
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(createAlert))).ServeHTTP(w, r)
+	testCode(t, w, 400)
+	testBody(t, w, "Invalid alert (visits)\n")
+
+	config.ActualConfig.AllowPrivateTargets = false
+	for _, target := range []string{ts.URL, "http://localhost/alert", "http://[::1]/alert", "http://10.1.2.3/alert"} {
+		w, r = postJSON(service.AlertT{Name: "private", Metric: "sessions", Condition: service.AlertAbove, WindowMinutes: 60, WebhookURL: target})
+		r = setCollectionName(r, userData.Name, collectionData.Name)
+		userBaseHandler(collectionBaseHandler(http.HandlerFunc(createAlert))).ServeHTTP(w, r)
+		testCode(t, w, 403)
+		testBody(t, w, "Forbidden target ("+target+")\n")
+	}
+	config.ActualConfig.AllowPrivateTargets = true
+
+	w, r = postJSON(service.AlertT{Name: "spam", Metric: "sessions", Condition: service.AlertAbove, WindowMinutes: 60, Email: userData.Email + ", stranger@irl.hu"})
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(createAlert))).ServeHTTP(w, r)
+	testCode(t, w, 403)
+	testBody(t, w, "Forbidden target (stranger@irl.hu)\n")
+
+	alertData := service.AlertT{Name: "traffic", Metric: "sessions", Condition: service.AlertAbove, WindowMinutes: 60, WebhookURL: ts.URL}
+	w, r = postJSON(alertData)
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(createAlert))).ServeHTTP(w, r)
+	testCode(t, w, 200)
+	var alert service.AlertT
+	testJSONBody(t, w, &alert)
+	if alert.ID == 0 || alert.CooldownMinutes == 0 {
+		t.Error(alert)
+	}
+
+	if err := service.CheckAlerts(time.Now().Add(time.Minute)); err != nil {
+		t.Error(err)
+	}
+	select {
+	case body := <-fired:
+		if !strings.Contains(string(body), alertData.Name) {
+			t.Error(string(body))
+		}
+	default:
+		t.Error("alert not fired")
+	}
+
+	if err := service.CheckAlerts(time.Now().Add(2 * time.Minute)); err != nil {
+		t.Error(err)
+	}
+	select {
+	case <-fired:
+		t.Error("alert fired in cooldown")
+	default:
+	}
+
+	alertID := strconv.FormatUint(alert.ID, 10)
+	w, r = postJSON(nil)
+	r = getReqWithRouteContext(r, kv{"name": userData.Name, "collectionName": collectionData.Name, "alertID": alertID})
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(getAlertEvents))).ServeHTTP(w, r)
+	testCode(t, w, 200)
+	events := []service.AlertEventT{}
+	testJSONBody(t, w, &events)
+	if len(events) != 1 || events[0].Value != 1 || events[0].Error != "" {
+		t.Error(events)
+	}
+
+	w, r = postJSON(nil)
+	r = getReqWithRouteContext(r, kv{"name": userData.Name, "collectionName": collectionData.Name, "alertID": alertID})
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(deleteAlert))).ServeHTTP(w, r)
+	testCode(t, w, 200)
+
+	w, r = postJSON(nil)
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(getAlerts))).ServeHTTP(w, r)
+	alerts := []service.AlertT{}
+	testJSONBody(t, w, &alerts)
+	if len(alerts) != 0 {
+		t.Error(alerts)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	type requestT struct {
+		signature string
+		body      []byte
+	}
+	fail := true
+	received := make(chan requestT, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			fail = false
+			w.WriteHeader(500)
+			return
+		}
+		b := &bytes.Buffer{}
+		b.ReadFrom(r.Body)
+		received <- requestT{r.Header.Get(service.WebhookSignatureHeader), b.Bytes()}
+	}))
+	defer ts.Close()
+
+	w, r := postJSON(service.WebhookT{URL: ts.URL, Events: []string{"goal.converted"}})
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(createWebhook))).ServeHTTP(w, r)
+	testCode(t, w, 400)
+	testBody(t, w, "Invalid webhook (goal.converted)\n")
+
+	config.ActualConfig.AllowPrivateTargets = false
+	w, r = postJSON(service.WebhookT{URL: ts.URL, Events: []string{service.WebhookDailySummary}})
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(createWebhook))).ServeHTTP(w, r)
+	testCode(t, w, 403)
+	testBody(t, w, "Forbidden target ("+ts.URL+")\n")
+	config.ActualConfig.AllowPrivateTargets = true
+
+	w, r = postJSON(service.WebhookT{URL: ts.URL, Events: []string{service.WebhookDailySummary}})
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(createWebhook))).ServeHTTP(w, r)
+	testCode(t, w, 200)
+	var webhook service.WebhookT
+	testJSONBody(t, w, &webhook)
+	if webhook.ID == 0 || webhook.Secret == "" {
+		t.Error(webhook)
+	}
+
+	now := time.Now().AddDate(0, 0, 1)
+	if err := service.QueueDailySummaries(now); err != nil {
+		t.Error(err)
+	}
+	if err := service.DeliverWebhooks(now); err != nil {
+		t.Error(err)
+	}
+	select {
+	case <-received:
+		t.Error("the first delivery should fail")
+	default:
+	}
+
+	if err := service.DeliverWebhooks(now.Add(time.Hour)); err != nil {
+		t.Error(err)
+	}
+	select {
+	case req := <-received:
+		if !strings.Contains(string(req.body), service.WebhookDailySummary) {
+			t.Error(string(req.body))
+		}
+		if req.signature != "sha256="+service.SignWebhook(webhook.Secret, req.body) {
+			t.Error(req.signature)
+		}
+	default:
+		t.Error("webhook not delivered")
+	}
+
+	w, r = postJSON(nil)
+	r = getReqWithRouteContext(r, kv{"name": userData.Name, "collectionName": collectionData.Name, "webhookID": strconv.FormatUint(webhook.ID, 10)})
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(deleteWebhook))).ServeHTTP(w, r)
+	testCode(t, w, 200)
+
+	w, r = postJSON(nil)
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(getWebhooks))).ServeHTTP(w, r)
+	webhooks := []service.WebhookT{}
+	testJSONBody(t, w, &webhooks)
+	if len(webhooks) != 0 {
+		t.Error(webhooks)
+	}
+}
+
+func TestBackups(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "rightana-backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	config.ActualConfig.Backup = map[string]config.BackupConfig{
+		"test": {Dir: tmp, Interval: "1h", Archive: true, KeepLast: 1},
+	}
+	defer func() { config.ActualConfig.Backup = nil }()
+
+	for i := 0; i < 2; i++ {
+		w, r := postJSON(nil)
+		r = getReqWithRouteContext(r, kv{"backupID": "test"})
+		runBackup(w, r)
+		testCode(t, w, 200)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := service.RunDueBackups(time.Now().Add(2 * time.Hour)); err != nil {
+		t.Error(err)
+	}
+	files, err := ioutil.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), ".tar.gz") {
+		t.Error(files)
+	}
+
+	w, r := postJSON(nil)
+	r = getReqWithRouteContext(r, kv{"backupID": "test"})
+	getBackupRuns(w, r)
+	testCode(t, w, 200)
+	runs := []service.BackupRunT{}
+	testJSONBody(t, w, &runs)
+	if len(runs) != 3 || runs[0].Error != "" || runs[0].Size == 0 {
+		t.Error(runs)
+	}
+
+	w, r = postJSON(nil)
+	getBackups(w, r)
+	testCode(t, w, 200)
+	backups := []service.Backup{}
+	testJSONBody(t, w, &backups)
+	if len(backups) != 1 || backups[0].LastRun == nil || backups[0].LastRun.ID != runs[0].ID || backups[0].NextRun == 0 {
+		t.Error(backups)
+	}
+}
+
+func TestDownloadBackup(t *testing.T) {
+	w, r := postJSON(nil)
+	r.URL.RawQuery = "gzip=1"
+	downloadBackup(w, r)
+	testCode(t, w, 200)
+	if w.Header().Get("content-type") != "application/gzip" {
+		t.Error(w.Header())
+	}
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := readTarNames(t, gr)
+	if len(names) < 3 || names[0] != "rightana.bolt" || !containsString(names, collectionData.ID+"/") {
+		t.Error(names)
+	}
+
+	w, r = postJSON(nil)
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(downloadCollectionBackup))).ServeHTTP(w, r)
+	testCode(t, w, 200)
+	if !strings.Contains(w.Header().Get("content-disposition"), ".tar\"") {
+		t.Error(w.Header())
+	}
+	names = readTarNames(t, w.Body)
+	if len(names) < 2 || names[0] != collectionData.ID+"/" {
+		t.Error(names)
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, collectionData.ID+"/") {
+			t.Error(name)
+		}
+	}
+}
+
+func TestExportImportCollection(t *testing.T) {
+	w, r := postJSON(nil)
+	r = setCollectionName(r, userData.Name, collectionData.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(exportCollection))).ServeHTTP(w, r)
+	testCode(t, w, 200)
+	export := w.Body.Bytes()
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/import?name=imported.org", bytes.NewReader(export))
+	r = setUserName(r, userData.Name)
+	userBaseHandler(http.HandlerFunc(importCollection)).ServeHTTP(w, r)
+	testCode(t, w, 200)
+	report := service.ImportReportT{}
+	testJSONBody(t, w, &report)
+	if report.CollectionID == collectionData.ID || report.Sessions == 0 || report.Pageviews == 0 {
+		t.Error(report)
+	}
+	imported, err := db.GetCollection(report.CollectionID)
+	if err != nil || imported.Name != "imported.org" {
+		t.Error(imported, err)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/import", bytes.NewReader(export))
+	r = setCollectionName(r, userData.Name, imported.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(importIntoCollection))).ServeHTTP(w, r)
+	testCode(t, w, 200)
+	again := service.ImportReportT{}
+	testJSONBody(t, w, &again)
+	if again.Sessions != 0 || again.Skipped != report.Sessions+report.Pageviews {
+		t.Error(again)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/import", bytes.NewBufferString("garbage"))
+	r = setUserName(r, userData.Name)
+	userBaseHandler(http.HandlerFunc(importCollection)).ServeHTTP(w, r)
+	testCode(t, w, 400)
+
+	truncated := export[:len(export)-3]
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/import?name=truncated.org", bytes.NewReader(truncated))
+	r = setUserName(r, userData.Name)
+	userBaseHandler(http.HandlerFunc(importCollection)).ServeHTTP(w, r)
+	testCode(t, w, 400)
+	if _, err := db.GetCollectionByName(imported.OwnerID, "truncated.org"); err == nil {
+		t.Error("the failed import's new collection is kept")
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/import", bytes.NewReader(truncated))
+	r = setCollectionName(r, userData.Name, imported.Name)
+	userBaseHandler(collectionBaseHandler(http.HandlerFunc(importIntoCollection))).ServeHTTP(w, r)
+	testCode(t, w, 400)
+	partial := service.ImportReportT{}
+	testJSONBody(t, w, &partial)
+	if partial.CollectionID != imported.ID || partial.Error == "" {
+		t.Error(partial)
+	}
+
+	if err := db.DeleteCollection(imported); err != nil {
+		t.Error(err)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func readTarNames(t *testing.T, r io.Reader) []string {
+	names := []string{}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Typeflag == tar.TypeReg {
+			b, err := ioutil.ReadAll(tr)
+			if err != nil || int64(len(b)) != h.Size {
+				t.Error(h.Name, err)
+			}
+		}
+		names = append(names, h.Name)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	w, r := postJSON(service.DigestT{Frequency: "daily"})
+	r = setUserName(r, userData.Name)
+	userBaseHandler(http.HandlerFunc(updateDigest)).ServeHTTP(w, r)
+	testCode(t, w, 400)
+	testBody(t, w, "Invalid digest frequency (daily)\n")
+
+	w, r = postJSON(service.DigestT{Frequency: service.DigestWeekly, Collections: []string{"notexists"}})
+	r = setUserName(r, userData.Name)
+	userBaseHandler(http.HandlerFunc(updateDigest)).ServeHTTP(w, r)
+	testCode(t, w, 404)
+
+	w, r = postJSON(service.DigestT{Frequency: service.DigestWeekly, Collections: []string{collectionData.ID}})
+	r = setUserName(r, userData.Name)
+	userBaseHandler(http.HandlerFunc(updateDigest)).ServeHTTP(w, r)
+	testCode(t, w, 200)
+
+	w, r = postJSON(nil)
+	r = setUserName(r, userData.Name)
+	userBaseHandler(http.HandlerFunc(getDigest)).ServeHTTP(w, r)
+	testCode(t, w, 200)
+	var digest service.DigestT
+	testJSONBody(t, w, &digest)
+	if digest.Frequency != service.DigestWeekly || len(digest.Collections) != 1 || digest.Collections[0] != collectionData.ID {
+		t.Error(digest)
+	}
+
+	type emailT struct{ recipient, subject, body string }
+	emails := []emailT{}
+	defer func(send func(recipient, name, subject, htmlBody string) error) { mail.SendUserEmail = send }(mail.SendUserEmail)
+	mail.SendUserEmail = func(recipient, name, subject, htmlBody string) error {
+		emails = append(emails, emailT{recipient, subject, htmlBody})
+		return nil
+	}
+	now := time.Now().AddDate(0, 0, 14)
+	if err := service.SendDigests(now); err != nil {
+		t.Error(err)
+	}
+	if len(emails) != 1 || emails[0].recipient != userData.Email || !strings.Contains(emails[0].subject, service.DigestWeekly) ||
+		!strings.Contains(emails[0].body, collectionData.Name) {
+		t.Fatal("the digest isn't sent", emails)
+	}
+	if user := getDbUserByName(userData.Name); user.DigestSentAt != now.UnixNano() {
+		t.Error("the digest sending isn't recorded", user.DigestSentAt)
+	}
+	if err := service.SendDigests(now.Add(time.Hour)); err != nil || len(emails) != 1 {
+		t.Error("the digest is sent again in the same period", len(emails), err)
+	}
+}
+
 /*
 func TestGetCollectionData(t *testing.T) {
 	w, r := postJSON(collectionInput)
@@ -919,6 +1584,8 @@ func TestMain(m *testing.M) {
 	runCmd("", "rm", "-rf", "data")
 	runCmd("", "mkdir", "data")
 	db.InitDatabase("data")
+	// the tests' webhook servers listen on the loopback
+	config.ActualConfig.AllowPrivateTargets = true
 	ret := m.Run()
 	if ret == 0 {
 		runCmd("", "rm", "-rf", "data")
@@ -1,14 +1,21 @@
 package api
 
 import (
+	"io"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/soyersoyer/rightana/internal/service"
 )
 
 func getBackupsE(w http.ResponseWriter, r *http.Request) error {
-	return respond(w, service.GetBackups())
+	backups, err := service.GetBackups()
+	if err != nil {
+		return err
+	}
+	return respond(w, backups)
 }
 
 var getBackups = handleError(getBackupsE)
@@ -20,3 +27,41 @@ func runBackupE(w http.ResponseWriter, r *http.Request) error {
 }
 
 var runBackup = handleError(runBackupE)
+
+func getBackupRunsE(w http.ResponseWriter, r *http.Request) error {
+	backupID := chi.URLParam(r, "backupID")
+	runs, err := service.GetBackupRuns(backupID)
+	if err != nil {
+		return err
+	}
+	return respond(w, runs)
+}
+
+var getBackupRuns = handleError(getBackupRunsE)
+
+func streamBackup(w http.ResponseWriter, r *http.Request, name string, write func(w io.Writer, compress bool) error) {
+	compress := r.URL.Query().Get("gzip") != ""
+	filename := name + "-" + time.Now().Format("20060102-150405") + ".tar"
+	contentType := "application/x-tar"
+	if compress {
+		filename += ".gz"
+		contentType = "application/gzip"
+	}
+	w.Header().Set("content-type", contentType)
+	w.Header().Set("content-disposition", "attachment; filename=\""+filename+"\"")
+	// the status is already sent when the stream fails, so the error can only be logged
+	if err := write(w, compress); err != nil {
+		log.Println("backup download failed:", err)
+	}
+}
+
+func downloadBackup(w http.ResponseWriter, r *http.Request) {
+	streamBackup(w, r, "rightana", service.WriteBackup)
+}
+
+func downloadCollectionBackup(w http.ResponseWriter, r *http.Request) {
+	collection := getCollectionCtx(r.Context())
+	streamBackup(w, r, "rightana-"+collection.Name, func(w io.Writer, compress bool) error {
+		return service.WriteCollectionBackup(w, collection, compress)
+	})
+}
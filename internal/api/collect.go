@@ -15,6 +15,10 @@ type createSessionInputT struct {
 	WindowResolution string `json:"wr"`
 	DeviceType       string `json:"dt"`
 	Referrer         string `json:"r"`
+	// the tracker's optional visit counter
+	VisitCount         int32             `json:"vc"`
+	DaysSinceLastVisit int32             `json:"vd"`
+	Properties         map[string]string `json:"pr"`
 }
 
 func createSessionE(w http.ResponseWriter, r *http.Request) error {
@@ -31,28 +35,32 @@ func createSessionE(w http.ResponseWriter, r *http.Request) error {
 	return respond(w, sessionKey)
 }
 
-var createSession = handleError(createSessionE)
+var createSession = handleError(collectMetrics("sessions", createSessionE))
 
-type updateSessionInputT struct {
+type sessionHeartbeatInputT struct {
 	CollectionID string `json:"c"`
 	SessionKey   string `json:"s"`
 }
 
-func updateSessionE(w http.ResponseWriter, r *http.Request) error {
-	var input updateSessionInputT
+func sessionHeartbeatE(w http.ResponseWriter, r *http.Request) error {
+	var input sessionHeartbeatInputT
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		return service.ErrInputDecodeFailed.Wrap(err)
 	}
 
-	return service.UpdateSession(r.UserAgent(), input.CollectionID, input.SessionKey)
+	return service.SessionHeartbeat(r.UserAgent(), input.CollectionID, input.SessionKey)
 }
 
-var updateSession = handleError(updateSessionE)
+var sessionHeartbeat = handleError(collectMetrics("sessions/heartbeat", sessionHeartbeatE))
+
+// updateSession is the heartbeat endpoint of the old trackers
+var updateSession = handleError(collectMetrics("sessions/update", sessionHeartbeatE))
 
 type createPageviewInputT struct {
-	CollectionID string `json:"c"`
-	SessionKey   string `json:"s"`
-	Path         string `json:"p"`
+	CollectionID string            `json:"c"`
+	SessionKey   string            `json:"s"`
+	Path         string            `json:"p"`
+	Properties   map[string]string `json:"pr"`
 }
 
 func createPageviewE(w http.ResponseWriter, r *http.Request) error {
@@ -61,7 +69,11 @@ func createPageviewE(w http.ResponseWriter, r *http.Request) error {
 		return service.ErrInputDecodeFailed.Wrap(err)
 	}
 
-	return service.CreatePageview(r.UserAgent(), service.CreatePageviewInputT(input))
+	sessionKey, err := service.CreatePageview(r.UserAgent(), service.CreatePageviewInputT(input))
+	if err != nil {
+		return err
+	}
+	return respond(w, sessionKey)
 }
 
-var createPageview = handleError(createPageviewE)
+var createPageview = handleError(collectMetrics("pageviews", createPageviewE))
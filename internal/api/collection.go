@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi"
 
@@ -11,8 +12,9 @@ import (
 )
 
 type collectionT struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	ShardGranularity string `json:"shard_granularity"`
 }
 
 func getCollectionSummariesE(w http.ResponseWriter, r *http.Request) error {
@@ -39,18 +41,23 @@ func createCollectionE(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	user := getUserCtx(r.Context())
-	collection, err := service.CreateCollection(user.ID, input.Name)
+	collection, err := service.CreateCollection(user.ID, input.Name, input.ShardGranularity)
 	if err != nil {
 		return err
 	}
-	return respond(w, collectionT{
-		Name: collection.Name,
-		ID:   collection.ID,
-	})
+	return respond(w, toCollectionT(collection))
 }
 
 var createCollection = handleError(createCollectionE)
 
+func toCollectionT(collection *service.Collection) collectionT {
+	return collectionT{
+		collection.ID,
+		collection.Name,
+		service.GetShardGranularity(collection),
+	}
+}
+
 func setCollectionCtx(ctx context.Context, collection *service.Collection) context.Context {
 	return context.WithValue(ctx, keyCollection, collection)
 }
@@ -115,10 +122,7 @@ func collectionCreateAccessHandler(next http.Handler) http.Handler {
 
 func getCollectionE(w http.ResponseWriter, r *http.Request) error {
 	collection := getCollectionCtx(r.Context())
-	return respond(w, collectionT{
-		collection.ID,
-		collection.Name,
-	})
+	return respond(w, toCollectionT(collection))
 }
 
 var getCollection = handleError(getCollectionE)
@@ -133,14 +137,53 @@ func updateCollectionE(w http.ResponseWriter, r *http.Request) error {
 	if err := service.UpdateCollection(collection, input.Name); err != nil {
 		return err
 	}
-	return respond(w, collectionT{
-		collection.ID,
-		collection.Name,
-	})
+	return respond(w, toCollectionT(collection))
 }
 
 var updateCollection = handleError(updateCollectionE)
 
+func getSessionSettingsE(w http.ResponseWriter, r *http.Request) error {
+	collection := getCollectionCtx(r.Context())
+	return respond(w, service.GetSessionSettings(collection))
+}
+
+var getSessionSettings = handleError(getSessionSettingsE)
+
+func updateSessionSettingsE(w http.ResponseWriter, r *http.Request) error {
+	collection := getCollectionCtx(r.Context())
+	var input service.SessionSettingsT
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return service.ErrInputDecodeFailed.Wrap(err)
+	}
+	if err := service.UpdateSessionSettings(collection, &input); err != nil {
+		return err
+	}
+	return respond(w, service.GetSessionSettings(collection))
+}
+
+var updateSessionSettings = handleError(updateSessionSettingsE)
+
+func getPropertyLimitsE(w http.ResponseWriter, r *http.Request) error {
+	collection := getCollectionCtx(r.Context())
+	return respond(w, service.GetPropertyLimits(collection))
+}
+
+var getPropertyLimits = handleError(getPropertyLimitsE)
+
+func updatePropertyLimitsE(w http.ResponseWriter, r *http.Request) error {
+	collection := getCollectionCtx(r.Context())
+	var input service.PropertyLimitsT
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return service.ErrInputDecodeFailed.Wrap(err)
+	}
+	if err := service.UpdatePropertyLimits(collection, &input); err != nil {
+		return err
+	}
+	return respond(w, service.GetPropertyLimits(collection))
+}
+
+var updatePropertyLimits = handleError(updatePropertyLimitsE)
+
 func deleteCollectionE(w http.ResponseWriter, r *http.Request) error {
 	collection := getCollectionCtx(r.Context())
 
@@ -249,10 +292,11 @@ func getSessionsE(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	collection := getCollectionCtx(r.Context())
-	sessions, err := service.GetSessions(collection, &input)
+	sessions, total, err := service.GetSessions(collection, &input)
 	if err != nil {
 		return err
 	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	return respond(w, sessions)
 }
 
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/soyersoyer/rightana/internal/service"
+)
+
+func getErasuresE(w http.ResponseWriter, r *http.Request) error {
+	collection := getCollectionCtx(r.Context())
+	erasures, err := service.GetErasures(collection)
+	if err != nil {
+		return err
+	}
+	return respond(w, erasures)
+}
+
+var getErasures = handleError(getErasuresE)
+
+func eraseSessionsE(w http.ResponseWriter, r *http.Request) error {
+	var input service.ErasureInputT
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return service.ErrInputDecodeFailed.Wrap(err)
+	}
+
+	collection := getCollectionCtx(r.Context())
+	user := getLoggedInUserCtx(r.Context())
+	dryRun := r.URL.Query().Get("dry_run") != ""
+	erasure, err := service.EraseSessions(collection, &input, user.Name, dryRun)
+	if err != nil {
+		return err
+	}
+	return respond(w, erasure)
+}
+
+var eraseSessions = handleError(eraseSessionsE)
+
+func purgeCollectionE(w http.ResponseWriter, r *http.Request) error {
+	var input service.PurgeInputT
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return service.ErrInputDecodeFailed.Wrap(err)
+	}
+
+	collection := getCollectionCtx(r.Context())
+	report, err := service.PurgeCollection(collection, &input)
+	if err != nil {
+		return err
+	}
+	return respond(w, report)
+}
+
+var purgeCollection = handleError(purgeCollectionE)
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/soyersoyer/rightana/internal/service"
+)
+
+func exportCollection(w http.ResponseWriter, r *http.Request) {
+	collection := getCollectionCtx(r.Context())
+	filename := collection.Name + "-" + time.Now().Format("20060102-150405") + ".rightana"
+	w.Header().Set("content-type", "application/octet-stream")
+	w.Header().Set("content-disposition", "attachment; filename=\""+filename+"\"")
+	// the status is already sent when the stream fails, so the error can only be logged
+	if err := service.ExportCollection(w, collection); err != nil {
+		log.Println("collection export failed:", err)
+	}
+}
+
+func importCollectionE(w http.ResponseWriter, r *http.Request) error {
+	user := getUserCtx(r.Context())
+	report, err := service.ImportCollection(r.Body, user, "", r.URL.Query().Get("name"))
+	return respondImport(w, report, err)
+}
+
+var importCollection = handleError(importCollectionE)
+
+func importIntoCollectionE(w http.ResponseWriter, r *http.Request) error {
+	user := getUserCtx(r.Context())
+	collection := getCollectionCtx(r.Context())
+	report, err := service.ImportCollection(r.Body, user, collection.ID, "")
+	return respondImport(w, report, err)
+}
+
+var importIntoCollection = handleError(importIntoCollectionE)
+
+// respondImport sends the report of a failed import with the error's status too,
+// so the client knows which records are already imported
+func respondImport(w http.ResponseWriter, report *service.ImportReportT, err error) error {
+	if err == nil {
+		return respond(w, report)
+	}
+	e, ok := err.(*service.Error)
+	if report == nil || !ok {
+		return err
+	}
+	log.Println(e)
+	report.Error = e.HTTPMessage()
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(e.Code)
+	return json.NewEncoder(w).Encode(report)
+}
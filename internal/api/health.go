@@ -0,0 +1,25 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/soyersoyer/rightana/internal/service"
+)
+
+func getHealthzE(w http.ResponseWriter, r *http.Request) error {
+	return respond(w, map[string]string{"status": service.StatusOK})
+}
+
+var getHealthz = handleError(getHealthzE)
+
+func getReadyzE(w http.ResponseWriter, r *http.Request) error {
+	readiness := service.CheckReadiness()
+	w.Header().Set("content-type", "application/json")
+	if readiness.Status != service.StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	return json.NewEncoder(w).Encode(readiness)
+}
+
+var getReadyz = handleError(getReadyzE)
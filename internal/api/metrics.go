@@ -0,0 +1,60 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/soyersoyer/rightana/internal/config"
+	"github.com/soyersoyer/rightana/internal/metrics"
+	"github.com/soyersoyer/rightana/internal/service"
+)
+
+var (
+	collectRequests = metrics.NewCounter("rightana_collect_requests_total",
+		"The number of the collector requests.", "endpoint", "outcome")
+	collectDuration = metrics.NewHistogram("rightana_collect_request_duration_seconds",
+		"The latency of the collector requests.", nil, "endpoint", "outcome")
+)
+
+// collectMetrics measures the collector endpoint's requests by their outcome
+func collectMetrics(endpoint string, fn handlerFuncWithError) handlerFuncWithError {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		start := time.Now()
+		err := fn(w, r)
+		outcome := "ok"
+		switch e := err.(type) {
+		case nil:
+		case *service.Error:
+			if e == service.ErrBotsDontMatter {
+				outcome = "bot"
+			} else if e.Code < 500 {
+				outcome = "rejected"
+			} else {
+				outcome = "error"
+			}
+		default:
+			outcome = "error"
+		}
+		collectRequests.Inc(endpoint, outcome)
+		collectDuration.ObserveSince(start, endpoint, outcome)
+		return err
+	}
+}
+
+func getMetricsE(w http.ResponseWriter, r *http.Request) error {
+	token := config.ActualConfig.MetricsToken
+	if token == "" {
+		http.NotFound(w, r)
+		return nil
+	}
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(auth), []byte(token)) != 1 {
+		return service.ErrAccessDenied
+	}
+	w.Header().Set("content-type", "text/plain; version=0.0.4")
+	return metrics.Write(w)
+}
+
+var getMetrics = handleError(getMetricsE)
@@ -171,3 +171,24 @@ func resetPasswordE(w http.ResponseWriter, r *http.Request) error {
 }
 
 var resetPassword = handleError(resetPasswordE)
+
+func getDigestE(w http.ResponseWriter, r *http.Request) error {
+	user := getUserCtx(r.Context())
+	return respond(w, service.GetDigest(user))
+}
+
+var getDigest = handleError(getDigestE)
+
+func updateDigestE(w http.ResponseWriter, r *http.Request) error {
+	user := getUserCtx(r.Context())
+	var input service.DigestT
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return service.ErrInputDecodeFailed.Wrap(err)
+	}
+	if err := service.UpdateDigest(user, &input); err != nil {
+		return err
+	}
+	return respond(w, service.GetDigest(user))
+}
+
+var updateDigest = handleError(updateDigestE)
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/soyersoyer/rightana/internal/service"
+)
+
+func getWebhooksE(w http.ResponseWriter, r *http.Request) error {
+	collection := getCollectionCtx(r.Context())
+	webhooks, err := service.GetWebhooks(collection)
+	if err != nil {
+		return err
+	}
+	return respond(w, webhooks)
+}
+
+var getWebhooks = handleError(getWebhooksE)
+
+func createWebhookE(w http.ResponseWriter, r *http.Request) error {
+	var input service.WebhookT
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return service.ErrInputDecodeFailed.Wrap(err)
+	}
+
+	collection := getCollectionCtx(r.Context())
+	webhook, err := service.CreateWebhook(collection, &input)
+	if err != nil {
+		return err
+	}
+	return respond(w, webhook)
+}
+
+var createWebhook = handleError(createWebhookE)
+
+func updateWebhookE(w http.ResponseWriter, r *http.Request) error {
+	var input service.WebhookT
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return service.ErrInputDecodeFailed.Wrap(err)
+	}
+
+	collection := getCollectionCtx(r.Context())
+	webhookID := chi.URLParam(r, "webhookID")
+	webhook, err := service.UpdateWebhook(collection, webhookID, &input)
+	if err != nil {
+		return err
+	}
+	return respond(w, webhook)
+}
+
+var updateWebhook = handleError(updateWebhookE)
+
+func deleteWebhookE(w http.ResponseWriter, r *http.Request) error {
+	collection := getCollectionCtx(r.Context())
+	webhookID := chi.URLParam(r, "webhookID")
+	if err := service.DeleteWebhook(collection, webhookID); err != nil {
+		return err
+	}
+	return respond(w, webhookID)
+}
+
+var deleteWebhook = handleError(deleteWebhookE)
@@ -8,23 +8,44 @@ import (
 
 // Config contains the configuration options
 type Config struct {
-	Listening          string
-	GeoIPCityFile      string
-	GeoIPASNFile       string
-	DataDir            string
-	EnableRegistration bool
-	UseBundledWebApp   bool
-	TrackingID         string
-	ServerAnnounce     string
-	Backup             map[string]string
-	AppName            string
-	AppURL             string
-	EmailExpiryMinutes int
-	SMTPHostname       string
-	SMTPPort           int
-	SMTPUser           string
-	SMTPPassword       string
-	SMTPSender         string
+	Listening           string
+	GeoIPCityFile       string
+	GeoIPASNFile        string
+	DataDir             string
+	EnableRegistration  bool
+	UseBundledWebApp    bool
+	TrackingID          string
+	ServerAnnounce      string
+	Backup              map[string]BackupConfig
+	AppName             string
+	AppURL              string
+	EmailExpiryMinutes  int
+	SMTPHostname        string
+	SMTPPort            int
+	SMTPUser            string
+	SMTPPassword        string
+	SMTPSender          string
+	AlertCheckMinutes   int
+	DigestCheckMinutes  int
+	WebhookSeconds      int
+	AllowPrivateTargets bool
+	MetricsToken        string
+	ShutdownSeconds     int
+	MinFreeDiskMB       int
+	AutoMigrate         bool
+	MaxOpenShards       int
+	Storage             string
+}
+
+// BackupConfig contains a backup's destination, schedule and rotation
+type BackupConfig struct {
+	Dir        string
+	Interval   string // a duration, eg 6h
+	At         string // a daily time, eg 03:00
+	Archive    bool
+	KeepLast   int
+	KeepDaily  int
+	KeepWeekly int
 }
 
 var (
@@ -57,6 +78,15 @@ func ReadConfig() Config {
 	viper.SetDefault("SMTPHostname", "localhost")
 	viper.SetDefault("SMTPPort", 25)
 
+	viper.SetDefault("AlertCheckMinutes", 5)
+	viper.SetDefault("DigestCheckMinutes", 60)
+	viper.SetDefault("WebhookSeconds", 10)
+	viper.SetDefault("ShutdownSeconds", 30)
+	viper.SetDefault("MinFreeDiskMB", 100)
+	viper.SetDefault("AutoMigrate", true)
+	viper.SetDefault("MaxOpenShards", 512)
+	viper.SetDefault("Storage", "bolt")
+
 	err := viper.ReadInConfig()
 	if err != nil {
 		log.Println(err)
@@ -69,7 +99,7 @@ func ReadConfig() Config {
 	ActualConfig.UseBundledWebApp = viper.GetBool("UseBundledWebApp")
 	ActualConfig.TrackingID = viper.GetString("TrackingID")
 	ActualConfig.ServerAnnounce = viper.GetString("ServerAnnounce")
-	ActualConfig.Backup = viper.GetStringMapString("Backup")
+	ActualConfig.Backup = readBackups()
 
 	ActualConfig.AppName = viper.GetString("AppName")
 	ActualConfig.AppURL = viper.GetString("AppURL")
@@ -81,9 +111,38 @@ func ReadConfig() Config {
 	ActualConfig.SMTPUser = viper.GetString("SMTPUser")
 	ActualConfig.SMTPSender = viper.GetString("SMTPSender")
 
+	ActualConfig.AlertCheckMinutes = viper.GetInt("AlertCheckMinutes")
+	ActualConfig.DigestCheckMinutes = viper.GetInt("DigestCheckMinutes")
+	ActualConfig.WebhookSeconds = viper.GetInt("WebhookSeconds")
+	ActualConfig.AllowPrivateTargets = viper.GetBool("AllowPrivateTargets")
+	ActualConfig.ShutdownSeconds = viper.GetInt("ShutdownSeconds")
+	ActualConfig.MinFreeDiskMB = viper.GetInt("MinFreeDiskMB")
+	ActualConfig.AutoMigrate = viper.GetBool("AutoMigrate")
+	ActualConfig.MaxOpenShards = viper.GetInt("MaxOpenShards")
+	ActualConfig.Storage = viper.GetString("Storage")
+
 	log.Printf("using config: %+v", ActualConfig)
 
 	ActualConfig.SMTPPassword = viper.GetString("SMTPPassword")
+	ActualConfig.MetricsToken = viper.GetString("MetricsToken")
 
 	return ActualConfig
 }
+
+// readBackups reads the Backup map, where the value is a dir or a BackupConfig
+func readBackups() map[string]BackupConfig {
+	backups := map[string]BackupConfig{}
+	for id, v := range viper.GetStringMap("Backup") {
+		if dir, ok := v.(string); ok {
+			backups[id] = BackupConfig{Dir: dir}
+			continue
+		}
+		backup := BackupConfig{}
+		if err := viper.UnmarshalKey("Backup."+id, &backup); err != nil {
+			log.Println("invalid backup config:", id, "cause:", err)
+			continue
+		}
+		backups[id] = backup
+	}
+	return backups
+}
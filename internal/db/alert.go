@@ -0,0 +1,105 @@
+package db
+
+import (
+	bolt "github.com/etcd-io/bbolt"
+)
+
+// InsertAlert inserts an alert rule
+func InsertAlert(alert *Alert) error {
+	return cipo.Insert(nil, alert)
+}
+
+// UpdateAlert updates an alert rule
+func UpdateAlert(alert *Alert) error {
+	return cipo.Update(alert.ID, alert)
+}
+
+// GetAlert returns an alert rule with the ID parameter
+func GetAlert(ID uint64) (*Alert, error) {
+	alert := &Alert{}
+	err := cipo.Get(ID, alert)
+	return alert, err
+}
+
+// GetAlerts returns all the alert rules
+func GetAlerts() ([]Alert, error) {
+	alert := Alert{}
+	alerts := []Alert{}
+	err := cipo.Iterate(&alert.ID, &alert, func() error {
+		alerts = append(alerts, alert)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// GetAlertsByCollectionID returns the collection's alert rules
+func GetAlertsByCollectionID(collectionID string) ([]Alert, error) {
+	alert := Alert{}
+	alerts := []Alert{}
+	err := cipo.Iterate(&alert.ID, &alert, func() error {
+		if alert.CollectionID == collectionID {
+			alerts = append(alerts, alert)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// DeleteAlert deletes an alert rule with its history
+func DeleteAlert(alert *Alert) error {
+	return cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		return deleteAlertTx(tx, alert)
+	})
+}
+
+func deleteAlertTx(tx *bolt.Tx, alert *Alert) error {
+	if err := cipo.DeleteTx(tx, alert.ID, alert); err != nil {
+		return err
+	}
+	ID := uint64(0)
+	v := AlertEvent{}
+	return cipo.IterateTx(tx, &ID, &v, func() error {
+		if v.AlertID == alert.ID {
+			return cipo.DeleteTx(tx, ID, &v)
+		}
+		return nil
+	})
+}
+
+func deleteAlertsByCollectionIDTx(tx *bolt.Tx, collectionID string) error {
+	ID := uint64(0)
+	v := Alert{}
+	return cipo.IterateTx(tx, &ID, &v, func() error {
+		if v.CollectionID == collectionID {
+			return deleteAlertTx(tx, &v)
+		}
+		return nil
+	})
+}
+
+// InsertAlertEvent inserts an alert history entry
+func InsertAlertEvent(event *AlertEvent) error {
+	return cipo.Insert(nil, event)
+}
+
+// GetAlertEvents returns the alert's history, the newest first
+func GetAlertEvents(alertID uint64) ([]AlertEvent, error) {
+	event := AlertEvent{}
+	events := []AlertEvent{}
+	err := cipo.Iterate(&event.ID, &event, func() error {
+		if event.AlertID == alertID {
+			events = append([]AlertEvent{event}, events...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
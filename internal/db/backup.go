@@ -0,0 +1,628 @@
+package db
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "github.com/etcd-io/bbolt"
+)
+
+// BackupShardT is the verification result of a backed up shard
+type BackupShardT struct {
+	ID        string    `json:"id"`
+	Sessions  int       `json:"sessions"`
+	Pageviews int       `json:"pageviews"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// BackupCollectionT is the verification result of a backed up collection
+type BackupCollectionT struct {
+	ID       string         `json:"id"`
+	Name     string         `json:"name"`
+	Sessions int            `json:"sessions"`
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	Shards   []BackupShardT `json:"shards"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// BackupReportT is the verification result of a backup
+type BackupReportT struct {
+	Dir         string              `json:"dir"`
+	Users       int                 `json:"users"`
+	Collections []BackupCollectionT `json:"collections"`
+	Errors      []string            `json:"errors"`
+}
+
+// OK returns whether the backup is consistent
+func (r *BackupReportT) OK() bool {
+	if len(r.Errors) > 0 {
+		return false
+	}
+	for _, c := range r.Collections {
+		if c.Error != "" {
+			return false
+		}
+		for _, s := range c.Shards {
+			if s.Error != "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func openReadOnly(file string) (*bolt.DB, error) {
+	if _, err := os.Stat(file); err != nil {
+		return nil, err
+	}
+	return bolt.Open(file, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+}
+
+func checkTx(tx *bolt.Tx) error {
+	errs := []string{}
+	for err := range tx.Check() {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("consistency check failed: %v", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func countKeys(tx *bolt.Tx, bucket []byte) int {
+	b := tx.Bucket(bucket)
+	if b == nil {
+		return 0
+	}
+	return b.Stats().KeyN
+}
+
+func readBackupCollections(file string) ([]Collection, int, error) {
+	bdb, err := openReadOnly(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer bdb.Close()
+	collections := []Collection{}
+	users := 0
+	err = bdb.View(func(tx *bolt.Tx) error {
+		if err := checkTx(tx); err != nil {
+			return err
+		}
+		users = countKeys(tx, BUser)
+		b := tx.Bucket(BCollection)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			c := Collection{}
+			if err := protoDecode(v, &c); err != nil {
+				return err
+			}
+			collections = append(collections, c)
+			return nil
+		})
+	})
+	return collections, users, err
+}
+
+func verifyBackupShard(file string) BackupShardT {
+	shard := BackupShardT{ID: strings.TrimSuffix(filepath.Base(file), ".bolt")}
+	bdb, err := openReadOnly(file)
+	if err != nil {
+		shard.Error = err.Error()
+		return shard
+	}
+	defer bdb.Close()
+	err = bdb.View(func(tx *bolt.Tx) error {
+		if err := checkTx(tx); err != nil {
+			return err
+		}
+		shard.Sessions = countKeys(tx, BSession)
+		shard.Pageviews = countKeys(tx, BPageview)
+		b := tx.Bucket(BSession)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		if k, _ := c.First(); k != nil {
+			t, err := unmarshalTime(k)
+			if err != nil {
+				return err
+			}
+			shard.From = t
+		}
+		if k, _ := c.Last(); k != nil {
+			t, err := unmarshalTime(k)
+			if err != nil {
+				return err
+			}
+			shard.To = t
+		}
+		return nil
+	})
+	if err != nil {
+		shard.Error = err.Error()
+	}
+	return shard
+}
+
+func verifyBackupCollection(dir string, c *BackupCollectionT) {
+	files, err := filepath.Glob(filepath.Join(dir, c.ID, "*.bolt"))
+	if err != nil {
+		c.Error = err.Error()
+		return
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		shard := verifyBackupShard(f)
+		c.Shards = append(c.Shards, shard)
+		c.Sessions += shard.Sessions
+		if shard.Sessions == 0 {
+			continue
+		}
+		if c.From.IsZero() || shard.From.Before(c.From) {
+			c.From = shard.From
+		}
+		if shard.To.After(c.To) {
+			c.To = shard.To
+		}
+	}
+}
+
+// openBackup returns the dir of the backup, a tar or tar.gz archive is extracted
+// into a temporary dir, which is removed by the returned cleanup
+func openBackup(path string) (string, func(), error) {
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() {
+		return path, func() {}, nil
+	}
+	tmp, err := ioutil.TempDir("", "rightana-backup-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+	if err := extractBackup(path, tmp); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("can't extract %v: %v", path, err)
+	}
+	return tmp, cleanup, nil
+}
+
+// extractBackup extracts the backup archive's files into the dir, the gzip compression is detected
+func extractBackup(file string, dir string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid file name in the archive: %v", header.Name)
+		}
+		target := filepath.Join(dir, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			if err := extractFile(tr, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractFile(r io.Reader, target string) error {
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// VerifyBackup opens every file of the backup dir or archive read-only and checks their consistency
+func VerifyBackup(path string) (*BackupReportT, error) {
+	dir, cleanup, err := openBackup(path)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	report, err := verifyBackupDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	report.Dir = filepath.Clean(path)
+	return report, nil
+}
+
+func verifyBackupDir(dir string) (*BackupReportT, error) {
+	report := &BackupReportT{Dir: dir, Collections: []BackupCollectionT{}, Errors: []string{}}
+	collections, users, err := readBackupCollections(filepath.Join(dir, filename))
+	if err != nil {
+		return nil, fmt.Errorf("can't read the main db backup: %v", err)
+	}
+	report.Users = users
+	known := map[string]bool{}
+	for _, c := range collections {
+		known[c.ID] = true
+		bc := BackupCollectionT{ID: c.ID, Name: c.Name, Shards: []BackupShardT{}}
+		verifyBackupCollection(dir, &bc)
+		report.Collections = append(report.Collections, bc)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() && !known[e.Name()] {
+			report.Errors = append(report.Errors, fmt.Sprintf("%v is not a collection in the main db", e.Name()))
+		}
+	}
+	return report, nil
+}
+
+// RestoreOptionsT selects what should be restored from a backup
+type RestoreOptionsT struct {
+	CollectionID string
+	ShardID      string
+	Force        bool
+}
+
+// RestoreBackup restores a verified backup dir or archive into the data dir, the server must be stopped.
+// The replaced files are kept with a .before-restore suffix.
+func RestoreBackup(path string, dataDir string, options RestoreOptionsT) error {
+	dataDir = filepath.Clean(dataDir)
+	if options.ShardID != "" && options.CollectionID == "" {
+		return fmt.Errorf("restoring a shard needs the collection")
+	}
+	dir, cleanup, err := openBackup(path)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	report, err := verifyBackupDir(dir)
+	if err != nil {
+		return err
+	}
+	if !report.OK() {
+		return fmt.Errorf("the backup is inconsistent: %v", report.Errors)
+	}
+	if err := os.MkdirAll(dataDir, os.ModePerm); err != nil {
+		return err
+	}
+	liveFile := filepath.Join(dataDir, filename)
+	live, err := liveCollectionIDs(liveFile)
+	if err != nil {
+		return err
+	}
+	suffix := ".before-restore-" + time.Now().Format("20060102150405")
+
+	if options.CollectionID == "" {
+		if live != nil && !options.Force {
+			return fmt.Errorf("%v exists, use force to replace the whole instance", liveFile)
+		}
+		if err := replaceFile(filepath.Join(dir, filename), liveFile, suffix); err != nil {
+			return err
+		}
+		for _, c := range report.Collections {
+			if err := restoreCollection(dir, dataDir, c.ID, suffix); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !backupHasCollection(report, options.CollectionID) {
+		return fmt.Errorf("collection %v is not in the backup", options.CollectionID)
+	}
+	if !live[options.CollectionID] {
+		return fmt.Errorf("collection %v is not in the live db, restore the whole instance instead", options.CollectionID)
+	}
+	if options.ShardID == "" {
+		return restoreCollection(dir, dataDir, options.CollectionID, suffix)
+	}
+	name := options.ShardID + ".bolt"
+	return replaceFile(filepath.Join(dir, options.CollectionID, name), filepath.Join(dataDir, options.CollectionID, name), suffix)
+}
+
+func backupHasCollection(report *BackupReportT, collectionID string) bool {
+	for _, c := range report.Collections {
+		if c.ID == collectionID {
+			return true
+		}
+	}
+	return false
+}
+
+// liveCollectionIDs returns the collections of the live db, nil if it doesn't exist.
+// It fails when the db is locked by a running server.
+func liveCollectionIDs(file string) (map[string]bool, error) {
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return nil, nil
+	}
+	bdb, err := bolt.Open(file, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("can't open %v, stop the server first: %v", file, err)
+	}
+	defer bdb.Close()
+	ids := map[string]bool{}
+	err = bdb.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(BCollection)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			ids[string(k)] = true
+			return nil
+		})
+	})
+	return ids, err
+}
+
+func restoreCollection(dir string, dataDir string, collectionID string, suffix string) error {
+	target := filepath.Join(dataDir, collectionID)
+	if _, err := os.Stat(target); err == nil {
+		if err := os.Rename(target, target+suffix); err != nil {
+			return err
+		}
+	}
+	files, err := filepath.Glob(filepath.Join(dir, collectionID, "*.bolt"))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(target, os.ModePerm); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := copyFile(f, filepath.Join(target, filepath.Base(f))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replaceFile(src string, dst string, suffix string) error {
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		if err := os.Rename(dst, dst+suffix); err != nil {
+			return err
+		}
+	}
+	return copyFile(src, dst)
+}
+
+// copyFile copies through a temporary file, so the dst is never half written
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// RunBackupArchive creates a tar.gz archive backup
+func RunBackupArchive(file string) error {
+	part := file + ".part"
+	f, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := WriteBackup(f, true); err != nil {
+		f.Close()
+		os.Remove(part)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(part)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(part)
+		return err
+	}
+	return os.Rename(part, file)
+}
+
+// WriteBackup streams a tar snapshot of the main database and all the shards,
+// every file is written from its own read transaction
+func WriteBackup(w io.Writer, compress bool) error {
+	collections, err := GetCollections()
+	if err != nil {
+		return err
+	}
+	return writeTar(w, compress, func(tw *tar.Writer) error {
+		if err := writeMainDBToTar(tw); err != nil {
+			return err
+		}
+		for _, c := range collections {
+			if err := writeShardsToTar(tw, c.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeMainDBToTar streams the main database straight into the tar entry, nothing is staged
+// on disk. The read transaction stays open until the client has downloaded the main database,
+// meanwhile the freed pages can't be reused, so a slow client makes the database file grow
+func writeMainDBToTar(tw *tar.Writer) error {
+	return cipo.Bolt().View(func(tx *bolt.Tx) error {
+		return writeTxToTar(tw, filename, tx)
+	})
+}
+
+// WriteCollectionBackup streams a tar snapshot of the collection's shards
+func WriteCollectionBackup(w io.Writer, collectionID string, compress bool) error {
+	return writeTar(w, compress, func(tw *tar.Writer) error {
+		return writeShardsToTar(tw, collectionID)
+	})
+}
+
+func writeTar(w io.Writer, compress bool, fn func(tw *tar.Writer) error) error {
+	var gw *gzip.Writer
+	if compress {
+		gw = gzip.NewWriter(w)
+		w = gw
+	}
+	tw := tar.NewWriter(w)
+	if err := fn(tw); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gw != nil {
+		return gw.Close()
+	}
+	return nil
+}
+
+func writeShardsToTar(tw *tar.Writer, collectionID string) error {
+	sdb, err := getBoltShardDB(collectionID)
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     collectionID + "/",
+		Mode:     0700,
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return sdb.ViewShards(func(id string, tx *bolt.Tx) error {
+		return writeTxToTar(tw, collectionID+"/"+id+".bolt", tx)
+	})
+}
+
+func writeTxToTar(tw *tar.Writer, name string, tx *bolt.Tx) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0600,
+		Size:     tx.Size(),
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tx.WriteTo(tw)
+	return err
+}
+
+// InsertBackupRun inserts a backup history entry
+func InsertBackupRun(run *BackupRun) error {
+	return cipo.Insert(nil, run)
+}
+
+// UpdateBackupRun updates a backup history entry
+func UpdateBackupRun(run *BackupRun) error {
+	return cipo.Update(run.ID, run)
+}
+
+// GetBackupRuns returns the backup's history, the newest first
+func GetBackupRuns(backupID string) ([]BackupRun, error) {
+	run := BackupRun{}
+	runs := []BackupRun{}
+	err := cipo.Iterate(&run.ID, &run, func() error {
+		if run.BackupID == backupID {
+			runs = append([]BackupRun{run}, runs...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// PruneBackupRuns keeps only the newest keep history entries of the backup
+func PruneBackupRuns(backupID string, keep int) error {
+	runs, err := GetBackupRuns(backupID)
+	if err != nil {
+		return err
+	}
+	if len(runs) <= keep {
+		return nil
+	}
+	return cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		for _, run := range runs[keep:] {
+			if err := cipo.DeleteTx(tx, run.ID, &run); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -1,7 +1,9 @@
 package db
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path"
@@ -16,40 +18,105 @@ import (
 	"github.com/soyersoyer/rightana/internal/db/shardbolt"
 )
 
-type shardMap map[string]*shardbolt.DB
+type shardMap map[string]ShardStore
 
 var (
 	basedir  = "data/"
 	filename = "rightana.bolt"
+	storage  = StorageBolt
+	tempDir  = ""
 
-	cipo     *cipobolt.DB
-	shardDBs = atomic.Value{}
-	dbMutex  sync.Mutex
+	cipo      *cipobolt.DB
+	shardDBs  = atomic.Value{}
+	dbMutex   sync.Mutex
+	shardPool = shardbolt.NewPool(0)
+	// closed is set by the Close, guarded by the dbMutex
+	closed bool
 
 	// ErrKeyExists is an error what you can get if the key exists but it shouldn't
 	ErrKeyExists = cipobolt.ErrKeyExists
 	// ErrKeyNotExists is an error what you can get if the key not exists but it should
 	ErrKeyNotExists = cipobolt.ErrKeyNotExists
+	// ErrClosed is returned after the Close, the shard databases aren't reopened
+	ErrClosed = errors.New("the database is closed")
 )
 
-func map2Month(key []byte) string {
-	t, err := unmarshalTime(key)
-	if err != nil {
-		panic(err)
-	}
-	return t.Format("2006-01")
-}
-
 // InitDatabase initializes the databases, creates the directories if necessary
+// With the memory storage the main database is created in a temporary directory
 func InitDatabase(basedirParam string) {
+	if storage == StorageMemory {
+		dir, err := ioutil.TempDir("", "rightana-")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		tempDir, basedirParam = dir, dir
+	}
 	basedir = path.Clean(basedirParam) + "/"
 	os.MkdirAll(basedir, os.ModePerm)
+	_, statErr := os.Stat(basedir + filename)
 	bdb, err := bolt.Open(basedir+filename, 0600, nil)
 	if err != nil {
 		log.Fatalln(err)
 	}
 	cipo = cipobolt.Open(bdb, protoEncode, protoDecode, bucketName)
+	dbMutex.Lock()
+	shardDBs.Store(shardMap{})
+	closed = false
+	dbMutex.Unlock()
+	if os.IsNotExist(statErr) {
+		if err := bdb.Update(setSchemaVersionTx); err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// SetStorage selects the session and pageview store before the InitDatabase,
+// the memory store keeps nothing after the Close
+func SetStorage(s string) error {
+	if s != StorageBolt && s != StorageMemory {
+		return fmt.Errorf("invalid storage: %v", s)
+	}
+	storage = s
+	return nil
+}
+
+// SetMaxOpenShards limits the open shard files, the least recently used ones are closed, 0 means unlimited
+func SetMaxOpenShards(max int) {
+	shardPool.SetMax(max)
+}
+
+// GetShardPoolStats returns the open shard files' statistics
+func GetShardPoolStats() shardbolt.PoolStats {
+	return shardPool.Stats()
+}
+
+// Close closes all the shard databases and the main database,
+// the shard databases aren't reopened until the next InitDatabase
+func Close() error {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	closed = true
+	var errs []error
+	dbs, _ := shardDBs.Load().(shardMap)
+	for _, sdb := range dbs {
+		errs = append(errs, sdb.Close()...)
+	}
 	shardDBs.Store(shardMap{})
+	if cipo != nil {
+		if err := cipo.Bolt().Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if tempDir != "" {
+		if err := os.RemoveAll(tempDir); err != nil {
+			errs = append(errs, err)
+		}
+		tempDir = ""
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("can't close the databases %v", errs)
+	}
+	return nil
 }
 
 // RunBackup copies the database to the dir
@@ -70,7 +137,7 @@ func RunBackup(dir string) error {
 	}
 	gerrs := []error{}
 	for _, c := range collections {
-		shardDB, err := getShardDB(c.ID)
+		shardDB, err := getBoltShardDB(c.ID)
 		if err != nil {
 			log.Println("can't open shard db for backup:", c.ID, "cause:", err)
 			gerrs = append(gerrs, err)
@@ -92,12 +159,38 @@ func RunBackup(dir string) error {
 
 // UpdateUser updates an user
 func UpdateUser(user *User) error {
-	return cipo.Update(user.ID, user)
+	return cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		old := &User{}
+		if err := cipo.GetTx(tx, user.ID, old); err != nil {
+			return err
+		}
+		if err := cipo.UpdateTx(tx, user.ID, user); err != nil {
+			return err
+		}
+		return indexUserTx(tx, old, user)
+	})
+}
+
+// SetFields re-reads the record with the ID into value and stores it with only the set's changes,
+// so the concurrent updates of its other fields aren't overwritten
+func SetFields(ID uint64, value interface{}, set func()) error {
+	return cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		if err := cipo.GetTx(tx, ID, value); err != nil {
+			return err
+		}
+		set()
+		return cipo.UpdateTx(tx, ID, value)
+	})
 }
 
 // InsertUser inserts an user
 func InsertUser(user *User) error {
-	return cipo.Insert(nil, user)
+	return cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		if err := cipo.InsertTx(tx, nil, user); err != nil {
+			return err
+		}
+		return indexUserTx(tx, nil, user)
+	})
 }
 
 // GetUsers returns the users
@@ -151,48 +244,46 @@ func GetUserByID(ID uint64) (*User, error) {
 
 // GetUserByEmail returns an user with the email parameter
 func GetUserByEmail(email string) (*User, error) {
-	user := User{}
-	ret := User{}
-	err := cipo.Iterate(&user.ID, &user, func() error {
-		if user.Email == email {
-			ret = user
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	if ret.ID == 0 {
-		return nil, ErrKeyNotExists
-	}
-	return &ret, err
+	return getUserByIndex(BUserByEmail, email)
 }
 
 // GetUserByName returns an user with the name parameter
 func GetUserByName(name string) (*User, error) {
-	user := User{}
-	ret := User{}
-	err := cipo.Iterate(&user.ID, &user, func() error {
-		if user.Name == name {
-			ret = user
+	return getUserByIndex(BUserByName, name)
+}
+
+func getUserByIndex(bucket []byte, key string) (*User, error) {
+	user := &User{}
+	err := cipo.Bolt().View(func(tx *bolt.Tx) error {
+		id := getIndexTx(tx, bucket, []byte(key))
+		if id == nil {
+			return ErrKeyNotExists
 		}
-		return nil
+		ID, err := unmarshaluint64(id)
+		if err != nil {
+			return err
+		}
+		return cipo.GetTx(tx, ID, user)
 	})
 	if err != nil {
 		return nil, err
 	}
-	if ret.ID == 0 {
-		return nil, ErrKeyNotExists
-	}
-	return &ret, err
+	return user, nil
 }
 
 // DeleteUser deletes an user
 func DeleteUser(user *User) error {
 	return cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		old := &User{}
+		if err := cipo.GetTx(tx, user.ID, old); err != nil {
+			return err
+		}
 		if err := cipo.DeleteTx(tx, user.ID, user); err != nil {
 			return err
 		}
+		if err := unindexUserTx(tx, old); err != nil {
+			return err
+		}
 
 		if err := deleteAuthTokensByUserIDTx(tx, user.ID); err != nil {
 			return err
@@ -265,16 +356,30 @@ func DeleteAuthToken(id string) error {
 
 // InsertCollection inserts a new collection
 func InsertCollection(collection *Collection) error {
-	return cipo.Insert(collection.ID, collection)
+	return cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		if err := cipo.InsertTx(tx, collection.ID, collection); err != nil {
+			return err
+		}
+		return indexCollectionTx(tx, nil, collection)
+	})
 }
 
 // UpdateCollection updates a new collection
 func UpdateCollection(collection *Collection) error {
-	return cipo.Update(collection.ID, collection)
+	return cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		return updateCollectionTx(tx, collection)
+	})
 }
 
 func updateCollectionTx(tx *bolt.Tx, collection *Collection) error {
-	return cipo.UpdateTx(tx, collection.ID, collection)
+	old := &Collection{}
+	if err := cipo.GetTx(tx, collection.ID, old); err != nil {
+		return err
+	}
+	if err := cipo.UpdateTx(tx, collection.ID, collection); err != nil {
+		return err
+	}
+	return indexCollectionTx(tx, old, collection)
 }
 
 // GetCollection returns a collection with the id parameter
@@ -284,25 +389,20 @@ func GetCollection(id string) (*Collection, error) {
 	return &collection, err
 }
 
-// TODO: adding indexes
-
 // GetCollectionByName returns a collection
 func GetCollectionByName(ownerID uint64, name string) (*Collection, error) {
-	collection := Collection{}
-	ret := Collection{}
-	err := cipo.Iterate(&collection.ID, &collection, func() error {
-		if collection.OwnerID == ownerID && collection.Name == name {
-			ret = collection
+	collection := &Collection{}
+	err := cipo.Bolt().View(func(tx *bolt.Tx) error {
+		id := getIndexTx(tx, BCollectionByName, collectionNameKey(ownerID, name))
+		if id == nil {
+			return ErrKeyNotExists
 		}
-		return nil
+		return cipo.GetTx(tx, string(id), collection)
 	})
 	if err != nil {
 		return nil, err
 	}
-	if ret.OwnerID == 0 {
-		return nil, ErrKeyNotExists
-	}
-	return &ret, err
+	return collection, nil
 }
 
 // DeleteCollection deletes a collection
@@ -313,9 +413,25 @@ func DeleteCollection(collection *Collection) error {
 }
 
 func deleteCollectionTx(tx *bolt.Tx, collection *Collection) error {
+	old := &Collection{}
+	if err := cipo.GetTx(tx, collection.ID, old); err != nil {
+		return err
+	}
 	if err := cipo.DeleteTx(tx, collection.ID, collection); err != nil {
 		return err
 	}
+	if err := unindexCollectionTx(tx, old); err != nil {
+		return err
+	}
+	if err := deleteAlertsByCollectionIDTx(tx, collection.ID); err != nil {
+		return err
+	}
+	if err := deleteWebhooksByCollectionIDTx(tx, collection.ID); err != nil {
+		return err
+	}
+	if err := deleteErasuresByCollectionIDTx(tx, collection.ID); err != nil {
+		return err
+	}
 	return deleteShardDB(collection.ID)
 }
 
@@ -421,7 +537,7 @@ func GetSession(collectionID string, key []byte) (*Session, error) {
 	return session, err
 }
 
-func getShardDB(collectionID string) (*shardbolt.DB, error) {
+func getShardDB(collectionID string) (ShardStore, error) {
 	dbs := shardDBs.Load().(shardMap)
 	db, ok := dbs[collectionID]
 	if !ok {
@@ -433,12 +549,19 @@ func getShardDB(collectionID string) (*shardbolt.DB, error) {
 			log.Println(db2)
 			return db2, nil
 		}
+		if closed {
+			return nil, ErrClosed
+		}
 
 		if collectionID == "" {
 			return nil, fmt.Errorf("collectionId is empty")
 		}
+		granularity := ""
+		if collection, err := GetCollection(collectionID); err == nil {
+			granularity = collection.ShardGranularity
+		}
 		var err error
-		db, err = shardbolt.Open(basedir+collectionID, map2Month, 0666, nil)
+		db, err = newShardStore(basedir+collectionID, granularity)
 		if err != nil {
 			return nil, err
 		}
@@ -452,7 +575,35 @@ func getShardDB(collectionID string) (*shardbolt.DB, error) {
 	return db, nil
 }
 
+func newShardStore(dir string, granularity string) (ShardStore, error) {
+	if storage == StorageMemory {
+		return newMemStore(getShardMapFn(granularity)), nil
+	}
+	sdb, err := openShardDB(dir, granularity)
+	if err != nil {
+		return nil, err
+	}
+	return boltStore{sdb}, nil
+}
+
+func openShardDB(dir string, granularity string) (*shardbolt.DB, error) {
+	return shardbolt.Open(dir, getShardMapFn(granularity), 0666, &shardbolt.Options{
+		FillPercent: 0.9,
+		OnCreate:    setSchemaVersionTx,
+		Pool:        shardPool,
+		ShardRange:  getShardRange,
+	})
+}
+
 func deleteShardDB(collectionID string) error {
+	if err := closeShardDB(collectionID); err != nil {
+		return err
+	}
+	return os.RemoveAll(basedir + collectionID)
+}
+
+// closeShardDB closes the collection's shards, they will be reopened on demand
+func closeShardDB(collectionID string) error {
 	sdb, err := getShardDB(collectionID)
 	if err != nil {
 		return err
@@ -462,10 +613,9 @@ func deleteShardDB(collectionID string) error {
 		log.Println(errs)
 		return fmt.Errorf("can't close the shard db %v", errs)
 	}
-	if err := os.RemoveAll(basedir + collectionID); err != nil {
-		return err
-	}
 
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
 	dbs := shardDBs.Load().(shardMap)
 	dbsCopy := shardMap{}
 	for k, v := range dbs {
@@ -476,6 +626,9 @@ func deleteShardDB(collectionID string) error {
 	return nil
 }
 
+// sessionKeyLength is the length of the session keys: the time and the id
+const sessionKeyLength = 12
+
 // GetKey returns the databse key based on time and id
 func GetKey(t time.Time, id uint32) []byte {
 	return append(marshalTime(t), marshal(id)...)
@@ -519,7 +672,7 @@ func GetTimeFromPVKey(key []byte) time.Time {
 }
 
 // ShardUpdate runs the fn in a shard
-func ShardUpdate(collectionID string, fn func(tx *shardbolt.MultiTx) error) error {
+func ShardUpdate(collectionID string, fn func(tx ShardTx) error) error {
 	sdb, err := getShardDB(collectionID)
 	if err != nil {
 		return err
@@ -538,13 +691,14 @@ func ShardUpsert(collectionID string, key []byte, v proto.Message) error {
 	if err != nil {
 		return err
 	}
-	return sdb.Update(func(tx *shardbolt.MultiTx) error {
+	return sdb.Update(func(tx ShardTx) error {
 		return tx.Put(bb, key, vb)
 	})
 }
 
 // ShardUpsertBatch upsert a value into shards, but not in a separated transaction
 func ShardUpsertBatch(collectionID string, key []byte, v proto.Message) error {
+	defer shardUpsertDuration.ObserveSince(time.Now())
 	sdb, err := getShardDB(collectionID)
 	if err != nil {
 		return err
@@ -558,7 +712,7 @@ func ShardUpsertBatch(collectionID string, key []byte, v proto.Message) error {
 }
 
 // ShardUpsertTx upsert a value into shards in a transaction
-func ShardUpsertTx(tx *shardbolt.MultiTx, key []byte, v proto.Message) error {
+func ShardUpsertTx(tx ShardTx, key []byte, v proto.Message) error {
 	bb := bucketName(v)
 	vb, err := proto.Marshal(v)
 	if err != nil {
@@ -1,10 +1,19 @@
 package db
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
+
+	bolt "github.com/etcd-io/bbolt"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -59,6 +68,85 @@ func TestUserCreate(t *testing.T) {
 	}
 }
 
+func TestSetFields(t *testing.T) {
+	user, err := GetUserByEmail(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := *user
+	user.IsAdmin = true
+	if err := UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	stored := &User{}
+	if err := SetFields(stale.ID, stored, func() { stored.DigestSentAt = 42 }); err != nil {
+		t.Fatal(err)
+	}
+	user, err = GetUserByID(stale.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.IsAdmin || user.DigestSentAt != 42 {
+		t.Error("the other fields are overwritten or the field isn't set", user)
+	}
+	user.IsAdmin = false
+	if err := UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetFields(math.MaxUint64, &Alert{}, func() {}); err == nil {
+		t.Error("a missing record is set")
+	}
+}
+
+func TestWebhookQueue(t *testing.T) {
+	webhook := &Webhook{URL: "http://queue", CollectionID: "webhooks"}
+	if err := InsertWebhook(webhook); err != nil {
+		t.Fatal(err)
+	}
+	other := &Webhook{URL: "http://other", CollectionID: "webhooks"}
+	if err := InsertWebhook(other); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteWebhook(other)
+	deliveries := []*WebhookDelivery{}
+	for i := 0; i < 4; i++ {
+		deliveries = append(deliveries, &WebhookDelivery{WebhookID: webhook.ID, NextAttempt: int64(40 - i)})
+	}
+	deliveries = append(deliveries, &WebhookDelivery{WebhookID: other.ID, NextAttempt: 10})
+	if dropped, err := InsertWebhookDeliveries(deliveries, 3); err != nil || dropped != 1 {
+		t.Fatal("the queue isn't capped", dropped, err)
+	}
+
+	due, err := GetDueWebhookDeliveries(39, 2)
+	if err != nil || len(due) != 2 || due[0].WebhookID != other.ID || due[1].NextAttempt != 38 {
+		t.Fatal("the earliest due deliveries aren't returned", due, err)
+	}
+	due[1].NextAttempt = 100
+	if err := UpdateWebhookDelivery(&due[1]); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := GetDueWebhookDeliveries(99, 10); err != nil || len(due) != 3 {
+		t.Error("the rescheduled delivery is still due", due, err)
+	}
+	if err := DeleteWebhookDelivery(&due[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteWebhook(webhook); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := GetDueWebhookDeliveries(100, 10); err != nil || len(due) != 0 {
+		t.Error("the deleted webhook's deliveries are queued", due, err)
+	}
+	cipo.Bolt().View(func(tx *bolt.Tx) error {
+		if tx.Bucket(BDeliveryByWebhook).Stats().KeyN != 0 || tx.Bucket(BDeliveryByNextAttempt).Stats().KeyN != 0 {
+			t.Error("the index entries remained")
+		}
+		return nil
+	})
+}
+
 func TestCollectionCreate(t *testing.T) {
 	if err := InsertCollection(&collection); err != nil {
 		t.Error(err)
@@ -77,6 +165,55 @@ func TestCollectionGetByName(t *testing.T) {
 	}
 }
 
+func TestIndexes(t *testing.T) {
+	user, err := GetUserByEmail(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Name = "soyer"
+	user.Email = "changed@irl.hu"
+	if err := UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetUserByEmail(email); err != ErrKeyNotExists {
+		t.Error("the old email should be removed from the index", err)
+	}
+	if u, err := GetUserByName("soyer"); err != nil || u.ID != user.ID {
+		t.Error(u, err)
+	}
+	if err := InsertUser(&User{Email: "changed@irl.hu", Name: "other"}); err != ErrKeyExists {
+		t.Error("the email should be unique", err)
+	}
+	user.Email = email
+	if err := UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	err = cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		for _, b := range indexBuckets {
+			if err := tx.DeleteBucket(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetUserByEmail(email); err != ErrKeyNotExists {
+		t.Error(err)
+	}
+	if err := RebuildIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := GetUserByEmail(email); err != nil || u.ID != user.ID {
+		t.Error(u, err)
+	}
+	if c, err := GetCollectionByName(collection.OwnerID, collection.Name); err != nil || c.ID != collectionID {
+		t.Error(c, err)
+	}
+}
+
 func TestSeed(t *testing.T) {
 	Seed(from, to, collection.ID, 100000)
 }
@@ -104,3 +241,1031 @@ func TestStat(t *testing.T) {
 	elapsed = time.Since(start)
 	log.Printf("stat time: %s", elapsed)
 }
+
+func TestSumsPaging(t *testing.T) {
+	m := map[string]int{"a": 1, "b": 3, "c": 2, "d": 2}
+
+	sums := getSums(&m, getPaging(&CollectionDataInputT{Limit: 2}))
+	if len(sums) != 2 || sums[0].Name != "b" || sums[1].Name != "c" {
+		t.Error(sums)
+	}
+	if sums[0].Percent != 0.375 {
+		t.Error(sums[0])
+	}
+
+	sums = getSums(&m, getPaging(&CollectionDataInputT{Limit: 2, Offset: 2}))
+	if len(sums) != 2 || sums[0].Name != "d" || sums[1].Name != "a" {
+		t.Error(sums)
+	}
+
+	sums = getSums(&m, getPaging(&CollectionDataInputT{Sort: "name", Order: "desc", Limit: 1}))
+	if len(sums) != 1 || sums[0].Name != "d" {
+		t.Error(sums)
+	}
+
+	sums = getSums(&m, getPaging(&CollectionDataInputT{Offset: 10}))
+	if len(sums) != 0 {
+		t.Error(sums)
+	}
+}
+
+func TestSessionsPaging(t *testing.T) {
+	input := CollectionDataInputT{
+		From:  from,
+		To:    to,
+		Limit: 100,
+	}
+	first, total, err := GetSessions(&collection, &input)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(first) != 100 || total < 100 {
+		t.Error(len(first), total)
+	}
+
+	input.Cursor = first[len(first)-1].Key
+	second, total2, err := GetSessions(&collection, &input)
+	if err != nil {
+		t.Error(err)
+	}
+	if total2 != total || len(second) == 0 {
+		t.Error(len(second), total2, total)
+	}
+	if second[0].Key == first[len(first)-1].Key || second[0].Begin < first[len(first)-1].Begin {
+		t.Error(second[0], first[len(first)-1])
+	}
+}
+
+func TestClose(t *testing.T) {
+	key := GetKey(time.Now(), 42)
+	if err := ShardUpsertBatch(collectionID, key, &Session{Hostname: "close.org"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetUserByEmail(email); err == nil {
+		t.Error("the main db should be closed")
+	}
+	if err := ShardUpsertBatch(collectionID, key, &Session{Hostname: "late.org"}); err != ErrClosed {
+		t.Error("the shard db is reopened after the close", err)
+	}
+
+	InitDatabase(dir)
+	session, err := GetSession(collectionID, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Hostname != "close.org" {
+		t.Error(session)
+	}
+}
+
+func TestVerifyAndRestoreBackup(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "rightana-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	backupDir := filepath.Join(tmp, "backup")
+	if err := RunBackup(backupDir); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := VerifyBackup(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || len(report.Collections) != 1 || report.Collections[0].Sessions == 0 {
+		t.Error(report)
+	}
+
+	if err := RestoreBackup(backupDir, dir, RestoreOptionsT{CollectionID: collectionID}); err == nil {
+		t.Error("restoring into a locked db should fail")
+	}
+
+	restoreDir := filepath.Join(tmp, "restore")
+	if err := RestoreBackup(backupDir, restoreDir, RestoreOptionsT{}); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := VerifyBackup(restoreDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restored.OK() || restored.Collections[0].Sessions != report.Collections[0].Sessions {
+		t.Error(restored)
+	}
+	if err := RestoreBackup(backupDir, restoreDir, RestoreOptionsT{}); err == nil {
+		t.Error("replacing an instance without force should fail")
+	}
+	if err := RestoreBackup(backupDir, restoreDir, RestoreOptionsT{CollectionID: collectionID, ShardID: report.Collections[0].Shards[0].ID}); err != nil {
+		t.Error(err)
+	}
+
+	archive := filepath.Join(tmp, "backup.tar.gz")
+	if err := RunBackupArchive(archive); err != nil {
+		t.Fatal(err)
+	}
+	archived, err := VerifyBackup(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !archived.OK() || archived.Dir != archive || archived.Collections[0].Sessions != report.Collections[0].Sessions {
+		t.Error(archived)
+	}
+	archiveRestoreDir := filepath.Join(tmp, "archive-restore")
+	if err := RestoreBackup(archive, archiveRestoreDir, RestoreOptionsT{}); err != nil {
+		t.Fatal(err)
+	}
+	if restored, err := VerifyBackup(archiveRestoreDir); err != nil || !restored.OK() || restored.Collections[0].Sessions != report.Collections[0].Sessions {
+		t.Error(restored, err)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := ExportCollection(buf, &collection); err != nil {
+		t.Fatal(err)
+	}
+	export := buf.Bytes()
+
+	er, err := NewExportReader(bytes.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if er.Header.Version != ExportVersion || er.Header.CollectionID != collectionID || er.Header.Name != collection.Name {
+		t.Error(er.Header)
+	}
+	var firstSession, lastPageview *ExportRecord
+	sessions, pageviews := 0, 0
+	for {
+		record, err := er.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Session != nil {
+			if firstSession == nil {
+				firstSession = record
+			}
+			sessions++
+		} else {
+			lastPageview = record
+			pageviews++
+		}
+	}
+	if sessions == 0 || pageviews == 0 {
+		t.Fatal(sessions, pageviews)
+	}
+
+	target := &Collection{ID: "BBBB", Name: "import.org", OwnerID: 1}
+	if err := InsertCollection(target); err != nil {
+		t.Fatal(err)
+	}
+	importExport := func() *ImportReportT {
+		er, err := NewExportReader(bytes.NewReader(export))
+		if err != nil {
+			t.Fatal(err)
+		}
+		report, err := ImportCollection(er, target.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	report := importExport()
+	if report.Sessions != sessions || report.Pageviews != pageviews || report.Updated != 0 || report.Skipped != 0 {
+		t.Error(report)
+	}
+	report = importExport()
+	if report.Sessions != 0 || report.Pageviews != 0 || report.Skipped != sessions+pageviews {
+		t.Error(report)
+	}
+
+	if err := ShardUpsert(target.ID, firstSession.Key, &Session{Hostname: "stale.org", LastActivity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	report = importExport()
+	if report.Sessions != 0 || report.Updated != 1 || report.Pageviews != 0 {
+		t.Error("the earlier import of the session isn't updated", report)
+	}
+	if session, err := GetSession(target.ID, firstSession.Key); err != nil || session.Hostname != firstSession.Session.Hostname {
+		t.Error(session, err)
+	}
+	if err := ShardUpsert(target.ID, firstSession.Key, &Session{Hostname: "newer.org", LastActivity: math.MaxInt64}); err != nil {
+		t.Fatal(err)
+	}
+	report = importExport()
+	if report.Sessions != 0 || report.Updated != 0 || report.Skipped != sessions+pageviews {
+		t.Error("the newer session is overwritten", report)
+	}
+	if session, err := GetSession(target.ID, firstSession.Key); err != nil || session.Hostname != "newer.org" {
+		t.Error(session, err)
+	}
+
+	if err := ShardUpsert(target.ID, lastPageview.Key, &Pageview{Path: "/collision"}); err != nil {
+		t.Fatal(err)
+	}
+	if report = importExport(); report.RemappedPageviews != 1 {
+		t.Error(report)
+	}
+	if report = importExport(); report.RemappedPageviews != 0 {
+		t.Error("the remapped pageview isn't skipped", report)
+	}
+
+	truncated := &Collection{ID: "BBBC", Name: "truncated.org", OwnerID: 1}
+	if err := InsertCollection(truncated); err != nil {
+		t.Fatal(err)
+	}
+	er, err = NewExportReader(bytes.NewReader(export[:len(export)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = ImportCollection(er, truncated.ID)
+	if err == nil || report == nil || report.CollectionID != truncated.ID || report.Sessions+report.Pageviews > sessions+pageviews-1 {
+		t.Error("the failed import's report", report, err)
+	}
+
+	if _, err := NewExportReader(bytes.NewBufferString("not an export")); err != ErrInvalidExport {
+		t.Error(err)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	plan, err := PlanMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Pending() || plan.From != SchemaVersion {
+		t.Error("a new database should be up to date", plan)
+	}
+
+	dropVersion := func(tx *bolt.Tx) error {
+		return tx.DeleteBucket(BMeta)
+	}
+	err = cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		for _, b := range indexBuckets {
+			if err := tx.DeleteBucket(b); err != nil {
+				return err
+			}
+		}
+		return dropVersion(tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdb, err := getBoltShardDB(collectionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sdb.UpdateShards(func(id string, tx *bolt.Tx) error { return dropVersion(tx) }); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err = PlanMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.From != 0 || len(plan.Migrations) != len(migrations) || len(plan.Shards) == 0 {
+		t.Error(plan)
+	}
+	if err := Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	plan, err = PlanMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Pending() || plan.From != SchemaVersion {
+		t.Error(plan)
+	}
+	if _, err := GetUserByEmail(email); err != nil {
+		t.Error("the indexes should be rebuilt", err)
+	}
+
+	key := GetKey(time.Now().AddDate(5, 0, 0), 1)
+	if err := ShardUpsert(collectionID, key, &Session{}); err != nil {
+		t.Fatal(err)
+	}
+	err = sdb.ViewShards(func(id string, tx *bolt.Tx) error {
+		if version := getSchemaVersionTx(tx); version != SchemaVersion {
+			t.Error(id, version)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		return putSchemaVersionTx(tx, SchemaVersion+1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PlanMigrations(); err == nil {
+		t.Error("a newer schema version should fail")
+	}
+	err = cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		return putSchemaVersionTx(tx, SchemaVersion)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReshard(t *testing.T) {
+	c := &Collection{ID: "RRRR", Name: "reshard.org", OwnerID: 1}
+	if err := InsertCollection(c); err != nil {
+		t.Fatal(err)
+	}
+	Seed(from, to, c.ID, 2000)
+	input := CollectionDataInputT{From: from, To: to, Bucket: "day"}
+	before, err := GetBucketSums(c, &input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, granularity := range []string{ShardWeekly, ShardYearly, ShardMonthly} {
+		report, err := ReshardCollection(c, granularity)
+		if err != nil {
+			t.Fatal(granularity, err)
+		}
+		if report.Records == 0 || report.NewShards == 0 || GetShardGranularity(c) != granularity {
+			t.Error(granularity, report)
+		}
+		if stored, err := GetCollection(c.ID); err != nil || stored.ShardGranularity != granularity {
+			t.Error(granularity, stored, err)
+		}
+		after, err := GetBucketSums(c, &input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(after.SessionSums) != len(before.SessionSums) || len(after.PageviewSums) != len(before.PageviewSums) {
+			t.Fatal(granularity, len(after.SessionSums), len(before.SessionSums))
+		}
+		for i := range before.SessionSums {
+			if *before.SessionSums[i] != *after.SessionSums[i] || *before.PageviewSums[i] != *after.PageviewSums[i] {
+				t.Error(granularity, i, before.SessionSums[i], after.SessionSums[i])
+			}
+		}
+	}
+	if _, err := ReshardCollection(c, "fortnight"); err == nil {
+		t.Error("invalid granularity accepted")
+	}
+
+	oldDir := basedir + c.ID + ".before-reshard"
+	if err := os.Mkdir(oldDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReshardCollection(c, ShardWeekly); err == nil || GetShardGranularity(c) != ShardMonthly {
+		t.Error("resharded over a left behind reshard", err)
+	}
+	if err := os.Remove(oldDir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShardRange(t *testing.T) {
+	day := time.Date(2019, 12, 31, 12, 0, 0, 0, time.Local)
+	for _, granularity := range []string{ShardDaily, ShardWeekly, ShardMonthly, ShardYearly} {
+		id := getShardID(granularity, day)
+		from, to, err := getShardRange(id)
+		if err != nil {
+			t.Fatal(id, err)
+		}
+		key := marshalTime(day)
+		if bytes.Compare(from, key) > 0 || bytes.Compare(key, to) >= 0 {
+			t.Error(granularity, id)
+		}
+	}
+	if id := getShardID(ShardWeekly, day); id != "2020-W01" {
+		t.Error(id)
+	}
+	if _, _, err := getShardRange("not-a-shard"); err == nil {
+		t.Error("invalid shard ID accepted")
+	}
+}
+
+func TestErasure(t *testing.T) {
+	c := &Collection{ID: "EEEE", Name: "erasure.org", OwnerID: 1}
+	if err := InsertCollection(c); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	keys := [][]byte{}
+	for i, ip := range []string{"10.0.0.1", "10.0.0.2", "192.168.1.5"} {
+		begin := now.Add(time.Duration(i-3) * time.Hour)
+		key := GetKey(begin, uint32(i))
+		keys = append(keys, key)
+		if err := ShardUpsert(c.ID, key, &Session{UserIP: ip}); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 2-i%2; j++ {
+			pvKey := GetPVKey(append([]byte{}, key...), begin.Add(time.Duration(j)*time.Minute))
+			if err := ShardUpsert(c.ID, pvKey, &Pageview{Path: "/"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	erase := func(erasure *Erasure, dryRun bool, sessions, pageviews int32) {
+		erasure.CollectionID = c.ID
+		erasure.Actor = "test"
+		if err := EraseSessions(erasure, dryRun); err != nil {
+			t.Fatal(err)
+		}
+		if erasure.Sessions != sessions || erasure.Pageviews != pageviews {
+			t.Error(erasure)
+		}
+	}
+	webhook := &Webhook{URL: "http://erasure", CollectionID: c.ID}
+	if err := InsertWebhook(webhook); err != nil {
+		t.Fatal(err)
+	}
+	deliveries := []*WebhookDelivery{}
+	for _, key := range keys[1:] {
+		payload := []byte(`{"event":"session.created","data":{"session_key":"` + EncodeSessionKey(key) + `"}}`)
+		deliveries = append(deliveries, &WebhookDelivery{WebhookID: webhook.ID, CollectionID: c.ID, Payload: payload})
+	}
+	if _, err := InsertWebhookDeliveries(deliveries, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	erase(&Erasure{IPRange: "10.0.0.0/24"}, true, 2, 3)
+	erase(&Erasure{IP: "10.0.0.1", From: now.Add(-4 * time.Hour).UnixNano()}, false, 1, 2)
+	erase(&Erasure{IPRange: "10.0.0.0/8", From: now.Add(-90 * time.Minute).UnixNano()}, false, 0, 0)
+	erase(&Erasure{SessionKey: EncodeSessionKey(keys[2])}, false, 1, 2)
+
+	if queued, err := GetDueWebhookDeliveries(0, 10); err != nil || len(queued) != 1 || queued[0].ID != deliveries[0].ID {
+		t.Error("the erased session's webhook delivery is queued", queued, err)
+	}
+
+	if _, err := GetSession(c.ID, keys[0]); err == nil {
+		t.Error("erased session exists")
+	}
+	if _, err := GetSession(c.ID, keys[1]); err != nil {
+		t.Error(err)
+	}
+	erasures, err := GetErasures(c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(erasures) != 3 || erasures[0].Criterion != "session_key" || erasures[2].Criterion != "ip" || erasures[0].Actor != "test" {
+		t.Error(erasures)
+	}
+	for _, e := range erasures {
+		if e.SessionKey != "" || e.IP != "" || e.IPRange != "" {
+			t.Error("the erasure criteria are stored in clear", e)
+		}
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(erasures[2].CriteriaHash), []byte("ip:10.0.0.1")); err != nil {
+		t.Error(err)
+	}
+	if err := EraseSessions(&Erasure{CollectionID: c.ID, SessionKey: "AAAA"}, false); err != ErrInvalidSessionKey {
+		t.Error(err)
+	}
+
+	clear := &Erasure{CollectionID: c.ID, IPRange: "10.0.0.0/8"}
+	if err := cipo.Insert(nil, clear); err != nil {
+		t.Fatal(err)
+	}
+	if err := cipo.Bolt().Update(hashErasuresTx); err != nil {
+		t.Fatal(err)
+	}
+	if err := cipo.Get(clear.ID, clear); err != nil || clear.IPRange != "" || clear.Criterion != "ip_range" || clear.CriteriaHash == "" {
+		t.Error("the erasure recorded in clear isn't hashed", clear, err)
+	}
+
+	if err := DeleteCollection(c); err != nil {
+		t.Fatal(err)
+	}
+	if erasures, err := GetErasures(c.ID); err != nil || len(erasures) != 0 {
+		t.Error("the deleted collection's erasures are kept", erasures, err)
+	}
+	if queued, err := GetDueWebhookDeliveries(0, 10); err != nil || len(queued) != 0 {
+		t.Error(queued, err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	c := &Collection{ID: "PPPP", Name: "purge.org", OwnerID: 1}
+	if err := InsertCollection(c); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2019, 6, 10, 0, 0, 0, 0, time.Local)
+	keys := [][]byte{}
+	for i := 0; i < 3; i++ {
+		begin := day.AddDate(0, 0, i).Add(12 * time.Hour)
+		key := GetKey(begin, uint32(i))
+		keys = append(keys, key)
+		if err := ShardUpsert(c.ID, key, &Session{Hostname: "purge.org"}); err != nil {
+			t.Fatal(err)
+		}
+		pvKey := GetPVKey(append([]byte{}, key...), begin.Add(time.Minute))
+		if err := ShardUpsert(c.ID, pvKey, &Pageview{Path: "/"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := PurgeCollection(c.ID, day.AddDate(0, 0, 1), day.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sessions != 1 || report.Pageviews != 1 || len(report.Shards) != 0 {
+		t.Error(report)
+	}
+	shards, err := CompactCollection(c.ID, day.AddDate(0, 0, 1), day.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) != 1 || shards[0].ID != "2019-06" {
+		t.Error(shards)
+	}
+	for i, key := range keys {
+		if _, err := GetSession(c.ID, key); (err == nil) != (i != 1) {
+			t.Error(i, err)
+		}
+	}
+}
+
+func TestSessionActivity(t *testing.T) {
+	c := &Collection{ID: "TTTT", Name: "activity.org", OwnerID: 1}
+	if err := InsertCollection(c); err != nil {
+		t.Fatal(err)
+	}
+	begin := time.Now().Add(-time.Hour).Truncate(time.Second)
+	key := GetKey(begin, 1)
+	if err := ShardUpsert(c.ID, key, &Session{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddPageview(c, key, begin.Add(time.Minute), &Pageview{Path: "/"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := TouchSession(c, key, begin.Add(5*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := TouchSession(c, key, begin.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	session, err := GetSession(c.ID, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Duration != 300 || session.LastActivity != begin.Add(5*time.Minute).UnixNano() {
+		t.Error(session)
+	}
+	if err := TouchSession(c, GetKey(begin, 2), begin); err != ErrKeyNotExists {
+		t.Error(err)
+	}
+	if _, err := AddPageview(c, GetKey(begin, 2), begin, &Pageview{Path: "/"}, 0); err != ErrKeyNotExists {
+		t.Error(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			at := begin.Add(time.Duration(6*60+i) * time.Second)
+			if _, err := AddPageview(c, key, at, &Pageview{Path: "/batched"}, 0); err != nil {
+				t.Error(err)
+			}
+			if err := TouchSession(c, key, at); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if pageviews, err := GetPageviews(c, key); err != nil || len(pageviews) != 21 {
+		t.Error(len(pageviews), err)
+	}
+	if session, _ := GetSession(c.ID, key); session.LastActivity != begin.Add(6*time.Minute+19*time.Second).UnixNano() {
+		t.Error(session)
+	}
+
+	legacyKey := GetKey(begin.Add(time.Second), 3)
+	if err := ShardUpsert(c.ID, legacyKey, &Session{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []time.Duration{0, 3 * time.Minute} {
+		pvKey := GetPVKey(append([]byte{}, legacyKey...), begin.Add(time.Second+d))
+		if err := ShardUpsert(c.ID, pvKey, &Pageview{Path: "/"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sessions, _, err := GetSessions(c, &CollectionDataInputT{From: begin.Add(-time.Minute), To: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].Duration != 379 || sessions[1].Duration != 180 {
+		for _, s := range sessions {
+			t.Error(s)
+		}
+	}
+}
+
+func TestCountMetric(t *testing.T) {
+	c := &Collection{ID: "CMCM", Name: "countmetric.org", OwnerID: 1}
+	if err := InsertCollection(c); err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2019, 5, 6, 12, 0, 0, 0, time.Local)
+	to := from.Add(2 * time.Hour)
+	sessions := map[time.Time][]time.Time{
+		from.Add(-3 * time.Hour): {from.Add(-3 * time.Hour), from, from.Add(time.Hour), to},
+		from.Add(time.Minute):    {from.Add(time.Minute)},
+	}
+	for begin, pageviews := range sessions {
+		key := GetKey(begin, 1)
+		if err := ShardUpsert(c.ID, key, &Session{}); err != nil {
+			t.Fatal(err)
+		}
+		for _, pv := range pageviews {
+			if err := ShardUpsert(c.ID, GetPVKey(append([]byte{}, key...), pv), &Pageview{Path: "/checkout"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if count, err := CountMetric(c.ID, "pageviews", map[string]string{}, from, to); err != nil || count != 3 {
+		t.Error("the window's pageviews of the earlier sessions aren't counted", count, err)
+	}
+	if count, err := CountMetric(c.ID, "sessions", map[string]string{}, from, to); err != nil || count != 1 {
+		t.Error(count, err)
+	}
+}
+
+func TestSessionTimeout(t *testing.T) {
+	c := &Collection{ID: "OOOO", Name: "timeout.org", OwnerID: 1, SessionTimeout: 10}
+	if err := InsertCollection(c); err != nil {
+		t.Fatal(err)
+	}
+	loc, _ := time.LoadLocation("Europe/Budapest")
+	begin := time.Date(2019, 3, 4, 23, 40, 0, 0, loc)
+	key := GetKey(begin, 1)
+	if err := ShardUpsert(c.ID, key, &Session{UserIP: "10.0.0.1", Referrer: "http://irl.hu", VisitorID: 7, VisitCount: 1}); err != nil {
+		t.Fatal(err)
+	}
+	addPageview := func(key []byte, t time.Time) []byte {
+		newKey, err := AddPageview(c, key, t, &Pageview{Path: "/"}, 8)
+		if err != nil {
+			panic(err)
+		}
+		return newKey
+	}
+	if next := addPageview(key, begin.Add(9*time.Minute)); !bytes.Equal(next, key) {
+		t.Error("session continued", next)
+	}
+	if err := TouchSession(c, key, begin.Add(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	next := addPageview(key, begin.Add(30*time.Minute))
+	if bytes.Equal(next, key) {
+		t.Fatal("session not expired")
+	}
+	session, err := GetSession(c.ID, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.UserIP != "10.0.0.1" || session.Referrer != "" || session.LastActivity != begin.Add(30*time.Minute).UnixNano() {
+		t.Error(session)
+	}
+	if session.VisitorID != 8 || session.VisitCount != 2 || session.DaysSinceLastVisit != 0 || GetVisitorType(session) != VisitorReturning {
+		t.Error("the continuation's visitor isn't recomputed", session)
+	}
+	if session, _ := GetSession(c.ID, key); session.Duration != 9*60 {
+		t.Error(session)
+	}
+
+	c.SessionTimeout = 0
+	c.SessionMidnightTimezone = "Europe/Budapest"
+	key = GetKey(begin, 2)
+	if err := ShardUpsert(c.ID, key, &Session{}); err != nil {
+		t.Fatal(err)
+	}
+	if next := addPageview(key, begin.Add(15*time.Minute)); !bytes.Equal(next, key) {
+		t.Error("session split before midnight", next)
+	}
+	if next := addPageview(key, begin.Add(25*time.Minute)); bytes.Equal(next, key) {
+		t.Error("session not split at midnight")
+	}
+
+	c.SessionMidnightTimezone = ""
+	monthEnd := time.Date(2019, 3, 31, 23, 50, 0, 0, time.Local)
+	key = GetKey(monthEnd, 3)
+	if err := ShardUpsert(c.ID, key, &Session{}); err != nil {
+		t.Fatal(err)
+	}
+	next = addPageview(key, monthEnd.Add(time.Hour))
+	if bytes.Equal(next, key) {
+		t.Fatal("session not expired")
+	}
+	if pageviews, err := GetPageviews(c, next); err != nil || len(pageviews) != 1 {
+		t.Error("the continuation in the next shard", pageviews, err)
+	}
+}
+
+func TestUniqueVisitors(t *testing.T) {
+	c := &Collection{ID: "UUUU", Name: "uniques.org", OwnerID: 1}
+	if err := InsertCollection(c); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2019, 3, 4, 12, 0, 0, 0, time.UTC)
+	for d := 0; d < 3; d++ {
+		for i := 0; i < 20; i++ {
+			visitorID := uint64(d*10+i%10+1) * 0x9E3779B97F4A7C15
+			key := GetKey(day.AddDate(0, 0, d).Add(time.Duration(i)*time.Minute), uint32(i))
+			if err := ShardUpsert(c.ID, key, &Session{VisitorID: visitorID}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := ShardUpsert(c.ID, GetKey(day, 100), &Session{}); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := GetStatistics(c, &CollectionDataInputT{From: day.AddDate(0, 0, -1), To: day.AddDate(0, 0, 3), Timezone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.SessionTotal.Count != 61 || stat.VisitorDays.Count != 30 {
+		t.Error(stat.SessionTotal, stat.VisitorDays)
+	}
+	if len(stat.DailyUniqueVisitors) != 3 || stat.DailyUniqueVisitors[0].Bucket != day.Truncate(24*time.Hour).Unix() {
+		t.Fatal(stat.DailyUniqueVisitors)
+	}
+	for _, b := range stat.DailyUniqueVisitors {
+		if b.Count != 10 {
+			t.Error(b)
+		}
+	}
+	if len(stat.WeeklyVisitorDays) != 1 || stat.WeeklyVisitorDays[0].Count != 30 {
+		t.Error(stat.WeeklyVisitorDays)
+	}
+	if len(stat.MonthlyVisitorDays) != 1 || stat.MonthlyVisitorDays[0].Count != 30 {
+		t.Error(stat.MonthlyVisitorDays)
+	}
+}
+
+func TestVisitCounts(t *testing.T) {
+	c := &Collection{ID: "VVVV", Name: "visits.org", OwnerID: 1, VisitCounter: true}
+	if err := InsertCollection(c); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2019, 3, 4, 12, 0, 0, 0, time.UTC)
+	sessions := []*Session{
+		{VisitCount: 1},
+		{VisitCount: 1},
+		{VisitCount: 2, DaysSinceLastVisit: 1},
+		{VisitCount: 5, DaysSinceLastVisit: 1},
+		{},
+	}
+	for i, session := range sessions {
+		if err := ShardUpsert(c.ID, GetKey(day.Add(time.Duration(i)*time.Minute), uint32(i)), session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := &CollectionDataInputT{From: day.Add(-time.Hour), To: day.Add(time.Hour)}
+	stat, err := GetStatistics(c, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := func(sums []sumT) map[string]int {
+		m := map[string]int{}
+		for _, s := range sums {
+			m[s.Name] = s.Count
+		}
+		return m
+	}
+	if m := counts(stat.VisitorTypeSums); len(m) != 2 || m[VisitorNew] != 2 || m[VisitorReturning] != 2 {
+		t.Error(stat.VisitorTypeSums)
+	}
+	if m := counts(stat.VisitCountSums); len(m) != 3 || m["1"] != 2 {
+		t.Error(stat.VisitCountSums)
+	}
+	if m := counts(stat.DaysSinceLastVisitSums); len(m) != 1 || m["1"] != 2 {
+		t.Error(stat.DaysSinceLastVisitSums)
+	}
+
+	input.Filter = map[string]string{"visitor_type": VisitorReturning}
+	if stat, err = GetStatistics(c, input); err != nil {
+		t.Fatal(err)
+	}
+	if stat.SessionTotal.Count != 2 {
+		t.Error(stat.SessionTotal)
+	}
+	input.Filter = map[string]string{"visit_count": "5"}
+	if stat, err = GetStatistics(c, input); err != nil {
+		t.Fatal(err)
+	}
+	if stat.SessionTotal.Count != 1 {
+		t.Error(stat.SessionTotal)
+	}
+}
+
+func TestProperties(t *testing.T) {
+	c := &Collection{ID: "CCCC", Name: "properties.org", OwnerID: 1}
+	if err := InsertCollection(c); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2019, 3, 4, 12, 0, 0, 0, time.UTC)
+	for i, plan := range []string{"free", "pro", "pro"} {
+		key := GetKey(day.Add(time.Duration(i)*time.Minute), uint32(i))
+		if err := ShardUpsert(c.ID, key, &Session{Properties: []*Property{{Key: "plan", Value: plan}}}); err != nil {
+			t.Fatal(err)
+		}
+		pvKey := GetPVKey(append([]byte{}, key...), day.Add(time.Duration(i)*time.Minute))
+		if err := ShardUpsert(c.ID, pvKey, &Pageview{Path: "/", Properties: []*Property{{Key: "author", Value: "a" + plan}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := &CollectionDataInputT{From: day.Add(-time.Hour), To: day.Add(time.Hour)}
+	stat, err := GetStatistics(c, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plans := stat.SessionPropertySums["plan"]
+	if len(plans) != 2 || plans[0].Name != "pro" || plans[0].Count != 2 || len(stat.PageviewPropertySums["author"]) != 2 {
+		t.Error(stat.SessionPropertySums, stat.PageviewPropertySums)
+	}
+
+	input.Filter = map[string]string{SessionPropertyFilter + "plan": "pro"}
+	if stat, err = GetStatistics(c, input); err != nil {
+		t.Fatal(err)
+	}
+	if stat.SessionTotal.Count != 2 {
+		t.Error(stat.SessionTotal)
+	}
+	input.Filter = map[string]string{PageviewPropertyFilter + "author": "afree", SessionPropertyFilter + "plan": "pro"}
+	if stat, err = GetStatistics(c, input); err != nil {
+		t.Fatal(err)
+	}
+	if stat.SessionTotal.Count != 0 {
+		t.Error(stat.SessionTotal)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := newMemStore(getShardMapFn(ShardMonthly))
+	june := time.Date(2019, 6, 10, 0, 0, 0, 0, time.Local)
+	keys := [][]byte{GetKey(june, 2), GetKey(june, 1), GetKey(june.AddDate(0, 1, 0), 1)}
+	err := s.Update(func(tx ShardTx) error {
+		for _, k := range keys {
+			if err := tx.Put(BSession, k, k); err != nil {
+				return err
+			}
+		}
+		if v, err := tx.Get(BSession, keys[0]); err != nil || !bytes.Equal(v, keys[0]) {
+			t.Error("the writes should be visible in the transaction", v, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pvKey := GetPVKey(append([]byte{}, keys[1]...), june.Add(time.Minute))
+	if err := s.BatchUpsert(BPageview, pvKey, []byte("/")); err != nil {
+		t.Fatal(err)
+	}
+
+	iterated := [][]byte{}
+	s.Iterate(BSession, marshalTime(june), marshalTime(june.AddDate(1, 0, 0)), func(k, v []byte) {
+		iterated = append(iterated, k)
+	})
+	if len(iterated) != 3 || !bytes.Equal(iterated[0], keys[1]) || !bytes.Equal(iterated[2], keys[2]) {
+		t.Error(iterated)
+	}
+	count := 0
+	s.IteratePrefix(BPageview, keys[1], func(k, v []byte) { count++ })
+	if count != 1 {
+		t.Error(count)
+	}
+	if ids := s.ShardIDs(marshalTime(june), marshalTime(june.AddDate(1, 0, 0))); len(ids) != 2 || ids[0] != "2019-06" {
+		t.Error(ids)
+	}
+	if sizes := s.GetSizes(); len(sizes) != 2 || sizes[0].ID != "2019-06" || sizes[0].Size == 0 {
+		t.Error(sizes)
+	}
+
+	tx := s.Begin(true)
+	if err := tx.Delete(BSession, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+	if _, err := s.Get(BSession, keys[0]); err != nil {
+		t.Error("the rolled back delete is applied", err)
+	}
+	err = s.Update(func(tx ShardTx) error {
+		deleted, err := tx.DeletePrefix(BPageview, keys[1])
+		if deleted != 1 {
+			t.Error(deleted)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := s.DeleteRange(BSession, marshalTime(june), marshalTime(june.Add(time.Hour))); err != nil || deleted != 2 {
+		t.Error(deleted, err)
+	}
+	if err := s.DeleteShard("2019-07"); err != nil {
+		t.Error(err)
+	}
+	if sizes := s.GetSizes(); len(sizes) != 0 {
+		t.Error(sizes)
+	}
+	if err := s.Begin(false).Put(BSession, keys[0], keys[0]); err != errTxReadOnly {
+		t.Error(err)
+	}
+
+	counter := []byte("counter")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.BatchUpdate(counter, func(tx ShardTx) error {
+				v, err := tx.Get(BSession, counter)
+				if err != nil {
+					return err
+				}
+				time.Sleep(time.Millisecond)
+				return tx.Put(BSession, counter, append(append([]byte{}, v...), 1))
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, err := s.Get(BSession, counter); err != nil || len(v) != 20 {
+		t.Error("the concurrent writable transactions lost updates", len(v), err)
+	}
+	tx = s.Begin(true)
+	if err := tx.Commit(); err != nil {
+		t.Error(err)
+	}
+	if err := tx.Rollback(); err != errTxClosed {
+		t.Error(err)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		SetStorage(StorageBolt)
+		InitDatabase(dir)
+	}()
+	if err := SetStorage(StorageMemory); err != nil {
+		t.Fatal(err)
+	}
+	InitDatabase(dir)
+	tmp := tempDir
+	if tmp == "" || basedir == dir+"/" {
+		t.Fatal("the memory storage should use a temporary directory", basedir)
+	}
+
+	user := &User{Email: email, Password: "e!"}
+	if err := InsertUser(user); err != nil {
+		t.Fatal(err)
+	}
+	c := &Collection{ID: "MMMM", Name: "memory.org", OwnerID: user.ID}
+	if err := InsertCollection(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := Seed(from, to, c.ID, 1000); err != nil {
+		t.Fatal(err)
+	}
+	input := CollectionDataInputT{From: from, To: to, Bucket: "day"}
+	sums, err := GetBucketSums(c, &input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := 0
+	for _, s := range sums.SessionSums {
+		sessions += s.Count
+	}
+	if sessions == 0 {
+		t.Error("no sessions in the memory store")
+	}
+	if _, err := os.Stat(filepath.Join(tmp, c.ID)); !os.IsNotExist(err) {
+		t.Error("the memory store wrote shard files", err)
+	}
+	if err := ExportCollection(ioutil.Discard, c); err != nil {
+		t.Error(err)
+	}
+	if err := WriteCollectionBackup(ioutil.Discard, c.ID, false); err != ErrStoreNotSupported {
+		t.Error(err)
+	}
+
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Error("the temporary directory is left behind", err)
+	}
+}
@@ -0,0 +1,10 @@
+package db
+
+import (
+	"math"
+)
+
+// diskFree is not implemented on windows, the free space check always passes
+func diskFree(dir string) (uint64, error) {
+	return math.MaxUint64, nil
+}
@@ -15,6 +15,12 @@ var (
 	BSession    = []byte("Session")
 	BPageview   = []byte("Pageview")
 	BAuthToken  = []byte("AuthToken")
+	BAlert      = []byte("Alert")
+	BAlertEvent = []byte("AlertEvent")
+	BWebhook    = []byte("Webhook")
+	BDelivery   = []byte("WebhookDelivery")
+	BBackupRun  = []byte("BackupRun")
+	BErasure    = []byte("Erasure")
 )
 
 func bucketName(value interface{}) []byte {
@@ -31,6 +37,18 @@ func bucketName(value interface{}) []byte {
 		return BPageview
 	case *AuthToken:
 		return BAuthToken
+	case *Alert:
+		return BAlert
+	case *AlertEvent:
+		return BAlertEvent
+	case *Webhook:
+		return BWebhook
+	case *WebhookDelivery:
+		return BDelivery
+	case *BackupRun:
+		return BBackupRun
+	case *Erasure:
+		return BErasure
 	}
 }
 
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"time"
+
+	bolt "github.com/etcd-io/bbolt"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidSessionKey is returned when the erasure's session key is malformed
+var ErrInvalidSessionKey = errors.New("invalid session key")
+
+// EraseSessions deletes the sessions matching the erasure's session key, IP or IP range
+// between its From and To with their pageviews and the pending webhook deliveries of them,
+// and records the erasure in the audit trail with the criteria hashed.
+// The counts are filled in, a dry run only counts the matching records.
+func EraseSessions(erasure *Erasure, dryRun bool) error {
+	sdb, err := getShardDB(erasure.CollectionID)
+	if err != nil {
+		return err
+	}
+	keys, err := getErasureKeys(sdb, erasure)
+	if err != nil {
+		return err
+	}
+
+	erasure.Sessions, erasure.Pageviews = 0, 0
+	erased := []string{}
+	tx := sdb.Begin(true)
+	for _, key := range keys {
+		value, err := tx.Get(BSession, key)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if value == nil {
+			continue
+		}
+		if err := tx.Delete(BSession, key); err != nil {
+			tx.Rollback()
+			return err
+		}
+		pageviews, err := tx.DeletePrefix(BPageview, key)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		erasure.Sessions++
+		erasure.Pageviews += int32(pageviews)
+		erased = append(erased, EncodeSessionKey(key))
+	}
+	if dryRun {
+		return tx.Rollback()
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if err := hashErasureCriteria(erasure); err != nil {
+		return err
+	}
+	erasure.Created = time.Now().UnixNano()
+	return cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		if err := deleteSessionWebhookDeliveriesTx(tx, erasure.CollectionID, erased); err != nil {
+			return err
+		}
+		return cipo.InsertTx(tx, nil, erasure)
+	})
+}
+
+// hashErasureCriteria replaces the erasure's session key, IP or IP range with a bcrypt hash,
+// so the audit trail can confirm an erasure of a known value, but it doesn't keep the personal data
+func hashErasureCriteria(erasure *Erasure) error {
+	criterion, value := "session_key", erasure.SessionKey
+	if erasure.IP != "" {
+		criterion, value = "ip", erasure.IP
+	} else if erasure.IPRange != "" {
+		criterion, value = "ip_range", erasure.IPRange
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(criterion+":"+value), 10)
+	if err != nil {
+		return err
+	}
+	erasure.Criterion, erasure.CriteriaHash = criterion, string(hash)
+	erasure.SessionKey, erasure.IP, erasure.IPRange = "", "", ""
+	return nil
+}
+
+// hashErasuresTx hashes the criteria of the erasures recorded in clear
+func hashErasuresTx(tx *bolt.Tx) error {
+	ID := uint64(0)
+	v := Erasure{}
+	erasures := []Erasure{}
+	err := cipo.IterateTx(tx, &ID, &v, func() error {
+		if v.SessionKey != "" || v.IP != "" || v.IPRange != "" {
+			erasures = append(erasures, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := range erasures {
+		if err := hashErasureCriteria(&erasures[i]); err != nil {
+			return err
+		}
+		if err := cipo.UpdateTx(tx, erasures[i].ID, &erasures[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteErasuresByCollectionIDTx(tx *bolt.Tx, collectionID string) error {
+	ID := uint64(0)
+	v := Erasure{}
+	ids := []uint64{}
+	err := cipo.IterateTx(tx, &ID, &v, func() error {
+		if v.CollectionID == collectionID {
+			ids = append(ids, v.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := cipo.DeleteTx(tx, id, &Erasure{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSessionWebhookDeliveriesTx drops the collection's queued webhook deliveries
+// which carry one of the encoded session keys
+func deleteSessionWebhookDeliveriesTx(tx *bolt.Tx, collectionID string, sessionKeys []string) error {
+	if len(sessionKeys) == 0 {
+		return nil
+	}
+	ID := uint64(0)
+	v := Webhook{}
+	webhookIDs := []uint64{}
+	err := cipo.IterateTx(tx, &ID, &v, func() error {
+		if v.CollectionID == collectionID {
+			webhookIDs = append(webhookIDs, v.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	carriesSession := func(delivery *WebhookDelivery) bool {
+		for _, key := range sessionKeys {
+			if bytes.Contains(delivery.Payload, []byte(`"session_key":"`+key+`"`)) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, id := range webhookIDs {
+		if err := deleteWebhookDeliveriesTx(tx, id, carriesSession); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getErasureKeys(sdb ShardStore, erasure *Erasure) ([][]byte, error) {
+	if erasure.SessionKey != "" {
+		key, err := DecodeSessionKey(erasure.SessionKey)
+		if err != nil || len(key) != sessionKeyLength {
+			return nil, ErrInvalidSessionKey
+		}
+		return [][]byte{key}, nil
+	}
+
+	match := func(ip net.IP) bool { return false }
+	if erasure.IPRange != "" {
+		_, ipNet, err := net.ParseCIDR(erasure.IPRange)
+		if err != nil {
+			return nil, err
+		}
+		match = ipNet.Contains
+	} else if target := net.ParseIP(erasure.IP); target != nil {
+		match = target.Equal
+	}
+	to := time.Now()
+	if erasure.To != 0 {
+		to = time.Unix(0, erasure.To)
+	}
+	keys := [][]byte{}
+	session := &Session{}
+	var err error
+	sdb.Iterate(BSession, marshalTime(time.Unix(0, erasure.From)), marshalTime(to), func(k []byte, v []byte) {
+		if err != nil {
+			return
+		}
+		if err = proto.Unmarshal(v, session); err != nil {
+			return
+		}
+		if ip := net.ParseIP(session.UserIP); ip == nil || !match(ip) {
+			return
+		}
+		keys = append(keys, append([]byte{}, k...))
+	})
+	return keys, err
+}
+
+// GetErasures returns the collection's erasure audit trail, the newest first
+func GetErasures(collectionID string) ([]Erasure, error) {
+	erasure := Erasure{}
+	erasures := []Erasure{}
+	err := cipo.Iterate(&erasure.ID, &erasure, func() error {
+		if erasure.CollectionID == collectionID {
+			erasures = append([]Erasure{erasure}, erasures...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return erasures, nil
+}
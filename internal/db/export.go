@@ -0,0 +1,290 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	proto "github.com/golang/protobuf/proto"
+)
+
+// ExportVersion is the version of the collection export format
+const ExportVersion = 1
+
+// The export stream starts with the magic, then comes the length prefixed
+// ExportHeader and the ExportRecords, the sessions precede their pageviews
+var exportMagic = []byte("RIGHTANA-EXPORT\n")
+
+const (
+	maxExportFrame = 16 << 20
+	importTxSize   = 10000
+)
+
+// ErrInvalidExport is returned when the stream is not a collection export
+var ErrInvalidExport = errors.New("not a rightana collection export")
+
+// ImportReportT is the collection import's report, a failed import's report contains
+// the committed records and the error
+type ImportReportT struct {
+	CollectionID      string `json:"collection_id"`
+	Sessions          int    `json:"sessions"`
+	Pageviews         int    `json:"pageviews"`
+	Updated           int    `json:"updated"`
+	RemappedPageviews int    `json:"remapped_pageviews"`
+	Skipped           int    `json:"skipped"`
+	Error             string `json:"error,omitempty"`
+}
+
+func (r *ImportReportT) add(o *ImportReportT) {
+	r.Sessions += o.Sessions
+	r.Pageviews += o.Pageviews
+	r.Updated += o.Updated
+	r.RemappedPageviews += o.RemappedPageviews
+	r.Skipped += o.Skipped
+}
+
+// ExportCollection writes the collection's metadata, sessions and pageviews into the stream
+func ExportCollection(w io.Writer, collection *Collection) error {
+	sdb, err := getShardDB(collection.ID)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(exportMagic); err != nil {
+		return err
+	}
+	header := &ExportHeader{
+		Version:          ExportVersion,
+		CollectionID:     collection.ID,
+		Name:             collection.Name,
+		Created:          collection.Created,
+		Exported:         time.Now().UnixNano(),
+		ShardGranularity: collection.ShardGranularity,
+	}
+	if err := writeFrame(bw, header); err != nil {
+		return err
+	}
+	fromKey, toKey := marshalTime(time.Unix(0, 0)), marshalTime(time.Unix(0, math.MaxInt64))
+	if err := exportBucket(bw, sdb, BSession, fromKey, toKey, func(k, v []byte) (*ExportRecord, error) {
+		session := &Session{}
+		return &ExportRecord{Key: k, Session: session}, proto.Unmarshal(v, session)
+	}); err != nil {
+		return err
+	}
+	if err := exportBucket(bw, sdb, BPageview, fromKey, toKey, func(k, v []byte) (*ExportRecord, error) {
+		pageview := &Pageview{}
+		return &ExportRecord{Key: k, Pageview: pageview}, proto.Unmarshal(v, pageview)
+	}); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func exportBucket(w io.Writer, sdb ShardStore, bucket []byte, fromKey []byte, toKey []byte, fn func(k, v []byte) (*ExportRecord, error)) error {
+	var err error
+	sdb.Iterate(bucket, fromKey, toKey, func(k, v []byte) {
+		if err != nil {
+			return
+		}
+		var record *ExportRecord
+		if record, err = fn(k, v); err != nil {
+			return
+		}
+		err = writeFrame(w, record)
+	})
+	return err
+}
+
+func writeFrame(w io.Writer, m proto.Message) error {
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(len(data)))
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ExportReader reads a collection export stream
+type ExportReader struct {
+	Header *ExportHeader
+	r      *bufio.Reader
+}
+
+// NewExportReader checks the stream's magic and reads its header
+func NewExportReader(r io.Reader) (*ExportReader, error) {
+	er := &ExportReader{&ExportHeader{}, bufio.NewReader(r)}
+	magic := make([]byte, len(exportMagic))
+	if _, err := io.ReadFull(er.r, magic); err != nil || !bytes.Equal(magic, exportMagic) {
+		return nil, ErrInvalidExport
+	}
+	if err := er.readFrame(er.Header); err != nil {
+		if err == io.EOF {
+			return nil, ErrInvalidExport
+		}
+		return nil, err
+	}
+	if er.Header.Version == 0 || er.Header.Version > ExportVersion {
+		return nil, fmt.Errorf("unsupported export version: %v", er.Header.Version)
+	}
+	return er, nil
+}
+
+// Next returns the next record, or io.EOF at the end of the stream
+func (er *ExportReader) Next() (*ExportRecord, error) {
+	record := &ExportRecord{}
+	if err := er.readFrame(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (er *ExportReader) readFrame(m proto.Message) error {
+	size, err := binary.ReadUvarint(er.r)
+	if err != nil {
+		return err
+	}
+	if size > maxExportFrame {
+		return fmt.Errorf("export frame too large: %v", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(er.r, data); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// ImportCollection imports the records into the collection. An existing session key is the same
+// session from an earlier export, it's updated if the imported one has a later activity, otherwise
+// it's skipped. The colliding pageviews are moved to the next free nanosecond, the identical records
+// are skipped. The records are committed in batches, on an error the report of the already committed
+// ones is returned with the error.
+func ImportCollection(er *ExportReader, collectionID string) (*ImportReportT, error) {
+	sdb, err := getShardDB(collectionID)
+	if err != nil {
+		return nil, err
+	}
+	report := &ImportReportT{CollectionID: collectionID}
+	pending := &ImportReportT{}
+	tx := sdb.Begin(true)
+	n := 0
+	for {
+		record, err := er.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			tx.Rollback()
+			return report, err
+		}
+		if err := importRecord(tx, record, pending); err != nil {
+			tx.Rollback()
+			return report, err
+		}
+		n++
+		if n%importTxSize == 0 {
+			if err := tx.Commit(); err != nil {
+				return report, err
+			}
+			report.add(pending)
+			pending = &ImportReportT{}
+			tx = sdb.Begin(true)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return report, err
+	}
+	report.add(pending)
+	return report, nil
+}
+
+func importRecord(tx ShardTx, record *ExportRecord, report *ImportReportT) error {
+	key := record.Key
+	switch {
+	case record.Session != nil:
+		if len(key) != 12 {
+			return fmt.Errorf("invalid session key: %x", key)
+		}
+		value, err := proto.Marshal(record.Session)
+		if err != nil {
+			return err
+		}
+		existing, err := tx.Get(BSession, key)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if bytes.Equal(existing, value) {
+				report.Skipped++
+				return nil
+			}
+			stored := &Session{}
+			if err := proto.Unmarshal(existing, stored); err != nil {
+				return err
+			}
+			if !getLastActivity(key, record.Session).After(getLastActivity(key, stored)) {
+				report.Skipped++
+				return nil
+			}
+			report.Updated++
+			return tx.Put(BSession, key, value)
+		}
+		report.Sessions++
+		return tx.Put(BSession, key, value)
+	case record.Pageview != nil:
+		if len(key) != 20 {
+			return fmt.Errorf("invalid pageview key: %x", key)
+		}
+		value, err := proto.Marshal(record.Pageview)
+		if err != nil {
+			return err
+		}
+		existing, err := tx.Get(BPageview, key)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			newKey, err := getFreePageviewKey(tx, key, value)
+			if err != nil {
+				return err
+			}
+			if newKey == nil {
+				report.Skipped++
+				return nil
+			}
+			report.RemappedPageviews++
+			key = newKey
+		}
+		report.Pageviews++
+		return tx.Put(BPageview, key, value)
+	}
+	return fmt.Errorf("empty export record: %x", key)
+}
+
+// getFreePageviewKey returns the key of the next free nanosecond after the colliding pageview key,
+// or nil if the pageview is already stored at a key on the way
+func getFreePageviewKey(tx ShardTx, key []byte, value []byte) ([]byte, error) {
+	t := GetTimeFromPVKey(key)
+	for i := 0; ; i++ {
+		k := GetPVKey(append([]byte{}, key[:sessionKeyLength]...), t.Add(time.Duration(i)))
+		existing, err := tx.Get(BPageview, k)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return k, nil
+		}
+		if bytes.Equal(existing, value) {
+			return nil, nil
+		}
+	}
+}
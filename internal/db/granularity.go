@@ -0,0 +1,84 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// The shard granularities of the collections, the empty one is monthly
+const (
+	ShardDaily   = "day"
+	ShardWeekly  = "week"
+	ShardMonthly = "month"
+	ShardYearly  = "year"
+)
+
+var shardLayouts = map[string]string{
+	ShardDaily:   "2006-01-02",
+	ShardMonthly: "2006-01",
+	ShardYearly:  "2006",
+}
+
+// ValidShardGranularity checks the granularity, the empty one is valid
+func ValidShardGranularity(granularity string) bool {
+	return granularity == "" || granularity == ShardWeekly || shardLayouts[granularity] != ""
+}
+
+func getShardGranularity(granularity string) string {
+	if granularity == "" {
+		return ShardMonthly
+	}
+	return granularity
+}
+
+// GetShardGranularity returns the collection's shard granularity
+func GetShardGranularity(collection *Collection) string {
+	return getShardGranularity(collection.ShardGranularity)
+}
+
+// getShardID returns the shard's ID of the time in the granularity, the weekly shards are ISO weeks
+func getShardID(granularity string, t time.Time) string {
+	granularity = getShardGranularity(granularity)
+	if granularity == ShardWeekly {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	}
+	return t.Format(shardLayouts[granularity])
+}
+
+func getShardMapFn(granularity string) func(key []byte) string {
+	return func(key []byte) string {
+		t, err := unmarshalTime(key)
+		if err != nil {
+			panic(err)
+		}
+		return getShardID(granularity, t)
+	}
+}
+
+// getShardRange returns the [from, to) key range of a shard ID in any granularity
+func getShardRange(id string) ([]byte, []byte, error) {
+	var year, week int
+	if n, _ := fmt.Sscanf(id, "%04d-W%02d", &year, &week); n == 2 {
+		jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, time.Local)
+		from := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+(week-1)*7)
+		return marshalTime(from), marshalTime(from.AddDate(0, 0, 7)), nil
+	}
+	for granularity, layout := range shardLayouts {
+		from, err := time.ParseInLocation(layout, id, time.Local)
+		if err != nil || len(id) != len(layout) {
+			continue
+		}
+		var to time.Time
+		switch granularity {
+		case ShardDaily:
+			to = from.AddDate(0, 0, 1)
+		case ShardMonthly:
+			to = from.AddDate(0, 1, 0)
+		case ShardYearly:
+			to = from.AddDate(1, 0, 0)
+		}
+		return marshalTime(from), marshalTime(to), nil
+	}
+	return nil, nil, fmt.Errorf("invalid shard ID: %v", id)
+}
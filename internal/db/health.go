@@ -0,0 +1,33 @@
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	bolt "github.com/etcd-io/bbolt"
+)
+
+// CheckMain checks whether the main database answers a read transaction
+func CheckMain() error {
+	if cipo == nil {
+		return fmt.Errorf("the main db is not opened")
+	}
+	return cipo.Bolt().View(func(tx *bolt.Tx) error {
+		tx.Bucket(BUser)
+		return nil
+	})
+}
+
+// CheckDataDir checks whether the data dir is writable and returns its free space in bytes
+func CheckDataDir() (uint64, error) {
+	f, err := ioutil.TempFile(basedir, ".readyz")
+	if err != nil {
+		return 0, err
+	}
+	f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		return 0, err
+	}
+	return diskFree(basedir)
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"bytes"
+	"log"
+
+	bolt "github.com/etcd-io/bbolt"
+)
+
+// These are the unique index buckets' names
+var (
+	BUserByEmail      = []byte("UserByEmail")
+	BUserByName       = []byte("UserByName")
+	BCollectionByName = []byte("CollectionByName")
+)
+
+var indexBuckets = [][]byte{BUserByEmail, BUserByName, BCollectionByName}
+
+func collectionNameKey(ownerID uint64, name string) []byte {
+	return append(marshaluint64(ownerID), name...)
+}
+
+func getIndexTx(tx *bolt.Tx, bucket []byte, key []byte) []byte {
+	b := tx.Bucket(bucket)
+	if b == nil || len(key) == 0 {
+		return nil
+	}
+	return b.Get(key)
+}
+
+// putIndexTx puts the key into the unique index, it fails if the key points to another record.
+// The empty keys aren't indexed.
+func putIndexTx(tx *bolt.Tx, bucket []byte, key []byte, value []byte) error {
+	if len(key) == 0 {
+		return nil
+	}
+	b, err := tx.CreateBucketIfNotExists(bucket)
+	if err != nil {
+		return err
+	}
+	if v := b.Get(key); v != nil && !bytes.Equal(v, value) {
+		return ErrKeyExists
+	}
+	return b.Put(key, value)
+}
+
+// deleteIndexTx deletes the key from the index if it points to the record
+func deleteIndexTx(tx *bolt.Tx, bucket []byte, key []byte, value []byte) error {
+	b := tx.Bucket(bucket)
+	if b == nil || len(key) == 0 {
+		return nil
+	}
+	if v := b.Get(key); v != nil && bytes.Equal(v, value) {
+		return b.Delete(key)
+	}
+	return nil
+}
+
+func indexUserTx(tx *bolt.Tx, old *User, user *User) error {
+	id := marshaluint64(user.ID)
+	if old != nil {
+		if err := unindexUserTx(tx, old); err != nil {
+			return err
+		}
+	}
+	if err := putIndexTx(tx, BUserByEmail, []byte(user.Email), id); err != nil {
+		return err
+	}
+	return putIndexTx(tx, BUserByName, []byte(user.Name), id)
+}
+
+func unindexUserTx(tx *bolt.Tx, user *User) error {
+	id := marshaluint64(user.ID)
+	if err := deleteIndexTx(tx, BUserByEmail, []byte(user.Email), id); err != nil {
+		return err
+	}
+	return deleteIndexTx(tx, BUserByName, []byte(user.Name), id)
+}
+
+func indexCollectionTx(tx *bolt.Tx, old *Collection, collection *Collection) error {
+	if old != nil {
+		if err := unindexCollectionTx(tx, old); err != nil {
+			return err
+		}
+	}
+	return putIndexTx(tx, BCollectionByName, collectionNameKey(collection.OwnerID, collection.Name), []byte(collection.ID))
+}
+
+func unindexCollectionTx(tx *bolt.Tx, collection *Collection) error {
+	return deleteIndexTx(tx, BCollectionByName, collectionNameKey(collection.OwnerID, collection.Name), []byte(collection.ID))
+}
+
+// RebuildIndexes drops and rebuilds the index buckets from the users and the collections
+func RebuildIndexes() error {
+	return cipo.Bolt().Update(rebuildIndexesTx)
+}
+
+func rebuildIndexesTx(tx *bolt.Tx) error {
+	for _, b := range indexBuckets {
+		if err := tx.DeleteBucket(b); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		if _, err := tx.CreateBucket(b); err != nil {
+			return err
+		}
+	}
+	user := User{}
+	err := cipo.IterateTx(tx, &user.ID, &user, func() error {
+		if err := indexUserTx(tx, nil, &user); err != nil {
+			log.Println("can't index user", user.ID, user.Name, user.Email, "cause:", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	collection := Collection{}
+	return cipo.IterateTx(tx, &collection.ID, &collection, func() error {
+		if err := indexCollectionTx(tx, nil, &collection); err != nil {
+			log.Println("can't index collection", collection.ID, collection.Name, "cause:", err)
+		}
+		return nil
+	})
+}
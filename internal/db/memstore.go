@@ -0,0 +1,343 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/soyersoyer/rightana/internal/db/shardbolt"
+)
+
+var (
+	errTxReadOnly = errors.New("transaction is read-only")
+	errTxClosed   = errors.New("transaction is closed")
+)
+
+// memStore is the in-memory ShardStore, the shards are only computed for the sizes and the deletes.
+// The writer is held by the writable transactions from the Begin to the Commit or Rollback
+// and by the direct writes, so there is one writer at a time like in bolt.
+type memStore struct {
+	mapFn   func([]byte) string
+	writer  sync.Mutex
+	mutex   sync.RWMutex
+	buckets map[string]*memBucket
+}
+
+// memBucket is a sorted key-value set
+type memBucket struct {
+	keys   []string
+	values map[string][]byte
+}
+
+type memEntry struct {
+	key   []byte
+	value []byte
+}
+
+func newMemStore(mapFn func([]byte) string) *memStore {
+	return &memStore{mapFn: mapFn, buckets: map[string]*memBucket{}}
+}
+
+func (b *memBucket) put(key string, value []byte) {
+	if _, ok := b.values[key]; !ok {
+		i := sort.SearchStrings(b.keys, key)
+		b.keys = append(b.keys, "")
+		copy(b.keys[i+1:], b.keys[i:])
+		b.keys[i] = key
+	}
+	b.values[key] = value
+}
+
+func (b *memBucket) delete(key string) bool {
+	if _, ok := b.values[key]; !ok {
+		return false
+	}
+	i := sort.SearchStrings(b.keys, key)
+	b.keys = append(b.keys[:i], b.keys[i+1:]...)
+	delete(b.values, key)
+	return true
+}
+
+// scan returns the entries from the key while fn accepts them
+func (b *memBucket) scan(from string, fn func(key string) bool) []memEntry {
+	entries := []memEntry{}
+	for i := sort.SearchStrings(b.keys, from); i < len(b.keys) && fn(b.keys[i]); i++ {
+		entries = append(entries, memEntry{[]byte(b.keys[i]), b.values[b.keys[i]]})
+	}
+	return entries
+}
+
+func (s *memStore) bucket(name []byte, create bool) *memBucket {
+	b := s.buckets[string(name)]
+	if b == nil && create {
+		b = &memBucket{values: map[string][]byte{}}
+		s.buckets[string(name)] = b
+	}
+	return b
+}
+
+func (s *memStore) get(bucket []byte, key []byte) []byte {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if b := s.bucket(bucket, false); b != nil {
+		return b.values[string(key)]
+	}
+	return nil
+}
+
+// scan copies the matching entries, so fn can use the store
+func (s *memStore) scan(bucket []byte, from []byte, match func(key string) bool) []memEntry {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	b := s.bucket(bucket, false)
+	if b == nil {
+		return nil
+	}
+	return b.scan(string(from), match)
+}
+
+func (s *memStore) Get(bucket []byte, key []byte) ([]byte, error) {
+	v := s.get(bucket, key)
+	if v == nil {
+		return nil, fmt.Errorf("key not found %v", key)
+	}
+	return v, nil
+}
+
+func (s *memStore) Iterate(bucket []byte, fromKey []byte, toKey []byte, fn func(k []byte, v []byte)) {
+	to := string(toKey)
+	for _, e := range s.scan(bucket, fromKey, func(key string) bool { return key < to }) {
+		fn(e.key, e.value)
+	}
+}
+
+func (s *memStore) IteratePrefix(bucket []byte, prefixKey []byte, fn func(k []byte, v []byte)) {
+	prefix := string(prefixKey)
+	for _, e := range s.scan(bucket, prefixKey, func(key string) bool { return strings.HasPrefix(key, prefix) }) {
+		fn(e.key, e.value)
+	}
+}
+
+func (s *memStore) Begin(writeable bool) ShardTx {
+	if writeable {
+		s.writer.Lock()
+	}
+	return &memTx{store: s, writeable: writeable, overlay: map[string]*memOp{}}
+}
+
+func (s *memStore) Update(fn func(tx ShardTx) error) error {
+	tx := s.Begin(true)
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *memStore) BatchUpdate(key []byte, fn func(tx ShardTx) error) error {
+	return s.Update(fn)
+}
+
+func (s *memStore) BatchUpsert(bucket []byte, key []byte, value []byte) error {
+	s.writer.Lock()
+	defer s.writer.Unlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.bucket(bucket, true).put(string(key), append([]byte{}, value...))
+	return nil
+}
+
+func (s *memStore) DeleteShard(id string) error {
+	s.writer.Lock()
+	defer s.writer.Unlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	found := false
+	for _, b := range s.buckets {
+		for _, key := range append([]string{}, b.keys...) {
+			if s.mapFn([]byte(key)) == id {
+				b.delete(key)
+				found = true
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("shard not found '%v'", id)
+	}
+	return nil
+}
+
+func (s *memStore) DeleteRange(bucket []byte, fromKey []byte, toKey []byte) (int, error) {
+	s.writer.Lock()
+	defer s.writer.Unlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b := s.bucket(bucket, false)
+	if b == nil {
+		return 0, nil
+	}
+	to := string(toKey)
+	entries := b.scan(string(fromKey), func(key string) bool { return key < to })
+	for _, e := range entries {
+		b.delete(string(e.key))
+	}
+	return len(entries), nil
+}
+
+func (s *memStore) ShardIDs(fromKey []byte, toKey []byte) []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	to := string(toKey)
+	seen := map[string]bool{}
+	ids := []string{}
+	for _, b := range s.buckets {
+		b.scan(string(fromKey), func(key string) bool {
+			if id := s.mapFn([]byte(key)); key < to && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+			return key < to
+		})
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// GetSizes returns the sizes of the keys and the values by shard
+func (s *memStore) GetSizes() []shardbolt.ShardSize {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	sizes := map[string]int{}
+	for _, b := range s.buckets {
+		for _, key := range b.keys {
+			sizes[s.mapFn([]byte(key))] += len(key) + len(b.values[key])
+		}
+	}
+	ret := []shardbolt.ShardSize{}
+	for id, size := range sizes {
+		ret = append(ret, shardbolt.ShardSize{ID: id, Size: size})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret
+}
+
+func (s *memStore) Close() []error {
+	return nil
+}
+
+// memOp is a write of a transaction
+type memOp struct {
+	bucket []byte
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// memTx collects the writes and applies them at once at the commit
+type memTx struct {
+	store     *memStore
+	writeable bool
+	closed    bool
+	ops       []*memOp
+	overlay   map[string]*memOp
+}
+
+// close ends the transaction and releases the store's writer
+func (tx *memTx) close() {
+	if tx.writeable && !tx.closed {
+		tx.store.writer.Unlock()
+	}
+	tx.closed = true
+	tx.ops, tx.overlay = nil, map[string]*memOp{}
+}
+
+func (tx *memTx) write(op *memOp) error {
+	if tx.closed {
+		return errTxClosed
+	}
+	if !tx.writeable {
+		return errTxReadOnly
+	}
+	tx.ops = append(tx.ops, op)
+	tx.overlay[string(op.bucket)+"\x00"+string(op.key)] = op
+	return nil
+}
+
+func (tx *memTx) Put(bucket []byte, key []byte, value []byte) error {
+	return tx.write(&memOp{
+		bucket: append([]byte{}, bucket...),
+		key:    append([]byte{}, key...),
+		value:  append([]byte{}, value...),
+	})
+}
+
+func (tx *memTx) Get(bucket []byte, key []byte) ([]byte, error) {
+	if op, ok := tx.overlay[string(bucket)+"\x00"+string(key)]; ok {
+		if op.delete {
+			return nil, nil
+		}
+		return op.value, nil
+	}
+	return tx.store.get(bucket, key), nil
+}
+
+func (tx *memTx) Delete(bucket []byte, key []byte) error {
+	return tx.write(&memOp{
+		bucket: append([]byte{}, bucket...),
+		key:    append([]byte{}, key...),
+		delete: true,
+	})
+}
+
+func (tx *memTx) DeletePrefix(bucket []byte, prefix []byte) (int, error) {
+	keys := map[string]bool{}
+	for _, e := range tx.store.scan(bucket, prefix, func(key string) bool { return strings.HasPrefix(key, string(prefix)) }) {
+		keys[string(e.key)] = true
+	}
+	for _, op := range tx.overlay {
+		if string(op.bucket) == string(bucket) && strings.HasPrefix(string(op.key), string(prefix)) {
+			keys[string(op.key)] = !op.delete
+		}
+	}
+	deleted := 0
+	for key, exists := range keys {
+		if !exists {
+			continue
+		}
+		if err := tx.Delete(bucket, []byte(key)); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (tx *memTx) Commit() error {
+	if tx.closed {
+		return errTxClosed
+	}
+	defer tx.close()
+	s := tx.store
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, op := range tx.ops {
+		if op.delete {
+			if b := s.bucket(op.bucket, false); b != nil {
+				b.delete(string(op.key))
+			}
+		} else {
+			s.bucket(op.bucket, true).put(string(op.key), op.value)
+		}
+	}
+	return nil
+}
+
+func (tx *memTx) Rollback() error {
+	if tx.closed {
+		return errTxClosed
+	}
+	tx.close()
+	return nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"log"
+	"sort"
+
+	"github.com/soyersoyer/rightana/internal/metrics"
+)
+
+var (
+	shardUpsertDuration = metrics.NewHistogram("rightana_shard_upsert_duration_seconds",
+		"The latency of the batched shard upserts.", nil)
+
+	_ = metrics.NewGaugeFunc("rightana_shard_dbs_open",
+		"The number of the open collection shard databases.", func() []metrics.Sample {
+			dbs, _ := shardDBs.Load().(shardMap)
+			return []metrics.Sample{{Value: float64(len(dbs))}}
+		})
+
+	_ = metrics.NewGaugeFunc("rightana_shard_files_open",
+		"The number of the open shard files.", func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(shardPool.Stats().Open)}}
+		})
+
+	_ = metrics.NewCounterFunc("rightana_shard_file_hits_total",
+		"The shard accesses which found the shard file open.", func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(shardPool.Stats().Hits)}}
+		})
+
+	_ = metrics.NewCounterFunc("rightana_shard_file_misses_total",
+		"The shard accesses which had to open the shard file.", func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(shardPool.Stats().Misses)}}
+		})
+
+	_ = metrics.NewCounterFunc("rightana_shard_file_evictions_total",
+		"The shard files closed because of the MaxOpenShards limit.", func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(shardPool.Stats().Evictions)}}
+		})
+
+	_ = metrics.NewGaugeFunc("rightana_shard_size_bytes",
+		"The size of the collections' shards.", func() []metrics.Sample {
+			samples := []metrics.Sample{}
+			collections, err := GetCollections()
+			if err != nil {
+				log.Println("can't get the collections for the metrics, cause:", err)
+				return samples
+			}
+			sort.Slice(collections, func(i, j int) bool { return collections[i].ID < collections[j].ID })
+			for _, c := range collections {
+				sdb, err := getShardDB(c.ID)
+				if err != nil {
+					log.Println("can't open the shards of", c.ID, "for the metrics, cause:", err)
+					continue
+				}
+				for _, s := range sdb.GetSizes() {
+					samples = append(samples, metrics.Sample{
+						LabelValues: []string{c.ID, s.ID},
+						Value:       float64(s.Size),
+					})
+				}
+			}
+			return samples
+		}, "collection", "shard")
+)
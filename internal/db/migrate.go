@@ -0,0 +1,191 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	bolt "github.com/etcd-io/bbolt"
+)
+
+// BMeta is the bucket of the schema version, it exists in the main db and in every shard
+var BMeta = []byte("Meta")
+
+var schemaVersionKey = []byte("SchemaVersion")
+
+// Migration upgrades the main db and the shards to its version, the 0.4.1 data dirs have the version 0
+type Migration struct {
+	Version int
+	Name    string
+	Main    func(tx *bolt.Tx) error
+	Shard   func(tx *bolt.Tx) error
+}
+
+// The migrations in ascending version order, never change the released ones, add a new one instead
+var migrations = []Migration{
+	{1, "user and collection indexes", rebuildIndexesTx, nil},
+	{2, "webhook queue indexes", indexWebhookDeliveriesTx, nil},
+	{3, "hashed erasure criteria", hashErasuresTx, nil},
+}
+
+// SchemaVersion is the schema version of this build
+var SchemaVersion = migrations[len(migrations)-1].Version
+
+// MigrationPlanT lists the pending migrations
+type MigrationPlanT struct {
+	From       int      `json:"from"`
+	To         int      `json:"to"`
+	Migrations []string `json:"migrations"`
+	Shards     []string `json:"shards"`
+}
+
+// Pending tells whether something needs migration
+func (p *MigrationPlanT) Pending() bool {
+	return len(p.Migrations) > 0 || len(p.Shards) > 0
+}
+
+func getSchemaVersionTx(tx *bolt.Tx) int {
+	b := tx.Bucket(BMeta)
+	if b == nil {
+		return 0
+	}
+	v := b.Get(schemaVersionKey)
+	if v == nil {
+		return 0
+	}
+	version, err := unmarshal(v)
+	if err != nil {
+		return 0
+	}
+	return int(version)
+}
+
+func putSchemaVersionTx(tx *bolt.Tx, version int) error {
+	b, err := tx.CreateBucketIfNotExists(BMeta)
+	if err != nil {
+		return err
+	}
+	return b.Put(schemaVersionKey, marshal(uint32(version)))
+}
+
+// setSchemaVersionTx stamps the new databases with the actual schema version
+func setSchemaVersionTx(tx *bolt.Tx) error {
+	return putSchemaVersionTx(tx, SchemaVersion)
+}
+
+func checkSchemaVersion(name string, version int) error {
+	if version > SchemaVersion {
+		return fmt.Errorf("%v has the schema version %v, this build supports up to %v, upgrade rightana", name, version, SchemaVersion)
+	}
+	return nil
+}
+
+// PlanMigrations returns the migrations which are needed by the main db and the shards
+func PlanMigrations() (*MigrationPlanT, error) {
+	plan := &MigrationPlanT{To: SchemaVersion, Migrations: []string{}, Shards: []string{}}
+	err := cipo.Bolt().View(func(tx *bolt.Tx) error {
+		plan.From = getSchemaVersionTx(tx)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchemaVersion(filename, plan.From); err != nil {
+		return nil, err
+	}
+	for _, m := range migrations {
+		if m.Version > plan.From {
+			plan.Migrations = append(plan.Migrations, fmt.Sprintf("%d: %s", m.Version, m.Name))
+		}
+	}
+	collections, err := GetCollections()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range collections {
+		sdb, err := getBoltShardDB(c.ID)
+		if err == ErrStoreNotSupported {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = sdb.ViewShards(func(id string, tx *bolt.Tx) error {
+			name := c.ID + "/" + id
+			version := getSchemaVersionTx(tx)
+			if err := checkSchemaVersion(name, version); err != nil {
+				return err
+			}
+			if version < SchemaVersion {
+				plan.Shards = append(plan.Shards, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// Migrate runs the pending migrations, every step is committed with its version,
+// so a failed migration can be continued
+func Migrate() error {
+	plan, err := PlanMigrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.Version <= plan.From {
+			continue
+		}
+		log.Printf("migrating %v to %d: %s", filename, m.Version, m.Name)
+		err := cipo.Bolt().Update(func(tx *bolt.Tx) error {
+			if m.Main != nil {
+				if err := m.Main(tx); err != nil {
+					return err
+				}
+			}
+			return putSchemaVersionTx(tx, m.Version)
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %v", m.Version, m.Name, err)
+		}
+	}
+	collections, err := GetCollections()
+	if err != nil {
+		return err
+	}
+	for _, c := range collections {
+		sdb, err := getBoltShardDB(c.ID)
+		if err == ErrStoreNotSupported {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = sdb.UpdateShards(func(id string, tx *bolt.Tx) error {
+			return migrateShardTx(c.ID+"/"+id, tx)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateShardTx(name string, tx *bolt.Tx) error {
+	version := getSchemaVersionTx(tx)
+	if version >= SchemaVersion {
+		return nil
+	}
+	for _, m := range migrations {
+		if m.Version <= version || m.Shard == nil {
+			continue
+		}
+		log.Printf("migrating %v to %d: %s", name, m.Version, m.Name)
+		if err := m.Shard(tx); err != nil {
+			return fmt.Errorf("migration %d (%s) failed on %v: %v", m.Version, m.Name, name, err)
+		}
+	}
+	return putSchemaVersionTx(tx, SchemaVersion)
+}
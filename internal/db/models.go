@@ -6,6 +6,7 @@ import "time"
 type ExtSession struct {
 	Session
 	Key           string
+	RawKey        []byte
 	Begin         time.Time
 	PageviewCount int
 }
@@ -14,6 +14,15 @@ It has these top-level messages:
 	AuthToken
 	Session
 	Pageview
+	Property
+	Alert
+	AlertEvent
+	Webhook
+	WebhookDelivery
+	BackupRun
+	ExportHeader
+	ExportRecord
+	Erasure
 */
 package db
 
@@ -33,21 +42,24 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 type User struct {
-	ID                   uint64 `protobuf:"varint,1,opt,name=ID,json=iD" json:"ID,omitempty"`
-	Email                string `protobuf:"bytes,2,opt,name=Email,json=email" json:"Email,omitempty"`
-	Password             string `protobuf:"bytes,3,opt,name=Password,json=password" json:"Password,omitempty"`
-	Created              int64  `protobuf:"varint,4,opt,name=Created,json=created" json:"Created,omitempty"`
-	Name                 string `protobuf:"bytes,5,opt,name=Name,json=name" json:"Name,omitempty"`
-	IsAdmin              bool   `protobuf:"varint,10,opt,name=IsAdmin,json=isAdmin" json:"IsAdmin,omitempty"`
-	DisablePwChange      bool   `protobuf:"varint,11,opt,name=DisablePwChange,json=disablePwChange" json:"DisablePwChange,omitempty"`
-	LimitCollections     bool   `protobuf:"varint,12,opt,name=LimitCollections,json=limitCollections" json:"LimitCollections,omitempty"`
-	CollectionLimit      uint32 `protobuf:"varint,13,opt,name=CollectionLimit,json=collectionLimit" json:"CollectionLimit,omitempty"`
-	DisableUserDeletion  bool   `protobuf:"varint,14,opt,name=DisableUserDeletion,json=disableUserDeletion" json:"DisableUserDeletion,omitempty"`
-	EmailVerified        bool   `protobuf:"varint,20,opt,name=EmailVerified,json=emailVerified" json:"EmailVerified,omitempty"`
-	EmailVerificationKey string `protobuf:"bytes,21,opt,name=EmailVerificationKey,json=emailVerificationKey" json:"EmailVerificationKey,omitempty"`
-	EmailVerificationAt  int64  `protobuf:"varint,22,opt,name=EmailVerificationAt,json=emailVerificationAt" json:"EmailVerificationAt,omitempty"`
-	PasswordResetKey     string `protobuf:"bytes,23,opt,name=PasswordResetKey,json=passwordResetKey" json:"PasswordResetKey,omitempty"`
-	PasswordResetAt      int64  `protobuf:"varint,24,opt,name=PasswordResetAt,json=passwordResetAt" json:"PasswordResetAt,omitempty"`
+	ID                   uint64   `protobuf:"varint,1,opt,name=ID,json=iD" json:"ID,omitempty"`
+	Email                string   `protobuf:"bytes,2,opt,name=Email,json=email" json:"Email,omitempty"`
+	Password             string   `protobuf:"bytes,3,opt,name=Password,json=password" json:"Password,omitempty"`
+	Created              int64    `protobuf:"varint,4,opt,name=Created,json=created" json:"Created,omitempty"`
+	Name                 string   `protobuf:"bytes,5,opt,name=Name,json=name" json:"Name,omitempty"`
+	IsAdmin              bool     `protobuf:"varint,10,opt,name=IsAdmin,json=isAdmin" json:"IsAdmin,omitempty"`
+	DisablePwChange      bool     `protobuf:"varint,11,opt,name=DisablePwChange,json=disablePwChange" json:"DisablePwChange,omitempty"`
+	LimitCollections     bool     `protobuf:"varint,12,opt,name=LimitCollections,json=limitCollections" json:"LimitCollections,omitempty"`
+	CollectionLimit      uint32   `protobuf:"varint,13,opt,name=CollectionLimit,json=collectionLimit" json:"CollectionLimit,omitempty"`
+	DisableUserDeletion  bool     `protobuf:"varint,14,opt,name=DisableUserDeletion,json=disableUserDeletion" json:"DisableUserDeletion,omitempty"`
+	EmailVerified        bool     `protobuf:"varint,20,opt,name=EmailVerified,json=emailVerified" json:"EmailVerified,omitempty"`
+	EmailVerificationKey string   `protobuf:"bytes,21,opt,name=EmailVerificationKey,json=emailVerificationKey" json:"EmailVerificationKey,omitempty"`
+	EmailVerificationAt  int64    `protobuf:"varint,22,opt,name=EmailVerificationAt,json=emailVerificationAt" json:"EmailVerificationAt,omitempty"`
+	PasswordResetKey     string   `protobuf:"bytes,23,opt,name=PasswordResetKey,json=passwordResetKey" json:"PasswordResetKey,omitempty"`
+	PasswordResetAt      int64    `protobuf:"varint,24,opt,name=PasswordResetAt,json=passwordResetAt" json:"PasswordResetAt,omitempty"`
+	DigestFrequency      string   `protobuf:"bytes,30,opt,name=DigestFrequency,json=digestFrequency" json:"DigestFrequency,omitempty"`
+	DigestCollections    []string `protobuf:"bytes,31,rep,name=DigestCollections,json=digestCollections" json:"DigestCollections,omitempty"`
+	DigestSentAt         int64    `protobuf:"varint,32,opt,name=DigestSentAt,json=digestSentAt" json:"DigestSentAt,omitempty"`
 }
 
 func (m *User) Reset()                    { *m = User{} }
@@ -160,6 +172,27 @@ func (m *User) GetPasswordResetAt() int64 {
 	return 0
 }
 
+func (m *User) GetDigestFrequency() string {
+	if m != nil {
+		return m.DigestFrequency
+	}
+	return ""
+}
+
+func (m *User) GetDigestCollections() []string {
+	if m != nil {
+		return m.DigestCollections
+	}
+	return nil
+}
+
+func (m *User) GetDigestSentAt() int64 {
+	if m != nil {
+		return m.DigestSentAt
+	}
+	return 0
+}
+
 type Teammate struct {
 	ID uint64 `protobuf:"varint,1,opt,name=ID,json=iD" json:"ID,omitempty"`
 }
@@ -177,11 +210,17 @@ func (m *Teammate) GetID() uint64 {
 }
 
 type Collection struct {
-	ID        string      `protobuf:"bytes,1,opt,name=ID,json=iD" json:"ID,omitempty"`
-	OwnerID   uint64      `protobuf:"varint,2,opt,name=OwnerID,json=ownerID" json:"OwnerID,omitempty"`
-	Name      string      `protobuf:"bytes,3,opt,name=Name,json=name" json:"Name,omitempty"`
-	Teammates []*Teammate `protobuf:"bytes,4,rep,name=Teammates,json=teammates" json:"Teammates,omitempty"`
-	Created   int64       `protobuf:"varint,5,opt,name=Created,json=created" json:"Created,omitempty"`
+	ID                      string      `protobuf:"bytes,1,opt,name=ID,json=iD" json:"ID,omitempty"`
+	OwnerID                 uint64      `protobuf:"varint,2,opt,name=OwnerID,json=ownerID" json:"OwnerID,omitempty"`
+	Name                    string      `protobuf:"bytes,3,opt,name=Name,json=name" json:"Name,omitempty"`
+	Teammates               []*Teammate `protobuf:"bytes,4,rep,name=Teammates,json=teammates" json:"Teammates,omitempty"`
+	Created                 int64       `protobuf:"varint,5,opt,name=Created,json=created" json:"Created,omitempty"`
+	ShardGranularity        string      `protobuf:"bytes,6,opt,name=ShardGranularity,json=shardGranularity" json:"ShardGranularity,omitempty"`
+	SessionTimeout          int32       `protobuf:"varint,7,opt,name=SessionTimeout,json=sessionTimeout" json:"SessionTimeout,omitempty"`
+	SessionMidnightTimezone string      `protobuf:"bytes,8,opt,name=SessionMidnightTimezone,json=sessionMidnightTimezone" json:"SessionMidnightTimezone,omitempty"`
+	VisitCounter            bool        `protobuf:"varint,9,opt,name=VisitCounter,json=visitCounter" json:"VisitCounter,omitempty"`
+	MaxPropertyKeys         int32       `protobuf:"varint,10,opt,name=MaxPropertyKeys,json=maxPropertyKeys" json:"MaxPropertyKeys,omitempty"`
+	MaxPropertyValueLength  int32       `protobuf:"varint,11,opt,name=MaxPropertyValueLength,json=maxPropertyValueLength" json:"MaxPropertyValueLength,omitempty"`
 }
 
 func (m *Collection) Reset()                    { *m = Collection{} }
@@ -224,6 +263,48 @@ func (m *Collection) GetCreated() int64 {
 	return 0
 }
 
+func (m *Collection) GetShardGranularity() string {
+	if m != nil {
+		return m.ShardGranularity
+	}
+	return ""
+}
+
+func (m *Collection) GetSessionTimeout() int32 {
+	if m != nil {
+		return m.SessionTimeout
+	}
+	return 0
+}
+
+func (m *Collection) GetSessionMidnightTimezone() string {
+	if m != nil {
+		return m.SessionMidnightTimezone
+	}
+	return ""
+}
+
+func (m *Collection) GetVisitCounter() bool {
+	if m != nil {
+		return m.VisitCounter
+	}
+	return false
+}
+
+func (m *Collection) GetMaxPropertyKeys() int32 {
+	if m != nil {
+		return m.MaxPropertyKeys
+	}
+	return 0
+}
+
+func (m *Collection) GetMaxPropertyValueLength() int32 {
+	if m != nil {
+		return m.MaxPropertyValueLength
+	}
+	return 0
+}
+
 type AuthToken struct {
 	ID      string `protobuf:"bytes,1,opt,name=ID,json=iD" json:"ID,omitempty"`
 	OwnerID uint64 `protobuf:"varint,2,opt,name=OwnerID,json=ownerID" json:"OwnerID,omitempty"`
@@ -265,23 +346,28 @@ func (m *AuthToken) GetCreated() int64 {
 }
 
 type Session struct {
-	Duration         int32  `protobuf:"varint,1,opt,name=Duration,json=duration" json:"Duration,omitempty"`
-	Hostname         string `protobuf:"bytes,2,opt,name=Hostname,json=hostname" json:"Hostname,omitempty"`
-	DeviceOS         string `protobuf:"bytes,3,opt,name=DeviceOS,json=deviceOS" json:"DeviceOS,omitempty"`
-	BrowserName      string `protobuf:"bytes,4,opt,name=BrowserName,json=browserName" json:"BrowserName,omitempty"`
-	BrowserVersion   string `protobuf:"bytes,5,opt,name=BrowserVersion,json=browserVersion" json:"BrowserVersion,omitempty"`
-	BrowserLanguage  string `protobuf:"bytes,6,opt,name=BrowserLanguage,json=browserLanguage" json:"BrowserLanguage,omitempty"`
-	ScreenResolution string `protobuf:"bytes,7,opt,name=ScreenResolution,json=screenResolution" json:"ScreenResolution,omitempty"`
-	WindowResolution string `protobuf:"bytes,8,opt,name=WindowResolution,json=windowResolution" json:"WindowResolution,omitempty"`
-	DeviceType       string `protobuf:"bytes,9,opt,name=DeviceType,json=deviceType" json:"DeviceType,omitempty"`
-	CountryCode      string `protobuf:"bytes,10,opt,name=CountryCode,json=countryCode" json:"CountryCode,omitempty"`
-	City             string `protobuf:"bytes,11,opt,name=City,json=city" json:"City,omitempty"`
-	UserAgent        string `protobuf:"bytes,12,opt,name=UserAgent,json=userAgent" json:"UserAgent,omitempty"`
-	UserIP           string `protobuf:"bytes,13,opt,name=UserIP,json=userIP" json:"UserIP,omitempty"`
-	UserHostname     string `protobuf:"bytes,14,opt,name=UserHostname,json=userHostname" json:"UserHostname,omitempty"`
-	Referrer         string `protobuf:"bytes,15,opt,name=Referrer,json=referrer" json:"Referrer,omitempty"`
-	ASNumber         int32  `protobuf:"varint,16,opt,name=ASNumber,json=aSNumber" json:"ASNumber,omitempty"`
-	ASName           string `protobuf:"bytes,17,opt,name=ASName,json=aSName" json:"ASName,omitempty"`
+	Duration           int32       `protobuf:"varint,1,opt,name=Duration,json=duration" json:"Duration,omitempty"`
+	Hostname           string      `protobuf:"bytes,2,opt,name=Hostname,json=hostname" json:"Hostname,omitempty"`
+	DeviceOS           string      `protobuf:"bytes,3,opt,name=DeviceOS,json=deviceOS" json:"DeviceOS,omitempty"`
+	BrowserName        string      `protobuf:"bytes,4,opt,name=BrowserName,json=browserName" json:"BrowserName,omitempty"`
+	BrowserVersion     string      `protobuf:"bytes,5,opt,name=BrowserVersion,json=browserVersion" json:"BrowserVersion,omitempty"`
+	BrowserLanguage    string      `protobuf:"bytes,6,opt,name=BrowserLanguage,json=browserLanguage" json:"BrowserLanguage,omitempty"`
+	ScreenResolution   string      `protobuf:"bytes,7,opt,name=ScreenResolution,json=screenResolution" json:"ScreenResolution,omitempty"`
+	WindowResolution   string      `protobuf:"bytes,8,opt,name=WindowResolution,json=windowResolution" json:"WindowResolution,omitempty"`
+	DeviceType         string      `protobuf:"bytes,9,opt,name=DeviceType,json=deviceType" json:"DeviceType,omitempty"`
+	CountryCode        string      `protobuf:"bytes,10,opt,name=CountryCode,json=countryCode" json:"CountryCode,omitempty"`
+	City               string      `protobuf:"bytes,11,opt,name=City,json=city" json:"City,omitempty"`
+	UserAgent          string      `protobuf:"bytes,12,opt,name=UserAgent,json=userAgent" json:"UserAgent,omitempty"`
+	UserIP             string      `protobuf:"bytes,13,opt,name=UserIP,json=userIP" json:"UserIP,omitempty"`
+	UserHostname       string      `protobuf:"bytes,14,opt,name=UserHostname,json=userHostname" json:"UserHostname,omitempty"`
+	Referrer           string      `protobuf:"bytes,15,opt,name=Referrer,json=referrer" json:"Referrer,omitempty"`
+	ASNumber           int32       `protobuf:"varint,16,opt,name=ASNumber,json=aSNumber" json:"ASNumber,omitempty"`
+	ASName             string      `protobuf:"bytes,17,opt,name=ASName,json=aSName" json:"ASName,omitempty"`
+	LastActivity       int64       `protobuf:"varint,18,opt,name=LastActivity,json=lastActivity" json:"LastActivity,omitempty"`
+	VisitorID          uint64      `protobuf:"varint,19,opt,name=VisitorID,json=visitorID" json:"VisitorID,omitempty"`
+	VisitCount         int32       `protobuf:"varint,20,opt,name=VisitCount,json=visitCount" json:"VisitCount,omitempty"`
+	DaysSinceLastVisit int32       `protobuf:"varint,21,opt,name=DaysSinceLastVisit,json=daysSinceLastVisit" json:"DaysSinceLastVisit,omitempty"`
+	Properties         []*Property `protobuf:"bytes,22,rep,name=Properties,json=properties" json:"Properties,omitempty"`
 }
 
 func (m *Session) Reset()                    { *m = Session{} }
@@ -408,9 +494,45 @@ func (m *Session) GetASName() string {
 	return ""
 }
 
+func (m *Session) GetLastActivity() int64 {
+	if m != nil {
+		return m.LastActivity
+	}
+	return 0
+}
+
+func (m *Session) GetVisitorID() uint64 {
+	if m != nil {
+		return m.VisitorID
+	}
+	return 0
+}
+
+func (m *Session) GetVisitCount() int32 {
+	if m != nil {
+		return m.VisitCount
+	}
+	return 0
+}
+
+func (m *Session) GetDaysSinceLastVisit() int32 {
+	if m != nil {
+		return m.DaysSinceLastVisit
+	}
+	return 0
+}
+
+func (m *Session) GetProperties() []*Property {
+	if m != nil {
+		return m.Properties
+	}
+	return nil
+}
+
 type Pageview struct {
-	Path        string `protobuf:"bytes,1,opt,name=Path,json=path" json:"Path,omitempty"`
-	QueryString string `protobuf:"bytes,2,opt,name=QueryString,json=queryString" json:"QueryString,omitempty"`
+	Path        string      `protobuf:"bytes,1,opt,name=Path,json=path" json:"Path,omitempty"`
+	QueryString string      `protobuf:"bytes,2,opt,name=QueryString,json=queryString" json:"QueryString,omitempty"`
+	Properties  []*Property `protobuf:"bytes,3,rep,name=Properties,json=properties" json:"Properties,omitempty"`
 }
 
 func (m *Pageview) Reset()                    { *m = Pageview{} }
@@ -432,6 +554,645 @@ func (m *Pageview) GetQueryString() string {
 	return ""
 }
 
+func (m *Pageview) GetProperties() []*Property {
+	if m != nil {
+		return m.Properties
+	}
+	return nil
+}
+
+type Property struct {
+	Key   string `protobuf:"bytes,1,opt,name=Key,json=key" json:"Key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=Value,json=value" json:"Value,omitempty"`
+}
+
+func (m *Property) Reset()                    { *m = Property{} }
+func (m *Property) String() string            { return proto.CompactTextString(m) }
+func (*Property) ProtoMessage()               {}
+func (*Property) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *Property) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *Property) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type Alert struct {
+	ID              uint64  `protobuf:"varint,1,opt,name=ID,json=iD" json:"ID,omitempty"`
+	CollectionID    string  `protobuf:"bytes,2,opt,name=CollectionID,json=collectionID" json:"CollectionID,omitempty"`
+	Name            string  `protobuf:"bytes,3,opt,name=Name,json=name" json:"Name,omitempty"`
+	Metric          string  `protobuf:"bytes,4,opt,name=Metric,json=metric" json:"Metric,omitempty"`
+	Path            string  `protobuf:"bytes,5,opt,name=Path,json=path" json:"Path,omitempty"`
+	WindowMinutes   int32   `protobuf:"varint,6,opt,name=WindowMinutes,json=windowMinutes" json:"WindowMinutes,omitempty"`
+	Condition       string  `protobuf:"bytes,7,opt,name=Condition,json=condition" json:"Condition,omitempty"`
+	Threshold       float64 `protobuf:"fixed64,8,opt,name=Threshold,json=threshold" json:"Threshold,omitempty"`
+	CooldownMinutes int32   `protobuf:"varint,9,opt,name=CooldownMinutes,json=cooldownMinutes" json:"CooldownMinutes,omitempty"`
+	Email           string  `protobuf:"bytes,10,opt,name=Email,json=email" json:"Email,omitempty"`
+	WebhookURL      string  `protobuf:"bytes,11,opt,name=WebhookURL,json=webhookURL" json:"WebhookURL,omitempty"`
+	Disabled        bool    `protobuf:"varint,12,opt,name=Disabled,json=disabled" json:"Disabled,omitempty"`
+	LastFired       int64   `protobuf:"varint,13,opt,name=LastFired,json=lastFired" json:"LastFired,omitempty"`
+	Created         int64   `protobuf:"varint,14,opt,name=Created,json=created" json:"Created,omitempty"`
+}
+
+func (m *Alert) Reset()                    { *m = Alert{} }
+func (m *Alert) String() string            { return proto.CompactTextString(m) }
+func (*Alert) ProtoMessage()               {}
+func (*Alert) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *Alert) GetID() uint64 {
+	if m != nil {
+		return m.ID
+	}
+	return 0
+}
+
+func (m *Alert) GetCollectionID() string {
+	if m != nil {
+		return m.CollectionID
+	}
+	return ""
+}
+
+func (m *Alert) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Alert) GetMetric() string {
+	if m != nil {
+		return m.Metric
+	}
+	return ""
+}
+
+func (m *Alert) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *Alert) GetWindowMinutes() int32 {
+	if m != nil {
+		return m.WindowMinutes
+	}
+	return 0
+}
+
+func (m *Alert) GetCondition() string {
+	if m != nil {
+		return m.Condition
+	}
+	return ""
+}
+
+func (m *Alert) GetThreshold() float64 {
+	if m != nil {
+		return m.Threshold
+	}
+	return 0
+}
+
+func (m *Alert) GetCooldownMinutes() int32 {
+	if m != nil {
+		return m.CooldownMinutes
+	}
+	return 0
+}
+
+func (m *Alert) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *Alert) GetWebhookURL() string {
+	if m != nil {
+		return m.WebhookURL
+	}
+	return ""
+}
+
+func (m *Alert) GetDisabled() bool {
+	if m != nil {
+		return m.Disabled
+	}
+	return false
+}
+
+func (m *Alert) GetLastFired() int64 {
+	if m != nil {
+		return m.LastFired
+	}
+	return 0
+}
+
+func (m *Alert) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+type AlertEvent struct {
+	ID           uint64  `protobuf:"varint,1,opt,name=ID,json=iD" json:"ID,omitempty"`
+	AlertID      uint64  `protobuf:"varint,2,opt,name=AlertID,json=alertID" json:"AlertID,omitempty"`
+	CollectionID string  `protobuf:"bytes,3,opt,name=CollectionID,json=collectionID" json:"CollectionID,omitempty"`
+	Fired        int64   `protobuf:"varint,4,opt,name=Fired,json=fired" json:"Fired,omitempty"`
+	Value        float64 `protobuf:"fixed64,5,opt,name=Value,json=value" json:"Value,omitempty"`
+	Reference    float64 `protobuf:"fixed64,6,opt,name=Reference,json=reference" json:"Reference,omitempty"`
+	Message      string  `protobuf:"bytes,7,opt,name=Message,json=message" json:"Message,omitempty"`
+	Error        string  `protobuf:"bytes,8,opt,name=Error,json=error" json:"Error,omitempty"`
+}
+
+func (m *AlertEvent) Reset()                    { *m = AlertEvent{} }
+func (m *AlertEvent) String() string            { return proto.CompactTextString(m) }
+func (*AlertEvent) ProtoMessage()               {}
+func (*AlertEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *AlertEvent) GetID() uint64 {
+	if m != nil {
+		return m.ID
+	}
+	return 0
+}
+
+func (m *AlertEvent) GetAlertID() uint64 {
+	if m != nil {
+		return m.AlertID
+	}
+	return 0
+}
+
+func (m *AlertEvent) GetCollectionID() string {
+	if m != nil {
+		return m.CollectionID
+	}
+	return ""
+}
+
+func (m *AlertEvent) GetFired() int64 {
+	if m != nil {
+		return m.Fired
+	}
+	return 0
+}
+
+func (m *AlertEvent) GetValue() float64 {
+	if m != nil {
+		return m.Value
+	}
+	return 0
+}
+
+func (m *AlertEvent) GetReference() float64 {
+	if m != nil {
+		return m.Reference
+	}
+	return 0
+}
+
+func (m *AlertEvent) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *AlertEvent) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type Webhook struct {
+	ID            uint64   `protobuf:"varint,1,opt,name=ID,json=iD" json:"ID,omitempty"`
+	CollectionID  string   `protobuf:"bytes,2,opt,name=CollectionID,json=collectionID" json:"CollectionID,omitempty"`
+	URL           string   `protobuf:"bytes,3,opt,name=URL,json=uRL" json:"URL,omitempty"`
+	Secret        string   `protobuf:"bytes,4,opt,name=Secret,json=secret" json:"Secret,omitempty"`
+	Events        []string `protobuf:"bytes,5,rep,name=Events,json=events" json:"Events,omitempty"`
+	Disabled      bool     `protobuf:"varint,6,opt,name=Disabled,json=disabled" json:"Disabled,omitempty"`
+	SummarySentAt int64    `protobuf:"varint,7,opt,name=SummarySentAt,json=summarySentAt" json:"SummarySentAt,omitempty"`
+	Created       int64    `protobuf:"varint,8,opt,name=Created,json=created" json:"Created,omitempty"`
+}
+
+func (m *Webhook) Reset()                    { *m = Webhook{} }
+func (m *Webhook) String() string            { return proto.CompactTextString(m) }
+func (*Webhook) ProtoMessage()               {}
+func (*Webhook) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *Webhook) GetID() uint64 {
+	if m != nil {
+		return m.ID
+	}
+	return 0
+}
+
+func (m *Webhook) GetCollectionID() string {
+	if m != nil {
+		return m.CollectionID
+	}
+	return ""
+}
+
+func (m *Webhook) GetURL() string {
+	if m != nil {
+		return m.URL
+	}
+	return ""
+}
+
+func (m *Webhook) GetSecret() string {
+	if m != nil {
+		return m.Secret
+	}
+	return ""
+}
+
+func (m *Webhook) GetEvents() []string {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+func (m *Webhook) GetDisabled() bool {
+	if m != nil {
+		return m.Disabled
+	}
+	return false
+}
+
+func (m *Webhook) GetSummarySentAt() int64 {
+	if m != nil {
+		return m.SummarySentAt
+	}
+	return 0
+}
+
+func (m *Webhook) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+type WebhookDelivery struct {
+	ID           uint64 `protobuf:"varint,1,opt,name=ID,json=iD" json:"ID,omitempty"`
+	WebhookID    uint64 `protobuf:"varint,2,opt,name=WebhookID,json=webhookID" json:"WebhookID,omitempty"`
+	CollectionID string `protobuf:"bytes,3,opt,name=CollectionID,json=collectionID" json:"CollectionID,omitempty"`
+	Event        string `protobuf:"bytes,4,opt,name=Event,json=event" json:"Event,omitempty"`
+	Payload      []byte `protobuf:"bytes,5,opt,name=Payload,json=payload" json:"Payload,omitempty"`
+	Attempts     int32  `protobuf:"varint,6,opt,name=Attempts,json=attempts" json:"Attempts,omitempty"`
+	NextAttempt  int64  `protobuf:"varint,7,opt,name=NextAttempt,json=nextAttempt" json:"NextAttempt,omitempty"`
+	LastError    string `protobuf:"bytes,8,opt,name=LastError,json=lastError" json:"LastError,omitempty"`
+	Created      int64  `protobuf:"varint,9,opt,name=Created,json=created" json:"Created,omitempty"`
+}
+
+func (m *WebhookDelivery) Reset()                    { *m = WebhookDelivery{} }
+func (m *WebhookDelivery) String() string            { return proto.CompactTextString(m) }
+func (*WebhookDelivery) ProtoMessage()               {}
+func (*WebhookDelivery) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *WebhookDelivery) GetID() uint64 {
+	if m != nil {
+		return m.ID
+	}
+	return 0
+}
+
+func (m *WebhookDelivery) GetWebhookID() uint64 {
+	if m != nil {
+		return m.WebhookID
+	}
+	return 0
+}
+
+func (m *WebhookDelivery) GetCollectionID() string {
+	if m != nil {
+		return m.CollectionID
+	}
+	return ""
+}
+
+func (m *WebhookDelivery) GetEvent() string {
+	if m != nil {
+		return m.Event
+	}
+	return ""
+}
+
+func (m *WebhookDelivery) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *WebhookDelivery) GetAttempts() int32 {
+	if m != nil {
+		return m.Attempts
+	}
+	return 0
+}
+
+func (m *WebhookDelivery) GetNextAttempt() int64 {
+	if m != nil {
+		return m.NextAttempt
+	}
+	return 0
+}
+
+func (m *WebhookDelivery) GetLastError() string {
+	if m != nil {
+		return m.LastError
+	}
+	return ""
+}
+
+func (m *WebhookDelivery) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+type BackupRun struct {
+	ID       uint64 `protobuf:"varint,1,opt,name=ID,json=iD" json:"ID,omitempty"`
+	BackupID string `protobuf:"bytes,2,opt,name=BackupID,json=backupID" json:"BackupID,omitempty"`
+	Started  int64  `protobuf:"varint,3,opt,name=Started,json=started" json:"Started,omitempty"`
+	Finished int64  `protobuf:"varint,4,opt,name=Finished,json=finished" json:"Finished,omitempty"`
+	Path     string `protobuf:"bytes,5,opt,name=Path,json=path" json:"Path,omitempty"`
+	Size     int64  `protobuf:"varint,6,opt,name=Size,json=size" json:"Size,omitempty"`
+	Error    string `protobuf:"bytes,7,opt,name=Error,json=error" json:"Error,omitempty"`
+}
+
+func (m *BackupRun) Reset()                    { *m = BackupRun{} }
+func (m *BackupRun) String() string            { return proto.CompactTextString(m) }
+func (*BackupRun) ProtoMessage()               {}
+func (*BackupRun) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *BackupRun) GetID() uint64 {
+	if m != nil {
+		return m.ID
+	}
+	return 0
+}
+
+func (m *BackupRun) GetBackupID() string {
+	if m != nil {
+		return m.BackupID
+	}
+	return ""
+}
+
+func (m *BackupRun) GetStarted() int64 {
+	if m != nil {
+		return m.Started
+	}
+	return 0
+}
+
+func (m *BackupRun) GetFinished() int64 {
+	if m != nil {
+		return m.Finished
+	}
+	return 0
+}
+
+func (m *BackupRun) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *BackupRun) GetSize() int64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *BackupRun) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type ExportHeader struct {
+	Version          uint32 `protobuf:"varint,1,opt,name=Version,json=version" json:"Version,omitempty"`
+	CollectionID     string `protobuf:"bytes,2,opt,name=CollectionID,json=collectionID" json:"CollectionID,omitempty"`
+	Name             string `protobuf:"bytes,3,opt,name=Name,json=name" json:"Name,omitempty"`
+	Created          int64  `protobuf:"varint,4,opt,name=Created,json=created" json:"Created,omitempty"`
+	Exported         int64  `protobuf:"varint,5,opt,name=Exported,json=exported" json:"Exported,omitempty"`
+	ShardGranularity string `protobuf:"bytes,6,opt,name=ShardGranularity,json=shardGranularity" json:"ShardGranularity,omitempty"`
+}
+
+func (m *ExportHeader) Reset()                    { *m = ExportHeader{} }
+func (m *ExportHeader) String() string            { return proto.CompactTextString(m) }
+func (*ExportHeader) ProtoMessage()               {}
+func (*ExportHeader) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *ExportHeader) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *ExportHeader) GetCollectionID() string {
+	if m != nil {
+		return m.CollectionID
+	}
+	return ""
+}
+
+func (m *ExportHeader) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ExportHeader) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+func (m *ExportHeader) GetExported() int64 {
+	if m != nil {
+		return m.Exported
+	}
+	return 0
+}
+
+func (m *ExportHeader) GetShardGranularity() string {
+	if m != nil {
+		return m.ShardGranularity
+	}
+	return ""
+}
+
+type ExportRecord struct {
+	Key      []byte    `protobuf:"bytes,1,opt,name=Key,json=key" json:"Key,omitempty"`
+	Session  *Session  `protobuf:"bytes,2,opt,name=Session,json=session" json:"Session,omitempty"`
+	Pageview *Pageview `protobuf:"bytes,3,opt,name=Pageview,json=pageview" json:"Pageview,omitempty"`
+}
+
+func (m *ExportRecord) Reset()                    { *m = ExportRecord{} }
+func (m *ExportRecord) String() string            { return proto.CompactTextString(m) }
+func (*ExportRecord) ProtoMessage()               {}
+func (*ExportRecord) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *ExportRecord) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *ExportRecord) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+func (m *ExportRecord) GetPageview() *Pageview {
+	if m != nil {
+		return m.Pageview
+	}
+	return nil
+}
+
+type Erasure struct {
+	ID           uint64 `protobuf:"varint,1,opt,name=ID,json=iD" json:"ID,omitempty"`
+	CollectionID string `protobuf:"bytes,2,opt,name=CollectionID,json=collectionID" json:"CollectionID,omitempty"`
+	Actor        string `protobuf:"bytes,3,opt,name=Actor,json=actor" json:"Actor,omitempty"`
+	Created      int64  `protobuf:"varint,4,opt,name=Created,json=created" json:"Created,omitempty"`
+	SessionKey   string `protobuf:"bytes,5,opt,name=SessionKey,json=sessionKey" json:"SessionKey,omitempty"`
+	IP           string `protobuf:"bytes,6,opt,name=IP,json=iP" json:"IP,omitempty"`
+	IPRange      string `protobuf:"bytes,7,opt,name=IPRange,json=iPRange" json:"IPRange,omitempty"`
+	From         int64  `protobuf:"varint,8,opt,name=From,json=from" json:"From,omitempty"`
+	To           int64  `protobuf:"varint,9,opt,name=To,json=to" json:"To,omitempty"`
+	Sessions     int32  `protobuf:"varint,10,opt,name=Sessions,json=sessions" json:"Sessions,omitempty"`
+	Pageviews    int32  `protobuf:"varint,11,opt,name=Pageviews,json=pageviews" json:"Pageviews,omitempty"`
+	Criterion    string `protobuf:"bytes,12,opt,name=Criterion,json=criterion" json:"Criterion,omitempty"`
+	CriteriaHash string `protobuf:"bytes,13,opt,name=CriteriaHash,json=criteriaHash" json:"CriteriaHash,omitempty"`
+}
+
+func (m *Erasure) Reset()                    { *m = Erasure{} }
+func (m *Erasure) String() string            { return proto.CompactTextString(m) }
+func (*Erasure) ProtoMessage()               {}
+func (*Erasure) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *Erasure) GetID() uint64 {
+	if m != nil {
+		return m.ID
+	}
+	return 0
+}
+
+func (m *Erasure) GetCollectionID() string {
+	if m != nil {
+		return m.CollectionID
+	}
+	return ""
+}
+
+func (m *Erasure) GetActor() string {
+	if m != nil {
+		return m.Actor
+	}
+	return ""
+}
+
+func (m *Erasure) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+func (m *Erasure) GetSessionKey() string {
+	if m != nil {
+		return m.SessionKey
+	}
+	return ""
+}
+
+func (m *Erasure) GetIP() string {
+	if m != nil {
+		return m.IP
+	}
+	return ""
+}
+
+func (m *Erasure) GetIPRange() string {
+	if m != nil {
+		return m.IPRange
+	}
+	return ""
+}
+
+func (m *Erasure) GetFrom() int64 {
+	if m != nil {
+		return m.From
+	}
+	return 0
+}
+
+func (m *Erasure) GetTo() int64 {
+	if m != nil {
+		return m.To
+	}
+	return 0
+}
+
+func (m *Erasure) GetSessions() int32 {
+	if m != nil {
+		return m.Sessions
+	}
+	return 0
+}
+
+func (m *Erasure) GetPageviews() int32 {
+	if m != nil {
+		return m.Pageviews
+	}
+	return 0
+}
+
+func (m *Erasure) GetCriterion() string {
+	if m != nil {
+		return m.Criterion
+	}
+	return ""
+}
+
+func (m *Erasure) GetCriteriaHash() string {
+	if m != nil {
+		return m.CriteriaHash
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*User)(nil), "db.User")
 	proto.RegisterType((*Teammate)(nil), "db.Teammate")
@@ -439,56 +1200,123 @@ func init() {
 	proto.RegisterType((*AuthToken)(nil), "db.AuthToken")
 	proto.RegisterType((*Session)(nil), "db.Session")
 	proto.RegisterType((*Pageview)(nil), "db.Pageview")
+	proto.RegisterType((*Property)(nil), "db.Property")
+	proto.RegisterType((*Alert)(nil), "db.Alert")
+	proto.RegisterType((*AlertEvent)(nil), "db.AlertEvent")
+	proto.RegisterType((*Webhook)(nil), "db.Webhook")
+	proto.RegisterType((*WebhookDelivery)(nil), "db.WebhookDelivery")
+	proto.RegisterType((*BackupRun)(nil), "db.BackupRun")
+	proto.RegisterType((*ExportHeader)(nil), "db.ExportHeader")
+	proto.RegisterType((*ExportRecord)(nil), "db.ExportRecord")
+	proto.RegisterType((*Erasure)(nil), "db.Erasure")
 }
 
 func init() { proto.RegisterFile("models.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 721 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xdb, 0x8e, 0x1a, 0x39,
-	0x10, 0x15, 0xd0, 0x40, 0xb7, 0xb9, 0xae, 0x87, 0x9d, 0xb5, 0x46, 0xab, 0x15, 0x42, 0xab, 0x15,
-	0x9a, 0x87, 0xd1, 0x6a, 0xf2, 0x03, 0x21, 0x10, 0x29, 0x28, 0x68, 0x86, 0x18, 0x32, 0x79, 0x76,
-	0x77, 0xd7, 0x80, 0x95, 0xbe, 0x10, 0xdb, 0x3d, 0x88, 0xbf, 0xc8, 0x47, 0xe4, 0x25, 0x7f, 0x19,
-	0xb9, 0xba, 0x19, 0x2e, 0x93, 0x3c, 0xe4, 0xf1, 0x9c, 0x3a, 0x76, 0xb9, 0xaa, 0x4e, 0x99, 0x34,
-	0xe3, 0x34, 0x84, 0x48, 0xdf, 0x6c, 0x54, 0x6a, 0x52, 0x5a, 0x0e, 0xfd, 0xc1, 0x37, 0x87, 0x38,
-	0x1f, 0x35, 0x28, 0xda, 0x26, 0xe5, 0xe9, 0x84, 0x95, 0xfa, 0xa5, 0xa1, 0xc3, 0xcb, 0x72, 0x42,
-	0x7b, 0xa4, 0xfa, 0x36, 0x16, 0x32, 0x62, 0xe5, 0x7e, 0x69, 0xe8, 0xf1, 0x2a, 0x58, 0x40, 0xaf,
-	0x88, 0x3b, 0x17, 0x5a, 0x6f, 0x53, 0x15, 0xb2, 0x0a, 0x06, 0xdc, 0x4d, 0x81, 0x29, 0x23, 0xf5,
-	0xb1, 0x02, 0x61, 0x20, 0x64, 0x4e, 0xbf, 0x34, 0xac, 0xf0, 0x7a, 0x90, 0x43, 0x4a, 0x89, 0x73,
-	0x27, 0x62, 0x60, 0x55, 0x3c, 0xe1, 0x24, 0x22, 0x06, 0xab, 0x9e, 0xea, 0x51, 0x18, 0xcb, 0x84,
-	0x91, 0x7e, 0x69, 0xe8, 0xf2, 0xba, 0xcc, 0x21, 0x1d, 0x92, 0xce, 0x44, 0x6a, 0xe1, 0x47, 0x30,
-	0xdf, 0x8e, 0xd7, 0x22, 0x59, 0x01, 0x6b, 0xa0, 0xa2, 0x13, 0x9e, 0xd2, 0xf4, 0x9a, 0x74, 0x67,
-	0x32, 0x96, 0x66, 0x9c, 0x46, 0x11, 0x04, 0x46, 0xa6, 0x89, 0x66, 0x4d, 0x94, 0x76, 0xa3, 0x33,
-	0xde, 0xde, 0x7a, 0x80, 0x78, 0x8a, 0xb5, 0xfa, 0xa5, 0x61, 0x8b, 0x77, 0x82, 0x53, 0x9a, 0xfe,
-	0x4f, 0x2e, 0x8a, 0xfc, 0xb6, 0x31, 0x13, 0x88, 0xc0, 0xc6, 0x58, 0x1b, 0x2f, 0xbe, 0x08, 0x5f,
-	0x86, 0xe8, 0xbf, 0xa4, 0x85, 0xbd, 0x7a, 0x00, 0x25, 0x1f, 0x25, 0x84, 0xac, 0x87, 0xda, 0x16,
-	0x1c, 0x93, 0xf4, 0x96, 0xf4, 0x8e, 0x54, 0x81, 0xb0, 0x47, 0xdf, 0xc3, 0x8e, 0xfd, 0x89, 0x5d,
-	0xe9, 0xc1, 0x4f, 0x62, 0xf6, 0x2d, 0x2f, 0xce, 0x8c, 0x0c, 0xbb, 0xc4, 0xfe, 0x5e, 0xc0, 0xcb,
-	0x90, 0xed, 0xc9, 0x7e, 0x42, 0x1c, 0x34, 0x18, 0x9b, 0xe1, 0x2f, 0xcc, 0xd0, 0xdd, 0x9c, 0xf1,
-	0xb6, 0x27, 0x27, 0xda, 0x91, 0x61, 0x0c, 0x6f, 0xee, 0x6c, 0x4e, 0xe9, 0xc1, 0x15, 0x71, 0x97,
-	0x20, 0xe2, 0x58, 0x18, 0x38, 0x77, 0xca, 0xe0, 0x6b, 0x89, 0x90, 0x43, 0x6b, 0x8f, 0xc2, 0x1e,
-	0x1a, 0x89, 0x91, 0xfa, 0xfd, 0x36, 0x01, 0x35, 0x9d, 0xa0, 0x95, 0x1c, 0x5e, 0x4f, 0x73, 0xf8,
-	0x6c, 0x8b, 0xca, 0x91, 0x2d, 0xae, 0x89, 0xb7, 0x4f, 0xa4, 0x99, 0xd3, 0xaf, 0x0c, 0x1b, 0xb7,
-	0xcd, 0x9b, 0xd0, 0xbf, 0xd9, 0x93, 0xdc, 0x33, 0xfb, 0xf0, 0xb1, 0xe1, 0xaa, 0x27, 0x86, 0x1b,
-	0x08, 0xe2, 0x8d, 0x32, 0xb3, 0x5e, 0xa6, 0x9f, 0xe1, 0x77, 0x1e, 0xd4, 0x25, 0x95, 0xe5, 0x72,
-	0x86, 0xef, 0xa9, 0xf2, 0x8a, 0x59, 0xce, 0x7e, 0xed, 0xe9, 0xc1, 0x77, 0x87, 0xd4, 0x17, 0xa0,
-	0xb5, 0x2d, 0xf9, 0x8a, 0xb8, 0x93, 0x4c, 0xe1, 0x04, 0x30, 0x4f, 0x95, 0xbb, 0x61, 0x81, 0x6d,
-	0xec, 0x5d, 0xaa, 0x8d, 0x2d, 0xae, 0x58, 0x25, 0x77, 0x5d, 0x60, 0x3c, 0x07, 0x4f, 0x32, 0x80,
-	0xfb, 0xc5, 0x7e, 0x9b, 0xc2, 0x02, 0xd3, 0x3e, 0x69, 0xbc, 0x51, 0xe9, 0x56, 0x83, 0xc2, 0x1e,
-	0x39, 0x18, 0x6e, 0xf8, 0x07, 0x8a, 0xfe, 0x47, 0xda, 0x85, 0xe2, 0x01, 0x94, 0x7d, 0x47, 0xb1,
-	0x5f, 0x6d, 0xff, 0x84, 0xb5, 0x53, 0x2e, 0x74, 0x33, 0x91, 0xac, 0x32, 0xb1, 0x02, 0x56, 0x43,
-	0x61, 0xc7, 0x3f, 0xa5, 0xad, 0x77, 0x16, 0x81, 0x02, 0x48, 0x38, 0xe8, 0x34, 0xca, 0xb0, 0x9e,
-	0x7a, 0xee, 0x1d, 0x7d, 0xc6, 0x5b, 0xed, 0x27, 0x99, 0x84, 0xe9, 0xf6, 0x48, 0xeb, 0xe6, 0xda,
-	0xed, 0x19, 0x4f, 0xff, 0x21, 0x24, 0xaf, 0x73, 0xb9, 0xdb, 0x00, 0xf3, 0x50, 0x45, 0xc2, 0x67,
-	0xc6, 0xd6, 0x3a, 0x4e, 0xb3, 0xc4, 0xa8, 0xdd, 0x38, 0x0d, 0x01, 0xff, 0x03, 0x8f, 0x37, 0x82,
-	0x03, 0x65, 0xad, 0x32, 0x96, 0x66, 0x87, 0x1f, 0x81, 0xc7, 0x9d, 0x40, 0x9a, 0x1d, 0xfd, 0x9b,
-	0x78, 0x76, 0x0b, 0x47, 0x2b, 0x48, 0x0c, 0xae, 0xbd, 0xc7, 0xbd, 0x6c, 0x4f, 0xd0, 0x4b, 0x52,
-	0xb3, 0xd1, 0xe9, 0x1c, 0xd7, 0xdc, 0xe3, 0xb5, 0x0c, 0x11, 0x1d, 0x90, 0xa6, 0xe5, 0x9f, 0x67,
-	0xd2, 0xc6, 0x68, 0x33, 0x3b, 0xe2, 0xec, 0x5c, 0x38, 0x3c, 0x82, 0x52, 0xa0, 0x58, 0x27, 0x9f,
-	0x8b, 0x2a, 0xb0, 0x8d, 0x8d, 0x16, 0x77, 0x59, 0xec, 0x83, 0x62, 0xdd, 0x7c, 0xd6, 0xa2, 0xc0,
-	0x36, 0xe7, 0x68, 0x81, 0xe3, 0xfa, 0x23, 0xcf, 0x29, 0x10, 0x0d, 0x5e, 0xdb, 0x5f, 0x73, 0x05,
-	0x4f, 0x12, 0xb6, 0xb6, 0x92, 0xb9, 0x30, 0xeb, 0xc2, 0x8f, 0xce, 0x46, 0x98, 0xb5, 0xad, 0xff,
-	0x43, 0x06, 0x6a, 0xb7, 0x30, 0x4a, 0x26, 0xab, 0xc2, 0x26, 0x8d, 0x2f, 0x07, 0xca, 0xaf, 0xe1,
-	0x8f, 0xfd, 0xea, 0x47, 0x00, 0x00, 0x00, 0xff, 0xff, 0x4f, 0x92, 0x45, 0xcb, 0xc1, 0x05, 0x00,
-	0x00,
+	// 1662 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x57, 0xdd, 0x8e, 0x1a, 0xcb,
+	0x11, 0x16, 0x0b, 0x03, 0x4c, 0xc3, 0x2e, 0xeb, 0xf1, 0x66, 0x3d, 0xb2, 0x8e, 0x1c, 0x84, 0x9c,
+	0x08, 0x59, 0x47, 0x56, 0xe4, 0x48, 0x51, 0x6e, 0x39, 0xbb, 0x76, 0x6c, 0x9d, 0x5d, 0x1f, 0xd2,
+	0x70, 0x7c, 0xae, 0x9b, 0x99, 0x5a, 0x68, 0xed, 0xfc, 0xe0, 0xee, 0x1e, 0x30, 0x7e, 0x83, 0x5c,
+	0xe6, 0x0d, 0x72, 0x91, 0xbb, 0xbc, 0x45, 0x9e, 0x21, 0x52, 0x2e, 0xf2, 0x04, 0x79, 0x8b, 0xa8,
+	0xaa, 0x7b, 0x60, 0x80, 0x3d, 0x49, 0xec, 0x3b, 0xea, 0xab, 0x66, 0xaa, 0xab, 0xbe, 0xaf, 0x6a,
+	0x6a, 0x58, 0x37, 0xcd, 0x63, 0x48, 0xf4, 0xcb, 0xa5, 0xca, 0x4d, 0x1e, 0x9c, 0xc4, 0xb3, 0xc1,
+	0x5f, 0x3c, 0xd6, 0xf8, 0x51, 0x83, 0x0a, 0xce, 0xd8, 0xc9, 0xbb, 0xeb, 0xb0, 0xd6, 0xaf, 0x0d,
+	0x1b, 0xfc, 0x44, 0x5e, 0x07, 0x17, 0xcc, 0x7b, 0x9d, 0x0a, 0x99, 0x84, 0x27, 0xfd, 0xda, 0xd0,
+	0xe7, 0x1e, 0xa0, 0x11, 0x3c, 0x65, 0xed, 0xb1, 0xd0, 0x7a, 0x9d, 0xab, 0x38, 0xac, 0x93, 0xa3,
+	0xbd, 0x74, 0x76, 0x10, 0xb2, 0xd6, 0x95, 0x02, 0x61, 0x20, 0x0e, 0x1b, 0xfd, 0xda, 0xb0, 0xce,
+	0x5b, 0x91, 0x35, 0x83, 0x80, 0x35, 0xde, 0x8b, 0x14, 0x42, 0x8f, 0xfe, 0xd1, 0xc8, 0x44, 0x0a,
+	0x78, 0xfa, 0x9d, 0x1e, 0xc5, 0xa9, 0xcc, 0x42, 0xd6, 0xaf, 0x0d, 0xdb, 0xbc, 0x25, 0xad, 0x19,
+	0x0c, 0x59, 0xef, 0x5a, 0x6a, 0x31, 0x4b, 0x60, 0xbc, 0xbe, 0x5a, 0x88, 0x6c, 0x0e, 0x61, 0x87,
+	0x4e, 0xf4, 0xe2, 0x7d, 0x38, 0x78, 0xc1, 0xce, 0x6f, 0x64, 0x2a, 0xcd, 0x55, 0x9e, 0x24, 0x10,
+	0x19, 0x99, 0x67, 0x3a, 0xec, 0xd2, 0xd1, 0xf3, 0xe4, 0x00, 0xc7, 0xa7, 0xee, 0x4c, 0xfa, 0x57,
+	0x78, 0xda, 0xaf, 0x0d, 0x4f, 0x79, 0x2f, 0xda, 0x87, 0x83, 0xdf, 0xb0, 0xc7, 0x2e, 0x3e, 0x16,
+	0xe6, 0x1a, 0x12, 0x40, 0x5f, 0x78, 0x46, 0x0f, 0x7e, 0x1c, 0x1f, 0xbb, 0x82, 0xe7, 0xec, 0x94,
+	0x6a, 0xf5, 0x01, 0x94, 0xbc, 0x93, 0x10, 0x87, 0x17, 0x74, 0xf6, 0x14, 0xaa, 0x60, 0xf0, 0x8a,
+	0x5d, 0x54, 0x4e, 0x45, 0x02, 0xff, 0xfa, 0x3d, 0x6c, 0xc2, 0x5f, 0x50, 0x55, 0x2e, 0xe0, 0x01,
+	0x1f, 0xde, 0xe5, 0xe8, 0x3f, 0x23, 0x13, 0x5e, 0x52, 0x7d, 0x1f, 0xc3, 0xb1, 0x0b, 0x6b, 0x52,
+	0x32, 0xc4, 0x41, 0x83, 0xc1, 0x08, 0x4f, 0x28, 0xc2, 0xf9, 0xf2, 0x00, 0xc7, 0x9a, 0xec, 0x9d,
+	0x1d, 0x99, 0x30, 0xa4, 0x27, 0xf7, 0x96, 0xfb, 0xb0, 0xe5, 0x64, 0x0e, 0xda, 0xbc, 0x51, 0xf0,
+	0xb1, 0x80, 0x2c, 0xda, 0x84, 0xcf, 0xe8, 0xa1, 0xbd, 0x78, 0x1f, 0x0e, 0xbe, 0x65, 0x8f, 0xec,
+	0xc9, 0x2a, 0x29, 0xbf, 0xec, 0xd7, 0x87, 0x3e, 0x7f, 0x14, 0x1f, 0x3a, 0x82, 0x01, 0xeb, 0xda,
+	0xd3, 0x13, 0xc8, 0x30, 0x7c, 0x9f, 0xc2, 0x77, 0xe3, 0x0a, 0x36, 0x78, 0xca, 0xda, 0x53, 0x10,
+	0x69, 0x2a, 0x0c, 0x1c, 0xaa, 0x74, 0xf0, 0xd7, 0x3a, 0x63, 0xbb, 0xe7, 0x55, 0xdc, 0x3e, 0xba,
+	0x51, 0x64, 0x3f, 0xac, 0x33, 0x50, 0xef, 0xae, 0x49, 0xc6, 0x0d, 0xde, 0xca, 0xad, 0xb9, 0x95,
+	0x64, 0xbd, 0x22, 0xc9, 0x17, 0xcc, 0x2f, 0x03, 0xe9, 0xb0, 0xd1, 0xaf, 0x0f, 0x3b, 0xaf, 0xba,
+	0x2f, 0xe3, 0xd9, 0xcb, 0x12, 0xe4, 0xbe, 0x29, 0xdd, 0x55, 0xb1, 0x7b, 0xfb, 0x62, 0x7f, 0xc1,
+	0xce, 0x27, 0x0b, 0xa1, 0xe2, 0x3f, 0x28, 0x91, 0x15, 0x89, 0x50, 0xd2, 0x6c, 0xc2, 0xa6, 0x25,
+	0x40, 0x1f, 0xe0, 0xc1, 0xaf, 0xd9, 0xd9, 0x04, 0xb4, 0x96, 0x79, 0x36, 0x95, 0x29, 0xe4, 0x85,
+	0x09, 0x5b, 0xfd, 0xda, 0xd0, 0xe3, 0x67, 0x7a, 0x0f, 0x0d, 0x7e, 0xcf, 0x9e, 0xb8, 0x73, 0xb7,
+	0x32, 0xce, 0xe4, 0x7c, 0x61, 0xd0, 0xf3, 0x39, 0xcf, 0x20, 0x6c, 0xd3, 0xa3, 0x9f, 0xe8, 0x87,
+	0xdd, 0x58, 0xe0, 0x0f, 0x52, 0x63, 0x2b, 0x14, 0x99, 0x01, 0x15, 0xfa, 0xa4, 0xcc, 0xee, 0xaa,
+	0x82, 0x21, 0xb9, 0xb7, 0xe2, 0xd3, 0x58, 0xe5, 0x4b, 0x50, 0x66, 0xf3, 0x3d, 0x6c, 0x34, 0xb5,
+	0xa4, 0xc7, 0x7b, 0xe9, 0x3e, 0x1c, 0xfc, 0x8e, 0x5d, 0x56, 0x4e, 0x7e, 0x10, 0x49, 0x01, 0x37,
+	0x90, 0xcd, 0xcd, 0x82, 0x3a, 0xd4, 0xe3, 0x97, 0xe9, 0x83, 0xde, 0x81, 0x60, 0xfe, 0xa8, 0x30,
+	0x8b, 0x69, 0x7e, 0x0f, 0x5f, 0x42, 0xd2, 0x39, 0xab, 0x4f, 0xa7, 0x37, 0xc4, 0x91, 0xc7, 0xeb,
+	0x66, 0x7a, 0xf3, 0xf3, 0x33, 0x66, 0xf0, 0x6f, 0x8f, 0xb5, 0x5c, 0x8d, 0x70, 0x4a, 0x5d, 0x17,
+	0x8a, 0x3a, 0x82, 0xe2, 0x78, 0xbc, 0x1d, 0x3b, 0x1b, 0x7d, 0x6f, 0x73, 0x6d, 0x90, 0x70, 0x37,
+	0xda, 0xda, 0x0b, 0x67, 0xd3, 0xff, 0x60, 0x25, 0x23, 0xf8, 0x61, 0x52, 0x4e, 0xb7, 0xd8, 0xd9,
+	0x41, 0x9f, 0x75, 0xbe, 0x53, 0xf9, 0x5a, 0x83, 0x22, 0xdd, 0x34, 0xc8, 0xdd, 0x99, 0xed, 0x20,
+	0x24, 0xd3, 0x9d, 0xf8, 0x00, 0x0a, 0xef, 0xe1, 0xe6, 0xdd, 0xd9, 0x6c, 0x0f, 0xc5, 0x72, 0xbb,
+	0x73, 0x37, 0x22, 0x9b, 0x17, 0x62, 0x0e, 0x4e, 0x1f, 0xbd, 0xd9, 0x3e, 0x4c, 0x52, 0x8a, 0x14,
+	0x40, 0xc6, 0x41, 0xe7, 0x49, 0x41, 0xf9, 0xb4, 0x9c, 0x94, 0x0e, 0x70, 0x3c, 0xfb, 0x93, 0xcc,
+	0xe2, 0x7c, 0x5d, 0x39, 0x6b, 0xb5, 0x71, 0xbe, 0x3e, 0xc0, 0x83, 0x67, 0x8c, 0xd9, 0x3c, 0xa7,
+	0x9b, 0x25, 0x90, 0x24, 0x7c, 0xce, 0xe2, 0x2d, 0x82, 0xb9, 0x92, 0x36, 0xd4, 0xe6, 0x2a, 0x8f,
+	0x81, 0xc4, 0xe0, 0xf3, 0x4e, 0xb4, 0x83, 0xb0, 0x7d, 0xae, 0x50, 0xd8, 0x1d, 0xdb, 0x3e, 0x11,
+	0x8a, 0xf9, 0x1b, 0xe6, 0xe3, 0x54, 0x1c, 0xcd, 0x21, 0x33, 0x34, 0x86, 0x7d, 0xee, 0x17, 0x25,
+	0x10, 0x5c, 0xb2, 0x26, 0x7a, 0xdf, 0x8d, 0x69, 0xec, 0xfa, 0xbc, 0x59, 0x90, 0x85, 0x02, 0x45,
+	0x7c, 0xcb, 0xc9, 0x19, 0x79, 0xbb, 0x45, 0x05, 0x43, 0x5e, 0x38, 0xdc, 0x81, 0x52, 0xa0, 0xc2,
+	0x9e, 0xe5, 0x45, 0x39, 0x1b, 0x7d, 0xa3, 0xc9, 0xfb, 0x22, 0x9d, 0x81, 0x0a, 0xcf, 0x2d, 0xd7,
+	0xc2, 0xd9, 0x18, 0x73, 0x34, 0x21, 0xba, 0x1e, 0xd9, 0x98, 0x82, 0x2c, 0x8c, 0x79, 0x23, 0xb4,
+	0x19, 0x45, 0x46, 0xae, 0x30, 0x8b, 0xc0, 0x4e, 0x9d, 0xa4, 0x82, 0x61, 0x36, 0xd4, 0x38, 0x39,
+	0xea, 0xf2, 0x31, 0xe9, 0xd2, 0x5f, 0x95, 0x00, 0x56, 0x70, 0xd7, 0x56, 0x34, 0xee, 0x3d, 0xce,
+	0x76, 0x4d, 0x15, 0xbc, 0x64, 0xc1, 0xb5, 0xd8, 0xe8, 0x89, 0xcc, 0x22, 0xc0, 0x50, 0x74, 0x98,
+	0x26, 0xbd, 0xc7, 0x83, 0xf8, 0xc8, 0x13, 0x7c, 0xcb, 0x98, 0xeb, 0x1b, 0x09, 0x3a, 0xbc, 0xdc,
+	0xcd, 0x9e, 0xb2, 0x9b, 0x38, 0x5b, 0x6e, 0xfd, 0x83, 0x0c, 0xdf, 0xc2, 0x73, 0x58, 0x49, 0x58,
+	0x23, 0x13, 0x63, 0x61, 0x16, 0xae, 0x9f, 0x1a, 0x4b, 0x61, 0x16, 0xc8, 0xdf, 0x1f, 0x0b, 0x50,
+	0x9b, 0x89, 0x51, 0x32, 0x9b, 0x3b, 0x99, 0x77, 0x3e, 0xee, 0xa0, 0x83, 0x78, 0xf5, 0xff, 0x11,
+	0xef, 0x15, 0x6b, 0x97, 0x38, 0xf6, 0x24, 0xbe, 0x52, 0x6c, 0xb8, 0xfa, 0x3d, 0x6c, 0x70, 0x53,
+	0xa0, 0x5e, 0x2f, 0x37, 0x85, 0x15, 0x1a, 0x83, 0x3f, 0xd7, 0x99, 0x37, 0x4a, 0x40, 0x99, 0xa3,
+	0xcd, 0x62, 0xc0, 0xba, 0xbb, 0x91, 0xed, 0x9a, 0xde, 0xe7, 0xdd, 0xa8, 0x82, 0x3d, 0x38, 0x9e,
+	0x2f, 0x59, 0xf3, 0x16, 0x8c, 0x92, 0x91, 0x6b, 0xbe, 0x66, 0x4a, 0xd6, 0xb6, 0x02, 0x5e, 0xa5,
+	0x02, 0xcf, 0xd9, 0xa9, 0xed, 0x86, 0x5b, 0x99, 0x15, 0x38, 0xce, 0x9b, 0x54, 0xfa, 0xd3, 0x75,
+	0x15, 0x44, 0x8e, 0xaf, 0xf2, 0x2c, 0x96, 0x95, 0xc6, 0xf2, 0xa3, 0x12, 0x40, 0xef, 0x74, 0xa1,
+	0x40, 0x2f, 0xf2, 0x24, 0xa6, 0x56, 0xaa, 0x71, 0xdf, 0x94, 0x80, 0xdd, 0x27, 0xf2, 0x24, 0xce,
+	0xd7, 0x59, 0x19, 0xc3, 0xb7, 0x43, 0x33, 0xda, 0x87, 0x77, 0x9b, 0x14, 0xab, 0x6e, 0x52, 0xcf,
+	0x18, 0xfb, 0x09, 0x66, 0x8b, 0x3c, 0xbf, 0xff, 0x91, 0xdf, 0xb8, 0x3e, 0x62, 0xeb, 0x2d, 0x42,
+	0xb3, 0xc8, 0xae, 0x1a, 0xb1, 0xdb, 0x69, 0xda, 0x6e, 0xf5, 0x88, 0xf1, 0x66, 0x28, 0x9d, 0x37,
+	0x52, 0x41, 0x4c, 0xed, 0x54, 0xe7, 0x7e, 0x52, 0x02, 0xd5, 0x19, 0x79, 0xb6, 0x3f, 0x23, 0xff,
+	0x51, 0x63, 0x8c, 0x38, 0x79, 0xbd, 0x82, 0xec, 0x98, 0x98, 0x90, 0xb5, 0xc8, 0xbb, 0x1b, 0xc4,
+	0xc2, 0x9a, 0x47, 0x94, 0xd5, 0x1f, 0xa0, 0xec, 0x82, 0x79, 0xf6, 0x42, 0x76, 0x30, 0x7b, 0x77,
+	0x74, 0x99, 0xad, 0x38, 0x3c, 0x2a, 0xa0, 0x15, 0x07, 0x26, 0x40, 0x0d, 0x0d, 0x59, 0x64, 0x87,
+	0x5f, 0x8d, 0xfb, 0xaa, 0x04, 0xf0, 0x1e, 0xb7, 0xa0, 0x35, 0x0e, 0x46, 0x4b, 0x4a, 0x2b, 0xb5,
+	0x26, 0x95, 0x52, 0xa9, 0x5c, 0xb9, 0xc9, 0xe6, 0x01, 0x1a, 0x83, 0x7f, 0xd5, 0x58, 0xcb, 0xd5,
+	0xf2, 0xab, 0xc4, 0x76, 0xce, 0xea, 0xc8, 0x81, 0x4d, 0xaa, 0x5e, 0xf0, 0x1b, 0x94, 0xda, 0x04,
+	0x22, 0x05, 0xa6, 0x94, 0x9a, 0x26, 0x0b, 0x71, 0x2a, 0x9d, 0x0e, 0x3d, 0xda, 0x68, 0x9a, 0x40,
+	0xd6, 0x1e, 0x59, 0xcd, 0x03, 0xb2, 0x9e, 0xb3, 0xd3, 0x49, 0x91, 0xa6, 0x42, 0x6d, 0xdc, 0x8e,
+	0xd3, 0xa2, 0xfa, 0x9c, 0xea, 0x2a, 0x58, 0x25, 0xad, 0xbd, 0x4f, 0xda, 0x9f, 0x4e, 0x58, 0xcf,
+	0x65, 0x77, 0x0d, 0x89, 0x5c, 0x81, 0xda, 0x1c, 0x65, 0xf9, 0x0d, 0xf3, 0xdd, 0x91, 0x2d, 0x77,
+	0xfe, 0xba, 0x04, 0xfe, 0x5f, 0xf6, 0x28, 0x33, 0x97, 0xb0, 0x47, 0x89, 0xe1, 0xad, 0xc6, 0x62,
+	0x93, 0xe4, 0xc2, 0x6e, 0x39, 0x5d, 0xde, 0x5a, 0x5a, 0x93, 0xc6, 0xae, 0x31, 0x90, 0x2e, 0x4d,
+	0xd9, 0x5b, 0x6d, 0xe1, 0x6c, 0x1c, 0x3f, 0xef, 0xe1, 0x93, 0x71, 0x7e, 0x97, 0x6f, 0x27, 0xdb,
+	0x41, 0xa5, 0x80, 0xab, 0x5c, 0xfa, 0x49, 0x09, 0x54, 0x6b, 0xe1, 0xef, 0xd7, 0xe2, 0x6f, 0x35,
+	0xe6, 0x7f, 0x27, 0xa2, 0xfb, 0x62, 0xc9, 0x8b, 0xec, 0xa8, 0x0a, 0x4f, 0x59, 0xdb, 0x3a, 0xb7,
+	0x3c, 0xb7, 0x67, 0xce, 0xc6, 0x67, 0x4e, 0x8c, 0x50, 0xf8, 0xcc, 0xba, 0x7d, 0xa6, 0xb6, 0x26,
+	0xfe, 0xeb, 0x8d, 0xcc, 0xa4, 0x5e, 0x6c, 0xa5, 0xdb, 0xbe, 0x73, 0xf6, 0x83, 0xa3, 0x25, 0x60,
+	0x8d, 0x89, 0xfc, 0x6c, 0x65, 0x5b, 0xe7, 0x0d, 0x2d, 0x3f, 0x57, 0x74, 0xd9, 0xaa, 0xea, 0xf2,
+	0xef, 0x35, 0xd6, 0x7d, 0xfd, 0x69, 0x99, 0x2b, 0xf3, 0x16, 0x44, 0x0c, 0x94, 0x58, 0xb9, 0x1a,
+	0xd4, 0xe8, 0xdb, 0xa3, 0xb5, 0xb2, 0xe6, 0x57, 0xcf, 0xc4, 0x9f, 0xff, 0xe6, 0x7a, 0xca, 0xda,
+	0x36, 0xf6, 0x76, 0x43, 0x6d, 0x83, 0xb3, 0xbf, 0x64, 0x45, 0x1d, 0x7c, 0x2c, 0x73, 0xe0, 0x10,
+	0xe1, 0x57, 0x5e, 0x65, 0xfe, 0x77, 0xed, 0xfc, 0xff, 0xd5, 0x76, 0xf1, 0xa2, 0x6b, 0x77, 0x5e,
+	0x75, 0xf0, 0x45, 0xe2, 0x20, 0xde, 0x72, 0x9b, 0x69, 0x30, 0xdc, 0xbd, 0xb4, 0x28, 0x85, 0xf2,
+	0x85, 0xe3, 0x30, 0xfc, 0x90, 0xb4, 0xbf, 0x06, 0xff, 0x3c, 0x61, 0xad, 0xd7, 0x4a, 0xe8, 0x42,
+	0xc1, 0x57, 0xf5, 0xf3, 0x05, 0xf3, 0x46, 0x91, 0xc9, 0x95, 0xab, 0x94, 0x27, 0xd0, 0xf8, 0x2f,
+	0xa5, 0x7a, 0xc6, 0x98, 0xbb, 0x2d, 0x66, 0x66, 0xb9, 0x66, 0x7a, 0x8b, 0xd0, 0x1d, 0xc6, 0xae,
+	0x40, 0x27, 0x72, 0x4c, 0x9f, 0xae, 0x63, 0x4e, 0x1f, 0xa6, 0x6e, 0x3e, 0x49, 0x6b, 0x22, 0x45,
+	0x6f, 0x54, 0x9e, 0xba, 0x16, 0x6e, 0xdc, 0xa9, 0x3c, 0xc5, 0x7f, 0x4f, 0x73, 0x27, 0xe4, 0x13,
+	0x93, 0x23, 0x31, 0x2e, 0x5a, 0xb9, 0x66, 0xb7, 0x5d, 0x2c, 0x7a, 0x21, 0x95, 0xf5, 0xd0, 0x6e,
+	0xa5, 0xf6, 0xcb, 0xb2, 0xd8, 0xd7, 0x95, 0x92, 0x06, 0x14, 0x96, 0xda, 0x2d, 0x58, 0x51, 0x09,
+	0x50, 0x65, 0xac, 0x21, 0xde, 0x0a, 0xbd, 0x70, 0x6b, 0x56, 0x37, 0xaa, 0x60, 0xb3, 0x26, 0x7d,
+	0xf8, 0xff, 0xf6, 0x3f, 0x03, 0x00, 0x7e, 0x8a, 0xb1, 0x19, 0x08, 0x10, 0x00, 0x00,
 }
@@ -0,0 +1,63 @@
+package db
+
+import "strings"
+
+// The default limits of the custom properties
+const (
+	DefaultMaxPropertyKeys        = 10
+	DefaultMaxPropertyValueLength = 100
+)
+
+// The filter prefixes of the custom properties, eg session_property.plan
+const (
+	SessionPropertyFilter  = "session_property."
+	PageviewPropertyFilter = "pageview_property."
+)
+
+// GetPropertyLimits returns the collection's maximum custom property count
+// and the maximum length of their keys and values
+func GetPropertyLimits(collection *Collection) (int, int) {
+	keys, length := int(collection.MaxPropertyKeys), int(collection.MaxPropertyValueLength)
+	if keys <= 0 {
+		keys = DefaultMaxPropertyKeys
+	}
+	if length <= 0 {
+		length = DefaultMaxPropertyValueLength
+	}
+	return keys, length
+}
+
+// propertyFilter is the required values of the custom properties
+type propertyFilter map[string]string
+
+func createPropertyFilter(filter map[string]string, prefix string) *propertyFilter {
+	pf := propertyFilter{}
+	for k, v := range filter {
+		if strings.HasPrefix(k, prefix) {
+			pf[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	if len(pf) == 0 {
+		return nil
+	}
+	return &pf
+}
+
+func (pf *propertyFilter) match(properties []*Property) bool {
+	if pf == nil {
+		return true
+	}
+	for key, value := range *pf {
+		found := false
+		for _, p := range properties {
+			if p.Key == key {
+				found = p.Value == value
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
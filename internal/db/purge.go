@@ -0,0 +1,69 @@
+package db
+
+import (
+	"time"
+)
+
+// PurgeReportT is the purge's report
+type PurgeReportT struct {
+	CollectionID string    `json:"collection_id"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Sessions     int       `json:"sessions"`
+	Pageviews    int       `json:"pageviews"`
+	// CompactionQueued is set when the affected shards are compacted in the background
+	CompactionQueued bool             `json:"compaction_queued"`
+	Shards           []CompactReportT `json:"shards"`
+}
+
+// CompactReportT is a compacted shard's sizes
+type CompactReportT struct {
+	ID     string `json:"id"`
+	Before int64  `json:"before"`
+	After  int64  `json:"after"`
+}
+
+// PurgeCollection deletes the sessions started between from and to with their pageviews,
+// the affected shard files are compacted separately by CompactCollection
+func PurgeCollection(collectionID string, from time.Time, to time.Time) (*PurgeReportT, error) {
+	sdb, err := getShardDB(collectionID)
+	if err != nil {
+		return nil, err
+	}
+	report := &PurgeReportT{
+		CollectionID: collectionID,
+		From:         from,
+		To:           to,
+		Shards:       []CompactReportT{},
+	}
+	fromKey, toKey := marshalTime(from), marshalTime(to)
+	if report.Sessions, err = sdb.DeleteRange(BSession, fromKey, toKey); err != nil {
+		return report, err
+	}
+	if report.Pageviews, err = sdb.DeleteRange(BPageview, fromKey, toKey); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// CompactCollection compacts the collection's shard files between from and to,
+// the memory store has nothing to compact
+func CompactCollection(collectionID string, from time.Time, to time.Time) ([]CompactReportT, error) {
+	sdb, err := getShardDB(collectionID)
+	if err != nil {
+		return nil, err
+	}
+	shards := []CompactReportT{}
+	bdb, err := boltShards(sdb)
+	if err == ErrStoreNotSupported {
+		return shards, nil
+	}
+	for _, id := range bdb.ShardIDs(marshalTime(from), marshalTime(to)) {
+		before, after, err := bdb.Compact(id)
+		if err != nil {
+			return shards, err
+		}
+		shards = append(shards, CompactReportT{id, before, after})
+	}
+	return shards, nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	bolt "github.com/etcd-io/bbolt"
+)
+
+// ReshardReportT is the reshard's report
+type ReshardReportT struct {
+	CollectionID string `json:"collection_id"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	OldShards    int    `json:"old_shards"`
+	NewShards    int    `json:"new_shards"`
+	Records      int    `json:"records"`
+}
+
+// ReshardCollection rewrites the collection's shards in the new granularity and stores it with the collection.
+// The data is copied into a new shard dir which replaces the old one, so it should run while the server is stopped.
+func ReshardCollection(collection *Collection, granularity string) (*ReshardReportT, error) {
+	if !ValidShardGranularity(granularity) {
+		return nil, fmt.Errorf("invalid shard granularity: %v", granularity)
+	}
+	report := &ReshardReportT{
+		CollectionID: collection.ID,
+		From:         GetShardGranularity(collection),
+		To:           getShardGranularity(granularity),
+	}
+	sdb, err := getBoltShardDB(collection.ID)
+	if err != nil {
+		return nil, err
+	}
+	dir := basedir + collection.ID
+	tmpDir := dir + ".reshard"
+	oldDir := dir + ".before-reshard"
+	if _, err := os.Stat(oldDir); err == nil {
+		return nil, fmt.Errorf("%v is left behind by a failed reshard, check it and remove it before resharding", oldDir)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return nil, err
+	}
+	ndb, err := openShardDB(tmpDir, granularity)
+	if err != nil {
+		return nil, err
+	}
+	err = sdb.ViewShards(func(id string, tx *bolt.Tx) error {
+		report.OldShards++
+		ntx := ndb.Begin(true)
+		err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if bytes.Equal(name, BMeta) {
+				return nil
+			}
+			return b.ForEach(func(k, v []byte) error {
+				report.Records++
+				return ntx.Put(name, k, v)
+			})
+		})
+		if err != nil {
+			ntx.Rollback()
+			return err
+		}
+		return ntx.Commit()
+	})
+	report.NewShards = len(ndb.GetSizes())
+	if errs := ndb.Close(); errs != nil && err == nil {
+		err = fmt.Errorf("can't close the new shards %v", errs)
+	}
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+
+	if err := closeShardDB(collection.ID); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(dir, oldDir); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return nil, fmt.Errorf("can't move the new shards into place, the old ones are in %v: %v", oldDir, err)
+	}
+	collection.ShardGranularity = granularity
+	if err := UpdateCollection(collection); err != nil {
+		return nil, err
+	}
+	return report, os.RemoveAll(oldDir)
+}
@@ -96,6 +96,7 @@ func Seed(from time.Time, to time.Time, collectionID string, n int) error {
 
 		session := &Session{
 			Duration:         int32(randomSessionDuration),
+			LastActivity:     tfrom.Add(time.Duration(randomSessionDuration) * time.Second).UnixNano(),
 			Hostname:         host,
 			DeviceOS:         ua.OS(),
 			UserIP:           ip,
@@ -112,6 +113,8 @@ func Seed(from time.Time, to time.Time, collectionID string, n int) error {
 			ASName:           asn.Name,
 			UserAgent:        userAgent,
 			Referrer:         randElem(referrers),
+			// every visitor has about three sessions
+			VisitorID: uint64(rand.Intn(n/3+1)+1) * 0x9E3779B97F4A7C15,
 		}
 		sessionKey := GetKey(tfrom, sessionID)
 		if err := ShardUpsertTx(tx, sessionKey, session); err != nil {
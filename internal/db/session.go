@@ -0,0 +1,205 @@
+package db
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/soyersoyer/rightana/internal/db/shardbolt"
+)
+
+// DefaultSessionTimeout is the inactivity after which a session ends
+const DefaultSessionTimeout = 30 * time.Minute
+
+// GetSessionTimeout returns the collection's session inactivity timeout
+func GetSessionTimeout(collection *Collection) time.Duration {
+	if collection.SessionTimeout <= 0 {
+		return DefaultSessionTimeout
+	}
+	return time.Duration(collection.SessionTimeout) * time.Minute
+}
+
+// locations caches the loaded timezones, time.LoadLocation reads the zoneinfo from the disk
+var locations sync.Map
+
+// GetLocation returns the named timezone, the loaded ones are cached
+func GetLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// getLastActivity returns the session's last activity, the old sessions' is their last pageview
+func getLastActivity(key []byte, session *Session) time.Time {
+	if session.LastActivity != 0 {
+		return time.Unix(0, session.LastActivity)
+	}
+	return GetTimeFromKey(key).Add(time.Duration(session.Duration) * time.Second)
+}
+
+// sessionExpired checks whether an activity at t belongs to a new session
+func sessionExpired(collection *Collection, key []byte, session *Session, t time.Time) bool {
+	if t.Sub(getLastActivity(key, session)) > GetSessionTimeout(collection) {
+		return true
+	}
+	if collection.SessionMidnightTimezone == "" {
+		return false
+	}
+	loc, err := GetLocation(collection.SessionMidnightTimezone)
+	if err != nil {
+		return false
+	}
+	begin := GetTimeFromKey(key)
+	by, bm, bd := begin.In(loc).Date()
+	ty, tm, td := t.In(loc).Date()
+	return by != ty || bm != tm || bd != td
+}
+
+// TouchSession records an activity of the session, its duration lasts until the latest activity.
+// An expired session isn't extended, the next pageview starts a new one.
+func TouchSession(collection *Collection, key []byte, t time.Time) error {
+	if len(key) != sessionKeyLength {
+		return ErrKeyNotExists
+	}
+	sdb, err := getShardDB(collection.ID)
+	if err != nil {
+		return err
+	}
+	notExists := false
+	err = sdb.BatchUpdate(key, func(tx ShardTx) error {
+		// a missing session isn't an error in the batch, it would roll back the others' writes
+		notExists = false
+		session, err := getSessionTx(tx, key)
+		if err == ErrKeyNotExists {
+			notExists = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if sessionExpired(collection, key, session, t) {
+			return nil
+		}
+		return touchSessionTx(tx, key, session, t)
+	})
+	if err == nil && notExists {
+		return ErrKeyNotExists
+	}
+	return err
+}
+
+// AddPageview stores the pageview of the session and records it as the session's activity.
+// When the session has expired, the pageview starts a continuation session with the visitorID
+// (0 means unknown), it returns the key of the session the pageview was stored in.
+// The visitorID is computed by the caller, the batched update may be retried, it mustn't have side effects.
+func AddPageview(collection *Collection, sessionKey []byte, t time.Time, pageview *Pageview, visitorID uint64) ([]byte, error) {
+	if len(sessionKey) != sessionKeyLength {
+		return nil, ErrKeyNotExists
+	}
+	sdb, err := getShardDB(collection.ID)
+	if err != nil {
+		return nil, err
+	}
+	key := sessionKey
+	notExists := false
+	update := func(tx ShardTx) error {
+		// a missing session isn't an error in the batch, it would roll back the others' writes
+		key, notExists = sessionKey, false
+		session, err := getSessionTx(tx, key)
+		if err == ErrKeyNotExists {
+			notExists = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if sessionExpired(collection, key, session, t) {
+			next := continueSession(key, session, t)
+			next.VisitorID = visitorID
+			key, session = GetKey(t, rand.Uint32()), next
+		}
+		if err := touchSessionTx(tx, key, session, t); err != nil {
+			return err
+		}
+		return ShardUpsertTx(tx, GetPVKey(append([]byte{}, key...), t), pageview)
+	}
+	err = sdb.BatchUpdate(sessionKey, update)
+	if err == shardbolt.ErrOtherShard {
+		// the continuation session is in a new shard
+		err = sdb.Update(update)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if notExists {
+		return nil, ErrKeyNotExists
+	}
+	return key, nil
+}
+
+// continueSession returns a new session of the same visitor at t, it's the visitor's next visit
+// if the visits are counted. The visitor ID is unknown, it depends on the day's salt.
+func continueSession(key []byte, session *Session, t time.Time) *Session {
+	next := *session
+	next.Duration = 0
+	next.LastActivity = 0
+	next.Referrer = ""
+	next.VisitorID = 0
+	if session.VisitCount > 0 {
+		next.VisitCount = session.VisitCount + 1
+		next.DaysSinceLastVisit = int32(t.Sub(getLastActivity(key, session)) / (24 * time.Hour))
+	}
+	return &next
+}
+
+func getSessionTx(tx ShardTx, key []byte) (*Session, error) {
+	if len(key) != sessionKeyLength {
+		return nil, ErrKeyNotExists
+	}
+	v, err := tx.Get(BSession, key)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, ErrKeyNotExists
+	}
+	session := &Session{}
+	if err := proto.Unmarshal(v, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func touchSessionTx(tx ShardTx, key []byte, session *Session, t time.Time) error {
+	if t.UnixNano() <= session.LastActivity {
+		return nil
+	}
+	session.LastActivity = t.UnixNano()
+	session.Duration = int32(t.Sub(GetTimeFromKey(key)).Seconds())
+	return ShardUpsertTx(tx, key, session)
+}
+
+// The visitor types by the tracker's visit counter
+const (
+	VisitorNew       = "new"
+	VisitorReturning = "returning"
+)
+
+// GetVisitorType returns whether the session's visitor is new or returning, empty means unknown
+func GetVisitorType(session *Session) string {
+	switch {
+	case session.VisitCount == 1:
+		return VisitorNew
+	case session.VisitCount > 1:
+		return VisitorReturning
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package shardbolt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	bolt "github.com/etcd-io/bbolt"
+)
+
+// compactTxSize is the count of the keys written in a transaction of the compaction
+const compactTxSize = 65536
+
+// ShardIDs returns the IDs of the shards which may contain keys between fromKey and toKey
+func (db *DB) ShardIDs(fromKey []byte, toKey []byte) []string {
+	ids := []string{}
+	for _, s := range db.getShards(fromKey, toKey) {
+		ids = append(ids, s.id)
+	}
+	return ids
+}
+
+// DeleteRange deletes the keys between fromKey and toKey and returns their count,
+// every shard is deleted from in its own transaction
+func (db *DB) DeleteRange(bucket []byte, fromKey []byte, toKey []byte) (int, error) {
+	if err := db.writable(); err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, s := range db.getShards(fromKey, toKey) {
+		err := s.update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(bucket)
+			if b == nil {
+				return nil
+			}
+			c := b.Cursor()
+			for k, _ := c.Seek(fromKey); k != nil && bytes.Compare(k, toKey) < 0; k, _ = c.Seek(fromKey) {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				deleted++
+			}
+			return nil
+		})
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// Compact rewrites the shard's file without its free pages and returns its sizes before and after,
+// it starts when the shard is idle and the shard's new users wait until it's done
+func (db *DB) Compact(id string) (int64, int64, error) {
+	var s *shard
+	for _, v := range db.getShardArray() {
+		if v.id == id {
+			s = v
+		}
+	}
+	if s == nil {
+		return 0, 0, fmt.Errorf("shard not found '%v'", id)
+	}
+	if err := s.pool.lock(s); err != nil {
+		return 0, 0, err
+	}
+	defer s.pool.unlock(s)
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return 0, 0, err
+	}
+	before := info.Size()
+	tmp := strings.TrimSuffix(s.path, ".bolt") + ".compact"
+	os.Remove(tmp)
+	if err := compactFile(s.path, tmp, s.mode, db.options.FillPercent); err != nil {
+		os.Remove(tmp)
+		return before, before, err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return before, before, err
+	}
+	if info, err = os.Stat(s.path); err != nil {
+		return before, 0, err
+	}
+	return before, info.Size(), nil
+}
+
+// compactFile copies the buckets of src into the new dst file
+func compactFile(src string, dst string, mode os.FileMode, fillPercent float64) error {
+	sdb, err := bolt.Open(src, mode, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer sdb.Close()
+	ddb, err := bolt.Open(dst, mode, nil)
+	if err != nil {
+		return err
+	}
+	defer ddb.Close()
+
+	return sdb.View(func(stx *bolt.Tx) error {
+		dtx, err := ddb.Begin(true)
+		if err != nil {
+			return err
+		}
+		puts := 0
+		err = stx.ForEach(func(name []byte, sb *bolt.Bucket) error {
+			b, err := dtx.CreateBucket(name)
+			if err != nil {
+				return err
+			}
+			b.FillPercent = fillPercent
+			return sb.ForEach(func(k, v []byte) error {
+				if v == nil {
+					return fmt.Errorf("nested bucket '%s/%s' isn't supported", name, k)
+				}
+				if puts == compactTxSize {
+					if err := dtx.Commit(); err != nil {
+						return err
+					}
+					if dtx, err = ddb.Begin(true); err != nil {
+						return err
+					}
+					b = dtx.Bucket(name)
+					b.FillPercent = fillPercent
+					puts = 0
+				}
+				puts++
+				return b.Put(k, v)
+			})
+		})
+		if err != nil {
+			if dtx != nil {
+				dtx.Rollback()
+			}
+			return err
+		}
+		return dtx.Commit()
+	})
+}
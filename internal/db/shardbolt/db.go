@@ -22,10 +22,22 @@ type DB struct {
 	options    *Options
 	shards     atomic.Value
 	shardMutex sync.Mutex
+	// commitMutex serializes the journaled multi-shard commits,
+	// the other writes don't take it, see MultiTx.Commit
+	commitMutex sync.Mutex
+	// writeErr is set when a multi-shard commit's journal is left behind, the writes are refused
+	writeErr atomic.Value
 }
 
 type Options struct {
 	FillPercent float64
+	// OnCreate is called in the first transaction of the newly created shards
+	OnCreate func(tx *bolt.Tx) error
+	// Pool limits the open shard files, it can be shared between DBs, nil means unlimited
+	Pool *Pool
+	// ShardRange returns the [from, to) key range of a shard ID, it makes possible to use
+	// shards with different ID formats in a DB, nil means that the IDs are compared
+	ShardRange  func(id string) (from []byte, to []byte, err error)
 	boltOptions *bolt.Options
 }
 
@@ -35,6 +47,11 @@ func Open(dir string, mapFn func([]byte) string, mode os.FileMode, options *Opti
 			FillPercent: 0.9,
 		}
 	}
+	if options.Pool == nil {
+		opts := *options
+		opts.Pool = NewPool(0)
+		options = &opts
+	}
 
 	db := &DB{
 		dir:     dir,
@@ -51,7 +68,7 @@ func Open(dir string, mapFn func([]byte) string, mode os.FileMode, options *Opti
 		if err != nil {
 			return err
 		}
-		if info.IsDir() {
+		if info.IsDir() || info.Name() == journalName {
 			return nil
 		}
 		shardID, err := getShardIDFromFilename(info.Name())
@@ -59,20 +76,19 @@ func Open(dir string, mapFn func([]byte) string, mode os.FileMode, options *Opti
 			log.Println(err)
 			return nil
 		}
-		shard, err := db.openShard(shardID)
-		if err != nil {
-			log.Println("can't open shard:", path, "cause:", err)
-			return nil
-		}
-		shards = append(shards, shard)
+		shards = append(shards, db.newShard(shardID))
 		return nil
 	})
 	if err != nil {
 		log.Println("cant open dir:", dir, "cause:", err)
 		return nil, err
 	}
-	sortShards(shards)
+	db.sortShards(shards)
 	db.setShardArray(shards)
+	if err := db.replayJournal(); err != nil {
+		log.Println("can't replay the journal in", dir, "cause:", err)
+		return nil, err
+	}
 	return db, nil
 }
 
@@ -80,7 +96,7 @@ func (db *DB) Close() []error {
 	shards := db.getShardArray()
 	var errs []error
 	for _, v := range shards {
-		err := v.db.Close()
+		err := v.closeDB()
 		if err != nil {
 			errs = append(errs, err)
 			log.Println(err)
@@ -113,7 +129,7 @@ func (db *DB) DeleteShard(id string) error {
 func (db *DB) Iterate(bucket []byte, fromKey []byte, toKey []byte, fn func(k []byte, v []byte)) {
 	shards := db.getShards(fromKey, toKey)
 	for _, v := range shards {
-		v.db.View(func(tx *bolt.Tx) error {
+		err := v.view(func(tx *bolt.Tx) error {
 			b := tx.Bucket(bucket)
 			if b == nil {
 				log.Println("bucket not found", string(bucket))
@@ -125,13 +141,16 @@ func (db *DB) Iterate(bucket []byte, fromKey []byte, toKey []byte, fn func(k []b
 			}
 			return nil
 		})
+		if err != nil {
+			log.Println("can't iterate shard", v.path, "cause:", err)
+		}
 	}
 }
 
 func (db *DB) IteratePrefix(bucket []byte, prefixKey []byte, fn func(k []byte, v []byte)) {
 	shards := db.getShards(prefixKey, prefixKey)
 	for _, v := range shards {
-		v.db.View(func(tx *bolt.Tx) error {
+		err := v.view(func(tx *bolt.Tx) error {
 			b := tx.Bucket(bucket)
 			if b == nil {
 				log.Println("bucket not found", string(bucket))
@@ -143,6 +162,9 @@ func (db *DB) IteratePrefix(bucket []byte, prefixKey []byte, fn func(k []byte, v
 			}
 			return nil
 		})
+		if err != nil {
+			log.Println("can't iterate shard", v.path, "cause:", err)
+		}
 	}
 }
 
@@ -153,8 +175,11 @@ func (db *DB) Get(bucket []byte, key []byte) ([]byte, error) {
 	}
 
 	ret := []byte{}
-	err := actualShard.db.View(func(tx *bolt.Tx) error {
+	err := actualShard.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucket)
+		if b == nil {
+			return errors.New(fmt.Sprint("bucket not found in shard ", string(bucket)))
+		}
 		ret = b.Get(key)
 		if ret == nil {
 			return errors.New(fmt.Sprint("key not found in shard", actualShard, key))
@@ -185,11 +210,14 @@ func (db *DB) Update(fn func(tx *MultiTx) error) error {
 }
 
 func (db *DB) BatchUpsert(bucket []byte, key []byte, value []byte) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
 	actualShard, err := db.ensureShard(key)
 	if err != nil {
 		return err
 	}
-	return actualShard.db.Batch(func(tx *bolt.Tx) error {
+	return actualShard.batch(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists(bucket)
 		if err != nil {
 			return err
@@ -199,6 +227,31 @@ func (db *DB) BatchUpsert(bucket []byte, key []byte, value []byte) error {
 	})
 }
 
+// ErrOtherShard is returned when a Batch's transaction accesses an other shard than its key's
+var ErrOtherShard = errors.New("the key is in an other shard than the batch's")
+
+// Batch runs fn in a batched read-write transaction of the key's shard, the concurrent calls may share
+// a transaction, so fn may be called more than once and it mustn't have side effects. fn can only access
+// the keys of the key's shard, the others return ErrOtherShard. If the key's shard doesn't exist yet,
+// fn runs in an Update.
+func (db *DB) Batch(key []byte, fn func(tx *MultiTx) error) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
+	actualShard := db.getActualShard(key)
+	if actualShard == nil {
+		return db.Update(fn)
+	}
+	return actualShard.batch(func(btx *bolt.Tx) error {
+		return fn(&MultiTx{
+			db:        db,
+			writeable: true,
+			txs:       []*shardTx{{shard: actualShard, tx: btx}},
+			batch:     true,
+		})
+	})
+}
+
 type ShardSize struct {
 	ID   string
 	Size int
@@ -210,7 +263,7 @@ func (db *DB) GetSizes() []ShardSize {
 	var sizes []ShardSize
 	for _, v := range shards {
 		size := -1
-		fileinfo, err := os.Stat(v.db.Path())
+		fileinfo, err := os.Stat(v.path)
 		if err == nil {
 			size = int(fileinfo.Size())
 		}
@@ -227,6 +280,35 @@ func (db *DB) setShardArray(shards shardArray) {
 	db.shards.Store(shards)
 }
 
+// ViewShards calls fn for every shard in its own read transaction
+func (db *DB) ViewShards(fn func(id string, tx *bolt.Tx) error) error {
+	for _, shard := range db.getShardArray() {
+		err := shard.view(func(tx *bolt.Tx) error {
+			return fn(shard.id, tx)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateShards calls fn for every shard in its own read-write transaction
+func (db *DB) UpdateShards(fn func(id string, tx *bolt.Tx) error) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
+	for _, shard := range db.getShardArray() {
+		err := shard.update(func(tx *bolt.Tx) error {
+			return fn(shard.id, tx)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // RunBackup creates a backup for this db
 func (db *DB) RunBackup(dir string) []error {
 	errs := []error{}
@@ -236,7 +318,7 @@ func (db *DB) RunBackup(dir string) []error {
 	}
 	shards := db.getShardArray()
 	for _, shard := range shards {
-		err := shard.db.View(func(tx *bolt.Tx) error {
+		err := shard.view(func(tx *bolt.Tx) error {
 			return tx.CopyFile(dir+"/"+shard.id+".bolt", 0600)
 		})
 		if err != nil {
@@ -5,8 +5,11 @@ import (
 	"errors"
 	"log"
 	"os"
+	"sync"
 	"testing"
 	"time"
+
+	bolt "github.com/etcd-io/bbolt"
 )
 
 var (
@@ -108,6 +111,538 @@ func TestGet(t *testing.T) {
 
 }
 
+func TestViewShards(t *testing.T) {
+	db, err := Open(dir, mapFn, 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	ids := []string{}
+	err = db.ViewShards(func(id string, tx *bolt.Tx) error {
+		ids = append(ids, id)
+		if tx.Bucket(bucket) == nil {
+			t.Error("bucket not exists in", id)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(ids) != 1 || ids[0] != now.Format("2006-01") {
+		t.Error(ids)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	db, err := Open(dir, mapFn, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	prefix := createKey(now, []byte("del"))
+	err = db.Update(func(tx *MultiTx) error {
+		for _, suffix := range []string{"a", "b", "c"} {
+			if err := tx.Put(bucket, append(append([]byte{}, prefix...), suffix...), value); err != nil {
+				return err
+			}
+		}
+		return tx.Put(bucket, prefix, value)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shards := len(db.GetSizes())
+	deleted := 0
+	err = db.Update(func(tx *MultiTx) error {
+		if err := tx.Delete(bucket, prefix); err != nil {
+			return err
+		}
+		if err := tx.Delete(bucket, createKey(now.AddDate(-20, 0, 0), key)); err != nil {
+			return err
+		}
+		deleted, err = tx.DeletePrefix(bucket, prefix)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 3 {
+		t.Error(deleted)
+	}
+	if len(db.GetSizes()) != shards {
+		t.Error("delete created a shard")
+	}
+	count := 0
+	db.IteratePrefix(bucket, prefix, func(k []byte, v []byte) { count++ })
+	if count != 0 {
+		t.Error(count)
+	}
+	if _, err := db.Get(bucket, createKey(now, key)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPool(t *testing.T) {
+	poolDir := "test-pool"
+	defer os.RemoveAll(poolDir)
+	pool := NewPool(1)
+	db, err := Open(poolDir, mapFn, 0666, &Options{FillPercent: 0.9, Pool: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	months := []time.Time{now, now.AddDate(0, 1, 0), now.AddDate(0, 2, 0)}
+	for _, m := range months {
+		if err := db.BatchUpsert(bucket, createKey(m, key), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := pool.Stats()
+	if stats.Open != 1 || stats.Evictions != 2 {
+		t.Error(stats)
+	}
+	for _, m := range months {
+		if v, err := db.Get(bucket, createKey(m, key)); err != nil || !bytes.Equal(v, value) {
+			t.Error(v, err)
+		}
+	}
+
+	count := 0
+	db.Iterate(bucket, marshalTime(now), marshalTime(now.AddDate(0, 3, 0)), func(k []byte, v []byte) {
+		if count == 0 {
+			if _, err := db.Get(bucket, createKey(months[2], key)); err != nil {
+				t.Error(err)
+			}
+			if db.getShardArray()[0].db == nil {
+				t.Error("the iterated shard should be kept open", pool.Stats())
+			}
+		}
+		count++
+	})
+	if count != len(months) {
+		t.Error(count)
+	}
+	if stats := pool.Stats(); stats.Open != 1 || stats.Hits == 0 || stats.Misses == 0 {
+		t.Error(stats)
+	}
+}
+
+func TestPoolLockedFile(t *testing.T) {
+	poolDir := "test-pool-locked"
+	defer os.RemoveAll(poolDir)
+	pool := NewPool(1)
+	db, err := Open(poolDir, mapFn, 0666, &Options{FillPercent: 0.9, Pool: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	months := []time.Time{now, now.AddDate(0, 1, 0), now.AddDate(0, 2, 0)}
+	for _, m := range months {
+		if err := db.BatchUpsert(bucket, createKey(m, key), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	locker, err := bolt.Open(db.getShardArray()[0].path, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(timeout time.Duration) { openTimeout = timeout }(openTimeout)
+	openTimeout = time.Second
+
+	locked := make(chan error)
+	go func() {
+		_, err := db.Get(bucket, createKey(months[0], key))
+		locked <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	begin := time.Now()
+	if v, err := db.Get(bucket, createKey(months[1], key)); err != nil || !bytes.Equal(v, value) {
+		t.Error(v, err)
+	}
+	if elapsed := time.Since(begin); elapsed > openTimeout/2 {
+		t.Error("an other shard waited for the locked file's open", elapsed)
+	}
+	if err := <-locked; err == nil {
+		t.Error("the locked file is opened")
+	}
+
+	locker.Close()
+	if v, err := db.Get(bucket, createKey(months[0], key)); err != nil || !bytes.Equal(v, value) {
+		t.Error(v, err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	batchDir := "test-batch"
+	defer os.RemoveAll(batchDir)
+	db, err := Open(batchDir, mapFn, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	begin := time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local)
+	counter := createKey(begin, key)
+	if err := db.Batch(counter, func(tx *MultiTx) error { return tx.Put(bucket, counter, []byte{0}) }); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := db.Batch(counter, func(tx *MultiTx) error {
+				v, err := tx.Get(bucket, counter)
+				if err != nil {
+					return err
+				}
+				return tx.Put(bucket, counter, []byte{v[0] + 1})
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, err := db.Get(bucket, counter); err != nil || v[0] != 50 {
+		t.Error(v, err)
+	}
+
+	other := createKey(begin.AddDate(0, 1, 0), key)
+	err = db.Batch(counter, func(tx *MultiTx) error { return tx.Put(bucket, other, value) })
+	if err != ErrOtherShard {
+		t.Error(err)
+	}
+	if ids := db.ShardIDs(other, other); len(ids) != 0 {
+		t.Error("the other shard is created", ids)
+	}
+}
+
+func TestDeleteRangeCompact(t *testing.T) {
+	compactDir := "test-compact"
+	defer os.RemoveAll(compactDir)
+	db, err := Open(compactDir, mapFn, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	begin := time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local)
+	bigValue := bytes.Repeat(value, 256)
+	err = db.Update(func(tx *MultiTx) error {
+		for i := 0; i < 2000; i++ {
+			if err := tx.Put(bucket, createKey(begin.Add(time.Duration(i)*time.Minute), key), bigValue); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromKey, toKey := marshalTime(begin), marshalTime(begin.Add(1900*time.Minute))
+	deleted, err := db.DeleteRange(bucket, fromKey, toKey)
+	if err != nil || deleted != 1900 {
+		t.Error(deleted, err)
+	}
+	if ids := db.ShardIDs(fromKey, toKey); len(ids) != 1 || ids[0] != "2019-06" {
+		t.Error(ids)
+	}
+
+	lastKey := createKey(begin.Add(1999*time.Minute), key)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if v, err := db.Get(bucket, lastKey); err != nil || !bytes.Equal(v, bigValue) {
+				t.Error("read during the compaction", err)
+				return
+			}
+		}
+	}()
+	before, after, err := db.Compact("2019-06")
+	<-done
+	if err != nil || after >= before {
+		t.Error(before, after, err)
+	}
+	count := 0
+	db.Iterate(bucket, fromKey, marshalTime(begin.AddDate(0, 0, 2)), func(k []byte, v []byte) { count++ })
+	if count != 100 {
+		t.Error(count)
+	}
+	if err := db.Update(func(tx *MultiTx) error { return tx.Put(bucket, fromKey, value) }); err != nil {
+		t.Error(err)
+	}
+	if _, _, err := db.Compact("1999-01"); err == nil {
+		t.Error("missing shard compacted")
+	}
+}
+
+func TestCompactNestedView(t *testing.T) {
+	compactDir := "test-compact-nested"
+	defer os.RemoveAll(compactDir)
+	db, err := Open(compactDir, mapFn, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	begin := time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local)
+	if err := db.BatchUpsert(bucket, createKey(begin, key), value); err != nil {
+		t.Fatal(err)
+	}
+
+	compacted := make(chan error, 1)
+	iterated := make(chan int, 1)
+	go func() {
+		count := 0
+		db.Iterate(bucket, marshalTime(begin), marshalTime(begin.AddDate(0, 0, 1)), func(k []byte, v []byte) {
+			go func() {
+				_, _, err := db.Compact("2019-06")
+				compacted <- err
+			}()
+			time.Sleep(50 * time.Millisecond)
+			db.IteratePrefix(bucket, k, func(k []byte, v []byte) { count++ })
+		})
+		iterated <- count
+	}()
+
+	select {
+	case count := <-iterated:
+		if count != 1 {
+			t.Error(count)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the nested view is blocked by the compaction")
+	}
+	select {
+	case err := <-compacted:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the compaction is blocked")
+	}
+	if v, err := db.Get(bucket, createKey(begin, key)); err != nil || !bytes.Equal(v, value) {
+		t.Error(v, err)
+	}
+}
+
+func TestAtomicCommit(t *testing.T) {
+	atomicDir := "test-atomic"
+	defer os.RemoveAll(atomicDir)
+	db, err := Open(atomicDir, mapFn, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	begin := time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local)
+	keys := [][]byte{createKey(begin, key), createKey(begin.AddDate(0, 1, 0), key), createKey(begin.AddDate(0, 2, 0), key)}
+	old, kept := []byte("old"), createKey(begin.Add(time.Hour), key)
+	err = db.Update(func(tx *MultiTx) error {
+		if err := tx.Put(bucket, keys[0], old); err != nil {
+			return err
+		}
+		return tx.Put(bucket, kept, old)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	injected := errors.New("injected failure")
+	defer func() { beforeShardCommit = func(id string) error { return nil } }()
+	beforeShardCommit = func(id string) error {
+		if id == "2019-08" {
+			return injected
+		}
+		return nil
+	}
+	update := func() error {
+		return db.Update(func(tx *MultiTx) error {
+			for _, k := range keys {
+				if err := tx.Put(bucket, k, value); err != nil {
+					return err
+				}
+			}
+			return tx.Delete(bucket, kept)
+		})
+	}
+	if err := update(); err != injected {
+		t.Fatal(err)
+	}
+	if v, err := db.Get(bucket, keys[0]); err != nil || !bytes.Equal(v, old) {
+		t.Error("the first shard isn't rolled back", v, err)
+	}
+	if v, err := db.Get(bucket, kept); err != nil || !bytes.Equal(v, old) {
+		t.Error("the deleted key isn't restored", v, err)
+	}
+	if _, err := db.Get(bucket, keys[1]); err == nil {
+		t.Error("the second shard isn't rolled back")
+	}
+	if _, err := os.Stat(db.journalPath()); !os.IsNotExist(err) {
+		t.Error("journal is left behind", err)
+	}
+
+	beforeShardCommit = func(id string) error { return nil }
+	if err := update(); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		if v, err := db.Get(bucket, k); err != nil || !bytes.Equal(v, value) {
+			t.Error(v, err)
+		}
+	}
+}
+
+func TestUndoFailure(t *testing.T) {
+	undoDir := "test-undo"
+	defer os.RemoveAll(undoDir)
+	db, err := Open(undoDir, mapFn, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	begin := time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local)
+	keys := [][]byte{createKey(begin, key), createKey(begin.AddDate(0, 1, 0), key)}
+	old, later := []byte("old"), createKey(begin.Add(time.Hour), key)
+	if err := db.Update(func(tx *MultiTx) error { return tx.Put(bucket, keys[0], old) }); err != nil {
+		t.Fatal(err)
+	}
+
+	injected := errors.New("injected failure")
+	undoFailures := 0
+	defer func(delay time.Duration) {
+		beforeShardCommit = func(id string) error { return nil }
+		beforeUndo = func(id string) error { return nil }
+		undoRetryDelay = delay
+	}(undoRetryDelay)
+	undoRetryDelay = time.Millisecond
+	beforeShardCommit = func(id string) error {
+		if id == "2019-07" {
+			return injected
+		}
+		return nil
+	}
+	beforeUndo = func(id string) error {
+		if undoFailures > 0 {
+			undoFailures--
+			return injected
+		}
+		return nil
+	}
+	update := func() error {
+		return db.Update(func(tx *MultiTx) error {
+			for _, k := range keys {
+				if err := tx.Put(bucket, k, value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	undoFailures = undoRetries - 1
+	if err := update(); err != injected {
+		t.Fatal(err)
+	}
+	if v, err := db.Get(bucket, keys[0]); err != nil || !bytes.Equal(v, old) {
+		t.Error("the retried undo isn't done", v, err)
+	}
+	if err := db.BatchUpsert(bucket, later, value); err != nil {
+		t.Error("write refused after a successful undo", err)
+	}
+
+	undoFailures = undoRetries
+	if err := update(); err != injected {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(db.journalPath()); err != nil {
+		t.Error("the journal is removed", err)
+	}
+	if err := db.BatchUpsert(bucket, later, old); err == nil {
+		t.Error("BatchUpsert accepted after a failed undo")
+	}
+	if err := db.Update(func(tx *MultiTx) error { return tx.Put(bucket, later, old) }); err == nil {
+		t.Error("Update accepted after a failed undo")
+	}
+	db.Close()
+
+	beforeShardCommit = func(id string) error { return nil }
+	db, err = Open(undoDir, mapFn, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if v, err := db.Get(bucket, keys[0]); err != nil || !bytes.Equal(v, old) {
+		t.Error("the committed shard isn't rolled back", v, err)
+	}
+	if v, err := db.Get(bucket, later); err != nil || !bytes.Equal(v, value) {
+		t.Error("the write before the failure is lost", v, err)
+	}
+	if err := update(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	replayDir := "test-replay"
+	defer os.RemoveAll(replayDir)
+	db, err := Open(replayDir, mapFn, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	begin := time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local)
+	keys := [][]byte{createKey(begin, key), createKey(begin.AddDate(0, 1, 0), key)}
+	old := []byte("old")
+	if err := db.Update(func(tx *MultiTx) error { return tx.Put(bucket, keys[0], old) }); err != nil {
+		t.Fatal(err)
+	}
+
+	// the process stops after the first shard's commit
+	tx := db.Begin(true)
+	for _, k := range keys {
+		if err := tx.Put(bucket, k, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	journal := []journalShard{}
+	for _, v := range tx.txs {
+		journal = append(journal, journalShard{v.shard.id, v.undo})
+	}
+	if err := db.writeJournal(journal); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.txs[0].tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	tx.txs = tx.txs[1:]
+	tx.Rollback()
+	db.Close()
+
+	db, err = Open(replayDir, mapFn, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if v, err := db.Get(bucket, keys[0]); err != nil || !bytes.Equal(v, old) {
+		t.Error("the committed shard isn't rolled back", v, err)
+	}
+	if _, err := db.Get(bucket, keys[1]); err == nil {
+		t.Error("the uncommitted key exists")
+	}
+	if _, err := os.Stat(db.journalPath()); !os.IsNotExist(err) {
+		t.Error("journal is left behind", err)
+	}
+}
+
 func marshalTime(t time.Time) []byte {
 	nsec := t.UnixNano()
 	enc := []byte{
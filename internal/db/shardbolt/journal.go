@@ -0,0 +1,188 @@
+package shardbolt
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "github.com/etcd-io/bbolt"
+)
+
+// journalName is the undo journal of the multi-shard commit in progress
+const journalName = "multitx.journal"
+
+// undoRecord is a key's value before the transaction
+type undoRecord struct {
+	Bucket []byte
+	Key    []byte
+	Value  []byte
+	Exists bool
+}
+
+// journalShard is the undo records of a shard
+type journalShard struct {
+	ID      string
+	Records []undoRecord
+}
+
+// undoRetries is the count of the attempts to restore the shards of a failed multi-shard commit
+const undoRetries = 5
+
+// undoRetryDelay is the wait between the restore attempts
+var undoRetryDelay = 200 * time.Millisecond
+
+// beforeShardCommit is called before every shard's commit of a multi-shard transaction,
+// the tests use it for injecting failures
+var beforeShardCommit = func(id string) error { return nil }
+
+// beforeUndo is called before every shard's restore, the tests use it for injecting failures
+var beforeUndo = func(id string) error { return nil }
+
+func (db *DB) journalPath() string {
+	return filepath.Join(db.dir, journalName)
+}
+
+// writeJournal stores the undo records durably, the journal is written fully or not at all
+func (db *DB) writeJournal(shards []journalShard) error {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(shards); err != nil {
+		return err
+	}
+	tmp := db.journalPath() + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, db.mode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, db.journalPath()); err != nil {
+		return err
+	}
+	return syncDir(db.dir)
+}
+
+func (db *DB) removeJournal() error {
+	if err := os.Remove(db.journalPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return syncDir(db.dir)
+}
+
+// undo restores the values of the journal in the shards
+func (db *DB) undo(shards []journalShard) error {
+	for _, js := range shards {
+		var s *shard
+		for _, v := range db.getShardArray() {
+			if v.id == js.ID {
+				s = v
+			}
+		}
+		if s == nil {
+			log.Println("journal's shard not found", js.ID)
+			continue
+		}
+		if err := beforeUndo(js.ID); err != nil {
+			return err
+		}
+		err := s.update(func(tx *bolt.Tx) error {
+			for _, r := range js.Records {
+				b := tx.Bucket(r.Bucket)
+				if !r.Exists {
+					if b == nil {
+						continue
+					}
+					if err := b.Delete(r.Key); err != nil {
+						return err
+					}
+					continue
+				}
+				b, err := tx.CreateBucketIfNotExists(r.Bucket)
+				if err != nil {
+					return err
+				}
+				if err := b.Put(r.Key, r.Value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// undoWithRetry restores the journal's values, the failed attempts are retried
+func (db *DB) undoWithRetry(shards []journalShard) error {
+	var err error
+	for i := 0; i < undoRetries; i++ {
+		if i > 0 {
+			time.Sleep(undoRetryDelay)
+		}
+		if err = db.undo(shards); err == nil {
+			return nil
+		}
+		log.Println("can't roll back the committed shards in", db.dir, "cause:", err)
+	}
+	return err
+}
+
+// stopWrites makes the DB read-only because the journal is left behind, its replay on the next Open
+// would overwrite the writes which are accepted after the failure
+func (db *DB) stopWrites(cause error) {
+	log.Println("the journal is left behind in", db.dir, "the DB doesn't accept writes until it's reopened, cause:", cause)
+	db.writeErr.Store(fmt.Errorf("shardbolt: the DB is read-only until it's reopened, the multi-shard commit's journal is left behind: %v", cause))
+}
+
+// writable returns an error if the DB doesn't accept writes
+func (db *DB) writable() error {
+	if err := db.writeErr.Load(); err != nil {
+		return err.(error)
+	}
+	return nil
+}
+
+// replayJournal rolls back the multi-shard commit which was interrupted
+func (db *DB) replayJournal() error {
+	os.Remove(db.journalPath() + ".tmp")
+	data, err := ioutil.ReadFile(db.journalPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var shards []journalShard
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&shards); err != nil {
+		return err
+	}
+	log.Println("rolling back an interrupted multi-shard commit in", db.dir)
+	if err := db.undo(shards); err != nil {
+		return err
+	}
+	return db.removeJournal()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
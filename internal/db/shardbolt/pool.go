@@ -0,0 +1,201 @@
+package shardbolt
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	bolt "github.com/etcd-io/bbolt"
+)
+
+var errShardClosed = errors.New("shard is closed")
+
+// openTimeout is the wait for an other process' lock on a shard file
+var openTimeout = 10 * time.Second
+
+// Pool limits the open shard files of the DBs which share it, the least recently used
+// idle shards are closed and they are reopened on demand
+type Pool struct {
+	mutex     sync.Mutex
+	cond      *sync.Cond
+	max       int
+	lru       *list.List
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// PoolStats is the pool's statistics
+type PoolStats struct {
+	Open      int
+	Max       int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// NewPool creates a pool with max open shard files, 0 means unlimited
+func NewPool(max int) *Pool {
+	p := &Pool{max: max, lru: list.New()}
+	p.cond = sync.NewCond(&p.mutex)
+	return p
+}
+
+// SetMax changes the limit and closes the shards above it
+func (p *Pool) SetMax(max int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.max = max
+	p.evict()
+}
+
+// Stats returns the pool's statistics
+func (p *Pool) Stats() PoolStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return PoolStats{p.lru.Len(), p.max, p.hits, p.misses, p.evictions}
+}
+
+// acquire opens the shard if needed and protects it from the eviction until the release.
+// The file is opened outside the pool's mutex, so the other shards aren't blocked meanwhile,
+// the shard's other users wait until it's opened
+func (p *Pool) acquire(s *shard) (*bolt.DB, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for s.locked || s.opening {
+		p.cond.Wait()
+	}
+	if s.closed {
+		return nil, errShardClosed
+	}
+	if s.db != nil {
+		p.hits++
+		p.lru.MoveToFront(s.elem)
+		s.refs++
+		p.evict()
+		return s.db, nil
+	}
+	p.misses++
+	s.opening = true
+	s.refs++
+	p.mutex.Unlock()
+	db, err := openShardFile(s)
+	p.mutex.Lock()
+	s.opening = false
+	p.cond.Broadcast()
+	if err != nil {
+		s.refs--
+		return nil, err
+	}
+	s.db = db
+	s.elem = p.lru.PushFront(s)
+	p.evict()
+	return s.db, nil
+}
+
+// openShardFile opens the shard's bolt file, it fails after openTimeout
+// if an other process holds the file's lock
+func openShardFile(s *shard) (*bolt.DB, error) {
+	options := bolt.Options{}
+	if s.boltOptions != nil {
+		options = *s.boltOptions
+	}
+	if options.Timeout == 0 {
+		options.Timeout = openTimeout
+	}
+	db, err := bolt.Open(s.path, s.mode, &options)
+	if err != nil {
+		return nil, fmt.Errorf("can't open shard %v: %v", s.path, err)
+	}
+	return db, nil
+}
+
+func (p *Pool) release(s *shard) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	s.refs--
+	if s.refs == 0 {
+		p.cond.Broadcast()
+	}
+	p.evict()
+}
+
+// lock waits until the shard is idle and closes its file, the new users wait until the unlock.
+// The new users aren't blocked while the running ones finish, because they may be nested in them
+// (e.g. an IteratePrefix in an Iterate callback), so a busy shard may wait long for an idle moment
+func (p *Pool) lock(s *shard) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for s.locked || s.refs > 0 {
+		p.cond.Wait()
+	}
+	if s.closed {
+		return errShardClosed
+	}
+	s.locked = true
+	if err := p.closeShard(s); err != nil {
+		s.locked = false
+		p.cond.Broadcast()
+		return err
+	}
+	return nil
+}
+
+func (p *Pool) unlock(s *shard) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	s.locked = false
+	p.cond.Broadcast()
+}
+
+// evict closes the least recently used idle shards above the limit,
+// the shards in use are kept open even if the limit is exceeded
+func (p *Pool) evict() {
+	if p.max <= 0 {
+		return
+	}
+	for e := p.lru.Back(); e != nil && p.lru.Len() > p.max; {
+		s := e.Value.(*shard)
+		e = e.Prev()
+		if s.refs > 0 {
+			continue
+		}
+		if err := p.closeShard(s); err != nil {
+			log.Println("can't close shard", s.path, "cause:", err)
+		}
+		p.evictions++
+	}
+}
+
+func (p *Pool) closeShard(s *shard) error {
+	if s.db == nil {
+		return nil
+	}
+	p.lru.Remove(s.elem)
+	s.elem = nil
+	db := s.db
+	s.db = nil
+	return db.Close()
+}
+
+// close closes the shard for good, the running transactions are waited
+func (p *Pool) close(s *shard) error {
+	p.mutex.Lock()
+	for s.locked || s.opening {
+		p.cond.Wait()
+	}
+	s.closed = true
+	if s.db == nil {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.lru.Remove(s.elem)
+	s.elem = nil
+	db := s.db
+	s.db = nil
+	p.mutex.Unlock()
+	return db.Close()
+}
@@ -1,7 +1,11 @@
 package shardbolt
 
 import (
+	"bytes"
+	"container/list"
 	"fmt"
+	"log"
+	"os"
 	"sort"
 	"strings"
 
@@ -9,24 +13,82 @@ import (
 )
 
 type shard struct {
-	id string
-	db *bolt.DB
+	id          string
+	from        []byte
+	to          []byte
+	path        string
+	mode        os.FileMode
+	boltOptions *bolt.Options
+	pool        *Pool
+
+	// these are guarded by the pool's mutex
+	db      *bolt.DB
+	refs    int
+	closed  bool
+	locked  bool
+	opening bool
+	elem    *list.Element
+}
+
+func (db *DB) newShard(shardID string) *shard {
+	s := &shard{
+		id:          shardID,
+		path:        db.dir + "/" + shardID + ".bolt",
+		mode:        db.mode,
+		boltOptions: db.options.boltOptions,
+		pool:        db.options.Pool,
+	}
+	if db.options.ShardRange != nil {
+		from, to, err := db.options.ShardRange(shardID)
+		if err != nil {
+			log.Println("can't get the range of shard", s.path, "cause:", err)
+		} else {
+			s.from, s.to = from, to
+		}
+	}
+	return s
 }
 
-func (db *DB) openShard(shardID string) (*shard, error) {
-	actualDB, err := bolt.Open(db.dir+"/"+shardID+".bolt", db.mode, db.options.boltOptions)
+func (s *shard) hasRange() bool {
+	return s.from != nil && s.to != nil
+}
+
+// view runs fn in a read transaction, the shard is opened if it's closed
+func (s *shard) view(fn func(tx *bolt.Tx) error) error {
+	db, err := s.pool.acquire(s)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &shard{shardID, actualDB}, nil
+	defer s.pool.release(s)
+	return db.View(fn)
+}
+
+// update runs fn in a read-write transaction, the shard is opened if it's closed
+func (s *shard) update(fn func(tx *bolt.Tx) error) error {
+	db, err := s.pool.acquire(s)
+	if err != nil {
+		return err
+	}
+	defer s.pool.release(s)
+	return db.Update(fn)
+}
+
+// batch runs fn in a batched transaction, the shard is opened if it's closed
+func (s *shard) batch(fn func(tx *bolt.Tx) error) error {
+	db, err := s.pool.acquire(s)
+	if err != nil {
+		return err
+	}
+	defer s.pool.release(s)
+	return db.Batch(fn)
 }
 
 func (s *shard) closeDB() error {
-	return s.db.Close()
+	return s.pool.close(s)
 }
 
 func (db *DB) getShardFileName(s *shard) string {
-	return db.dir + "/" + s.id + ".bolt"
+	return s.path
 }
 
 func getShardIDFromFilename(fname string) (string, error) {
@@ -46,9 +108,15 @@ func (db *DB) getActualShard(key []byte) *shard {
 			return v
 		}
 	}
+	for _, v := range shards {
+		if v.hasRange() && bytes.Compare(v.from, key) <= 0 && bytes.Compare(key, v.to) < 0 {
+			return v
+		}
+	}
 	return nil
 }
 
+// getShards returns the shards which may contain keys between fromKey and toKey
 func (db *DB) getShards(fromKey []byte, toKey []byte) []*shard {
 	fromID := db.mapFn(fromKey)
 	toID := db.mapFn(toKey)
@@ -57,7 +125,11 @@ func (db *DB) getShards(fromKey []byte, toKey []byte) []*shard {
 	shards := db.getShardArray()
 
 	for _, v := range shards {
-		if fromID <= v.id && v.id <= toID {
+		if v.hasRange() {
+			if bytes.Compare(v.from, toKey) <= 0 && bytes.Compare(fromKey, v.to) < 0 {
+				out = append(out, v)
+			}
+		} else if fromID <= v.id && v.id <= toID {
 			out = append(out, v)
 		}
 	}
@@ -66,8 +138,13 @@ func (db *DB) getShards(fromKey []byte, toKey []byte) []*shard {
 
 func (db *DB) createActualShard(key []byte) (*shard, error) {
 	shardID := db.mapFn(key)
-	newShard, err := db.openShard(shardID)
-	if err != nil {
+	newShard := db.newShard(shardID)
+	onCreate := db.options.OnCreate
+	if onCreate == nil {
+		onCreate = func(tx *bolt.Tx) error { return nil }
+	}
+	if err := newShard.update(onCreate); err != nil {
+		newShard.closeDB()
 		return nil, err
 	}
 	shards := db.getShardArray()
@@ -75,7 +152,7 @@ func (db *DB) createActualShard(key []byte) (*shard, error) {
 	newShards := make(shardArray, len(shards), len(shards)+1)
 	copy(newShards, shards)
 	newShards = append(newShards, newShard)
-	sortShards(newShards)
+	db.sortShards(newShards)
 	db.setShardArray(newShards)
 	return newShard, nil
 }
@@ -98,8 +175,14 @@ func (db *DB) ensureShard(key []byte) (*shard, error) {
 	return actualShard, nil
 }
 
-func sortShards(shards shardArray) {
-	sort.Slice(shards, func(i, j int) bool {
+// sortShards sorts the shards by their ranges' start, or by their IDs if the ranges are unknown
+func (db *DB) sortShards(shards shardArray) {
+	sort.SliceStable(shards, func(i, j int) bool {
+		if shards[i].hasRange() && shards[j].hasRange() {
+			if c := bytes.Compare(shards[i].from, shards[j].from); c != 0 {
+				return c < 0
+			}
+		}
 		return shards[i].id < shards[j].id
 	})
 }
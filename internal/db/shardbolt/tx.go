@@ -1,6 +1,7 @@
 package shardbolt
 
 import (
+	"bytes"
 	"log"
 
 	bolt "github.com/etcd-io/bbolt"
@@ -10,15 +11,42 @@ type MultiTx struct {
 	db        *DB
 	writeable bool
 	txs       []*shardTx
+	// batch is set in the Batch's transactions, they can't access the other shards
+	batch bool
 }
 
 type shardTx struct {
-	id string
-	tx *bolt.Tx
+	shard   *shard
+	tx      *bolt.Tx
+	undo    []undoRecord
+	touched map[string]bool
+}
+
+// remember saves the key's value before its first change in the transaction
+func (stx *shardTx) remember(b *bolt.Bucket, bucket []byte, key []byte) {
+	id := string(bucket) + "\x00" + string(key)
+	if stx.touched[id] {
+		return
+	}
+	if stx.touched == nil {
+		stx.touched = map[string]bool{}
+	}
+	stx.touched[id] = true
+	r := undoRecord{
+		Bucket: append([]byte{}, bucket...),
+		Key:    append([]byte{}, key...),
+	}
+	if b != nil {
+		if v := b.Get(key); v != nil {
+			r.Value = append([]byte{}, v...)
+			r.Exists = true
+		}
+	}
+	stx.undo = append(stx.undo, r)
 }
 
 func (db *DB) Begin(writeable bool) *MultiTx {
-	return &MultiTx{db, writeable, nil}
+	return &MultiTx{db: db, writeable: writeable}
 }
 
 func (tx *MultiTx) Rollback() error {
@@ -29,11 +57,24 @@ func (tx *MultiTx) Rollback() error {
 			log.Println(err)
 			errAny = err
 		}
+		v.shard.pool.release(v.shard)
 	}
+	tx.txs = nil
 	return errAny
 }
 
+// Commit commits the shards' transactions. A transaction which spans multiple shards is all-or-nothing:
+// the previous values are journaled first and restored if a shard's commit fails or on the next Open
+// if the process stops during the commit. If the restore fails too, the DB doesn't accept writes until
+// it's reopened, so the journal's replay can't overwrite the newer writes.
+//
+// The restore puts back the journaled values of the keys, a BatchUpsert or a single-shard transaction
+// which writes the same keys between the failed commit and the restore is overwritten. The callers
+// mustn't write the keys of a running multi-shard transaction concurrently.
 func (tx *MultiTx) Commit() error {
+	if tx.writeable && len(tx.txs) > 1 {
+		return tx.commitJournaled()
+	}
 	var errAny error
 	for _, v := range tx.txs {
 		err := v.tx.Commit()
@@ -41,10 +82,58 @@ func (tx *MultiTx) Commit() error {
 			log.Println(err)
 			errAny = err
 		}
+		v.shard.pool.release(v.shard)
 	}
+	tx.txs = nil
 	return errAny
 }
 
+func (tx *MultiTx) commitJournaled() error {
+	txs := tx.txs
+	tx.txs = nil
+	tx.db.commitMutex.Lock()
+	defer tx.db.commitMutex.Unlock()
+
+	journal := make([]journalShard, len(txs))
+	for i, v := range txs {
+		journal[i] = journalShard{v.shard.id, v.undo}
+	}
+	if err := tx.db.writeJournal(journal); err != nil {
+		for _, v := range txs {
+			v.tx.Rollback()
+			v.shard.pool.release(v.shard)
+		}
+		return err
+	}
+	for i, v := range txs {
+		err := beforeShardCommit(v.shard.id)
+		if err == nil {
+			err = v.tx.Commit()
+		}
+		if err != nil {
+			log.Println("can't commit shard", v.shard.path, "cause:", err)
+			for _, r := range txs[i:] {
+				r.tx.Rollback()
+				r.shard.pool.release(r.shard)
+			}
+			if uerr := tx.db.undoWithRetry(journal[:i]); uerr != nil {
+				tx.db.stopWrites(uerr)
+				return err
+			}
+			if rerr := tx.db.removeJournal(); rerr != nil {
+				tx.db.stopWrites(rerr)
+			}
+			return err
+		}
+		v.shard.pool.release(v.shard)
+	}
+	if err := tx.db.removeJournal(); err != nil {
+		tx.db.stopWrites(err)
+		return err
+	}
+	return nil
+}
+
 func (tx *MultiTx) Put(bucket []byte, key []byte, value []byte) error {
 	stx, err := tx.ensureTx(key)
 	if err != nil {
@@ -56,27 +145,106 @@ func (tx *MultiTx) Put(bucket []byte, key []byte, value []byte) error {
 		return err
 	}
 	b.FillPercent = tx.db.options.FillPercent
+	stx.remember(b, bucket, key)
 	return b.Put(key, value)
 }
 
+// Get returns the value of the key, or nil if it doesn't exist, the writes of this transaction are visible
+func (tx *MultiTx) Get(bucket []byte, key []byte) ([]byte, error) {
+	stx, err := tx.getTx(key, false)
+	if err != nil || stx == nil {
+		return nil, err
+	}
+	b := stx.tx.Bucket(bucket)
+	if b == nil {
+		return nil, nil
+	}
+	return b.Get(key), nil
+}
+
+// Delete deletes the key, a missing key or shard is not an error
+func (tx *MultiTx) Delete(bucket []byte, key []byte) error {
+	stx, err := tx.getTx(key, false)
+	if err != nil || stx == nil {
+		return err
+	}
+	b := stx.tx.Bucket(bucket)
+	if b == nil {
+		return nil
+	}
+	stx.remember(b, bucket, key)
+	return b.Delete(key)
+}
+
+// DeletePrefix deletes the keys with the prefix from the prefix's shard and returns their count
+func (tx *MultiTx) DeletePrefix(bucket []byte, prefix []byte) (int, error) {
+	stx, err := tx.getTx(prefix, false)
+	if err != nil || stx == nil {
+		return 0, err
+	}
+	b := stx.tx.Bucket(bucket)
+	if b == nil {
+		return 0, nil
+	}
+	deleted := 0
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+		stx.remember(b, bucket, k)
+		if err := c.Delete(); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
 func (tx *MultiTx) ensureTx(key []byte) (*shardTx, error) {
-	id := tx.db.mapFn(key)
+	return tx.getTx(key, true)
+}
+
+// getTx returns the transaction of the key's shard, the shard is created only if create is set
+func (tx *MultiTx) getTx(key []byte, create bool) (*shardTx, error) {
+	if tx.writeable {
+		if err := tx.db.writable(); err != nil {
+			return nil, err
+		}
+	}
+	actualShard := tx.db.getActualShard(key)
+	if tx.batch {
+		if actualShard == tx.txs[0].shard {
+			return tx.txs[0], nil
+		}
+		if actualShard == nil && !create {
+			return nil, nil
+		}
+		return nil, ErrOtherShard
+	}
+	if actualShard == nil {
+		if !create {
+			return nil, nil
+		}
+		var err error
+		actualShard, err = tx.db.ensureShard(key)
+		if err != nil {
+			return nil, err
+		}
+	}
 	for _, v := range tx.txs {
-		if v.id == id {
+		if v.shard == actualShard {
 			return v, nil
 		}
 	}
 
-	actualShard, err := tx.db.ensureShard(key)
+	bdb, err := actualShard.pool.acquire(actualShard)
 	if err != nil {
 		return nil, err
 	}
-
-	btx, err := actualShard.db.Begin(tx.writeable)
+	btx, err := bdb.Begin(tx.writeable)
 	if err != nil {
+		actualShard.pool.release(actualShard)
 		return nil, err
 	}
-	stx := &shardTx{id, btx}
+	stx := &shardTx{shard: actualShard, tx: btx}
 	tx.txs = append(tx.txs, stx)
 	return stx, nil
 }
@@ -1,13 +1,15 @@
 package db
 
 import (
+	"bytes"
 	"encoding/base64"
+	"fmt"
 	"log"
 	"sort"
 	"strconv"
 	"time"
 
-	"github.com/soyersoyer/rightana/internal/db/shardbolt"
+	"github.com/soyersoyer/rightana/internal/hll"
 )
 
 type empty struct{}
@@ -30,6 +32,35 @@ type CollectionDataInputT struct {
 	Bucket   string
 	Timezone string
 	Filter   map[string]string
+	Limit    int    // maximum number of rows per list, 0 means unlimited
+	Offset   int    // skipped rows per list
+	Sort     string // count or name
+	Order    string // asc or desc
+	Cursor   string // the last seen session key, the sessions continue after it
+}
+
+type pagingT struct {
+	limit  int
+	offset int
+	byName bool
+	desc   bool
+}
+
+func getPaging(input *CollectionDataInputT) pagingT {
+	p := pagingT{
+		limit:  input.Limit,
+		offset: input.Offset,
+		byName: input.Sort == "name",
+	}
+	switch input.Order {
+	case "asc":
+		p.desc = false
+	case "desc":
+		p.desc = true
+	default:
+		p.desc = !p.byName
+	}
+	return p
 }
 
 // CollectionDataT is the collection's data struct for the clients
@@ -135,6 +166,40 @@ func (bg *bucketGen) Close() []*bucketSumT {
 	return bucketSums
 }
 
+// uniqueGen estimates the distinct visitor IDs of the buckets
+type uniqueGen struct {
+	loc      *time.Location
+	timeMap  func(time.Time, *time.Location) int64
+	sketches map[int64]*hll.Sketch
+}
+
+func createUniqueGen(bucketType string, timezone string) *uniqueGen {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.Local
+	}
+	return &uniqueGen{loc, getTimeMap(bucketType), map[int64]*hll.Sketch{}}
+}
+
+func (ug *uniqueGen) Add(t time.Time, visitorID uint64) {
+	bucket := ug.timeMap(t, ug.loc)
+	sketch, ok := ug.sketches[bucket]
+	if !ok {
+		sketch = hll.New()
+		ug.sketches[bucket] = sketch
+	}
+	sketch.Add(visitorID)
+}
+
+func (ug *uniqueGen) Close() []*bucketSumT {
+	bucketSums := make([]*bucketSumT, 0, len(ug.sketches))
+	for k, v := range ug.sketches {
+		bucketSums = append(bucketSums, &bucketSumT{k, v.Count()})
+	}
+	sort.Slice(bucketSums, func(i, j int) bool { return bucketSums[i].Bucket < bucketSums[j].Bucket })
+	return bucketSums
+}
+
 // CollectionSummary contains summary for the collection
 type CollectionSummary struct {
 	SessionCount    int           `json:"session_count"`
@@ -210,12 +275,20 @@ func GetCollectionSummary(collectionID string, dayBefore int, options Collection
 	return cs, nil
 }
 
-func readSessions(sdb *shardbolt.DB, from, to time.Time,
+func readSessions(sdb ShardStore, from, to time.Time,
+	filter map[string]string,
+	sessionFunc func(session *ExtSession),
+	pvFunc func(pv *ExtPageview)) {
+	readSessionsSince(sdb, from.Add(-time.Hour*24), from, to, filter, sessionFunc, pvFunc)
+}
+
+// readSessionsSince reads the sessions started in [from, to) and the pageviews viewed in [from, to),
+// the pageviews' sessions are scanned from the possibleSessionStart
+func readSessionsSince(sdb ShardStore, possibleSessionStart, from, to time.Time,
 	filter map[string]string,
 	sessionFunc func(session *ExtSession),
 	pvFunc func(pv *ExtPageview)) {
 
-	possibleSessionStart := from.Add(-time.Hour * 24)
 	fromKey := marshalTime(possibleSessionStart)
 	toKey := marshalTime(to)
 
@@ -228,6 +301,7 @@ func readSessions(sdb *shardbolt.DB, from, to time.Time,
 
 	sdb.Iterate(BSession, fromKey, toKey, func(k []byte, v []byte) {
 		session.PageviewCount = 0
+		session.RawKey = k
 		session.Key = EncodeSessionKey(k)
 		session.Begin, err = unmarshalTime(k)
 		if err != nil {
@@ -242,11 +316,13 @@ func readSessions(sdb *shardbolt.DB, from, to time.Time,
 			return
 		}
 		matchSession := false
+		// the sessions before the activity tracking last until their last pageview
+		lastPageview := session.Begin
 		sdb.IteratePrefix(BPageview, k, func(pvk []byte, pvv []byte) {
 			pageview.Time = GetTimeFromPVKey(pvk)
 			session.PageviewCount++
-			if session.Duration == 0 {
-				session.Duration = int32(pageview.Time.Sub(session.Begin).Seconds())
+			if pageview.Time.After(lastPageview) {
+				lastPageview = pageview.Time
 			}
 			/* TODO - ability to skip the pageview decoding */
 			if err := protoDecode(pvv, &pageview.Pageview); err != nil {
@@ -258,17 +334,20 @@ func readSessions(sdb *shardbolt.DB, from, to time.Time,
 			}
 			matchSession = true
 
-			if pageview.Time.After(from) && pageview.Time.Before(to) && pvFunc != nil {
+			if !pageview.Time.Before(from) && pageview.Time.Before(to) && pvFunc != nil {
 				pvFunc(pageview)
 			}
 		})
+		if session.LastActivity == 0 && session.Duration == 0 {
+			session.Duration = int32(lastPageview.Sub(session.Begin).Seconds())
+		}
 		if pvFilter != nil && !matchSession {
 			return
 		}
 		if !sessionFilter.matchPVC(session) {
 			return
 		}
-		if session.Begin.After(from) {
+		if !session.Begin.Before(from) {
 			sessionFunc(session)
 		}
 	})
@@ -331,6 +410,22 @@ type CollectionStatDataT struct {
 	CitySums             []sumT   `json:"city_sums"`
 	ASNameSums           []sumT   `json:"as_name_sums"`
 	ReferrerSums         []sumT   `json:"referrer_sums"`
+
+	// The visitors are counted by their daily visitor IDs, so only the daily counts are unique,
+	// the others are visitor-days: a visitor of more days is counted once per day
+	VisitorDays         totalT        `json:"visitor_days"`
+	DailyUniqueVisitors []*bucketSumT `json:"daily_unique_visitors"`
+	WeeklyVisitorDays   []*bucketSumT `json:"weekly_visitor_days"`
+	MonthlyVisitorDays  []*bucketSumT `json:"monthly_visitor_days"`
+
+	// The sessions without the tracker's visit counter are left out
+	VisitorTypeSums        []sumT `json:"visitor_type_sums"`
+	VisitCountSums         []sumT `json:"visit_count_sums"`
+	DaysSinceLastVisitSums []sumT `json:"days_since_last_visit_sums"`
+
+	// The sums of the custom properties' values by key
+	SessionPropertySums  map[string][]sumT `json:"session_property_sums"`
+	PageviewPropertySums map[string][]sumT `json:"pageview_property_sums"`
 }
 
 type totalT struct {
@@ -357,14 +452,42 @@ func getTotal(m *map[string]int) int {
 	return total
 }
 
-func getSums(m *map[string]int) []sumT {
+func getSums(m *map[string]int, p pagingT) []sumT {
 	output := []sumT{}
 	total := getTotal(m)
 	for k, v := range *m {
 		output = append(output, sumT{Name: k, Count: v, Percent: float64(v) / float64(total)})
 	}
-	sort.Slice(output, func(i, j int) bool { return output[i].Count > output[j].Count })
-	return output
+	sort.Slice(output, func(i, j int) bool {
+		a, b := output[i], output[j]
+		if p.byName {
+			if p.desc {
+				return a.Name > b.Name
+			}
+			return a.Name < b.Name
+		}
+		if a.Count != b.Count {
+			if p.desc {
+				return a.Count > b.Count
+			}
+			return a.Count < b.Count
+		}
+		return a.Name < b.Name
+	})
+	return pageSums(output, p)
+}
+
+func pageSums(sums []sumT, p pagingT) []sumT {
+	if p.offset > 0 {
+		if p.offset >= len(sums) {
+			return []sumT{}
+		}
+		sums = sums[p.offset:]
+	}
+	if p.limit > 0 && p.limit < len(sums) {
+		sums = sums[:p.limit]
+	}
+	return sums
 }
 
 func getPercentByKey(m *map[string]int, key string) float64 {
@@ -389,6 +512,10 @@ type sessionFilter struct {
 	City             *string
 	ASName           *string
 	Referrer         *string
+	VisitorType      *string
+	VisitCount       *int32
+	DaysSinceLast    *int32
+	Properties       *propertyFilter
 }
 
 func createSessionFilter(filter map[string]string) *sessionFilter {
@@ -439,6 +566,28 @@ func createSessionFilter(filter map[string]string) *sessionFilter {
 	if v, ok := filter["referrer"]; ok {
 		sf.Referrer = &v
 	}
+	if v, ok := filter["visitor_type"]; ok {
+		sf.VisitorType = &v
+	}
+	if v, ok := filter["visit_count"]; ok {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			log.Println("bad visit_count int filter", v)
+		} else {
+			ic := int32(i)
+			sf.VisitCount = &ic
+		}
+	}
+	if v, ok := filter["days_since_last_visit"]; ok {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			log.Println("bad days_since_last_visit int filter", v)
+		} else {
+			ic := int32(i)
+			sf.DaysSinceLast = &ic
+		}
+	}
+	sf.Properties = createPropertyFilter(filter, SessionPropertyFilter)
 	if *sf == *empty {
 		return nil
 	}
@@ -485,6 +634,18 @@ func (sf *sessionFilter) match(session *ExtSession) bool {
 	if sf.Referrer != nil && *sf.Referrer != session.Referrer {
 		return false
 	}
+	if sf.VisitorType != nil && *sf.VisitorType != GetVisitorType(&session.Session) {
+		return false
+	}
+	if sf.VisitCount != nil && *sf.VisitCount != session.VisitCount {
+		return false
+	}
+	if sf.DaysSinceLast != nil && (GetVisitorType(&session.Session) != VisitorReturning || *sf.DaysSinceLast != session.DaysSinceLastVisit) {
+		return false
+	}
+	if !sf.Properties.match(session.Properties) {
+		return false
+	}
 	return true
 }
 func (sf *sessionFilter) matchPVC(session *ExtSession) bool {
@@ -500,6 +661,7 @@ func (sf *sessionFilter) matchPVC(session *ExtSession) bool {
 type pageviewFilter struct {
 	Page        *string
 	QueryString *string
+	Properties  *propertyFilter
 }
 
 func createPageviewFilter(filter map[string]string) *pageviewFilter {
@@ -511,6 +673,7 @@ func createPageviewFilter(filter map[string]string) *pageviewFilter {
 	if v, ok := filter["query_string"]; ok {
 		pvf.QueryString = &v
 	}
+	pvf.Properties = createPropertyFilter(filter, PageviewPropertyFilter)
 	if *pvf == *empty {
 		return nil
 	}
@@ -527,6 +690,9 @@ func (pvf *pageviewFilter) match(pv *Pageview) bool {
 	if pvf.QueryString != nil && *pvf.QueryString != pv.QueryString {
 		return false
 	}
+	if !pvf.Properties.match(pv.Properties) {
+		return false
+	}
 	return true
 }
 
@@ -545,6 +711,11 @@ func GetStatistics(collection *Collection, input *CollectionDataInputT) (*Collec
 	prevPageviewTotal := 0
 	sumOfPrevSessionLength := 0
 	prevPageviewCountSums := make(map[string]int)
+	visitorDays := hll.New()
+	prevVisitorDays := hll.New()
+	dailyUniques := createUniqueGen("day", input.Timezone)
+	weeklyVisitorDays := createUniqueGen("week", input.Timezone)
+	monthlyVisitorDays := createUniqueGen("month", input.Timezone)
 
 	pageSums := make(map[string]int)
 	queryStringSums := make(map[string]int)
@@ -562,8 +733,14 @@ func GetStatistics(collection *Collection, input *CollectionDataInputT) (*Collec
 	citySums := make(map[string]int)
 	asNameSums := make(map[string]int)
 	referrerSums := make(map[string]int)
+	visitorTypeSums := make(map[string]int)
+	visitCountSums := make(map[string]int)
+	daysSinceLastVisitSums := make(map[string]int)
+	sessionPropertySums := make(map[string]map[string]int)
+	pageviewPropertySums := make(map[string]map[string]int)
 
 	prevTime := input.From.Add(input.From.Sub(input.To))
+	p := getPaging(input)
 
 	readSessions(sdb, prevTime, input.From, input.Filter,
 		func(session *ExtSession) {
@@ -571,6 +748,9 @@ func GetStatistics(collection *Collection, input *CollectionDataInputT) (*Collec
 			sumOfPrevSessionLength += int(session.Duration)
 
 			prevPageviewCountSums[strconv.Itoa(session.PageviewCount)]++
+			if session.VisitorID != 0 {
+				prevVisitorDays.Add(session.VisitorID)
+			}
 		},
 		func(pv *ExtPageview) {
 			prevPageviewTotal++
@@ -594,17 +774,35 @@ func GetStatistics(collection *Collection, input *CollectionDataInputT) (*Collec
 			citySums[session.City]++
 			asNameSums[session.ASName]++
 			referrerSums[session.Referrer]++
+			if session.VisitorID != 0 {
+				visitorDays.Add(session.VisitorID)
+				dailyUniques.Add(session.Begin, session.VisitorID)
+				weeklyVisitorDays.Add(session.Begin, session.VisitorID)
+				monthlyVisitorDays.Add(session.Begin, session.VisitorID)
+			}
+			if visitorType := GetVisitorType(&session.Session); visitorType != "" {
+				visitorTypeSums[visitorType]++
+				visitCountSums[strconv.Itoa(int(session.VisitCount))]++
+				if visitorType == VisitorReturning {
+					daysSinceLastVisitSums[strconv.Itoa(int(session.DaysSinceLastVisit))]++
+				}
+			}
+			addPropertySums(sessionPropertySums, session.Properties)
 		},
 		func(pv *ExtPageview) {
 			pageviewTotal++
 
 			pageSums[pv.Path]++
 			queryStringSums[pv.QueryString]++
+			addPropertySums(pageviewPropertySums, pv.Properties)
 		})
 
 	avgSessionLength := safeDiv(sumOfSessionLength, sessionTotal)
 	prevAvgSessionLength := safeDiv(sumOfPrevSessionLength, prevSessionTotal)
 
+	visitorDayCount := visitorDays.Count()
+	prevVisitorDayCount := prevVisitorDays.Count()
+
 	bounceRate := getPercentByKey(&pageviewCountSums, "1")
 	prevBounceRate := getPercentByKey(&prevPageviewCountSums, "1")
 
@@ -613,24 +811,53 @@ func GetStatistics(collection *Collection, input *CollectionDataInputT) (*Collec
 		PageviewTotal:        totalT{pageviewTotal, getGrowthPercent(pageviewTotal, prevPageviewTotal)},
 		AvgSessionLength:     totalT{avgSessionLength, getGrowthPercent(avgSessionLength, prevAvgSessionLength)},
 		BounceRate:           percentT{bounceRate, getGrowthPercentF(bounceRate, prevBounceRate)},
-		PageSums:             getSums(&pageSums),
-		QueryStringSums:      getSums(&queryStringSums),
-		HostnameSums:         getSums(&hostnameSums),
-		DeviceTypeSums:       getSums(&deviceTypeSums),
-		DeviceOSSums:         getSums(&deviceOSSums),
-		BrowserNameSums:      getSums(&browserNameSums),
-		BrowserVersionSums:   getSums(&browserVersionSums),
-		BrowserLanguageSums:  getSums(&browserLanguageSums),
-		PageviewCountSums:    getSums(&pageviewCountSums),
-		ScreenResolutionSums: getSums(&screenResolutionSums),
-		WindowResolutionSums: getSums(&windowResolutionSums),
-		CountryCodeSums:      getSums(&countryCodeSums),
-		CitySums:             getSums(&citySums),
-		ASNameSums:           getSums(&asNameSums),
-		ReferrerSums:         getSums(&referrerSums),
+		PageSums:             getSums(&pageSums, p),
+		QueryStringSums:      getSums(&queryStringSums, p),
+		HostnameSums:         getSums(&hostnameSums, p),
+		DeviceTypeSums:       getSums(&deviceTypeSums, p),
+		DeviceOSSums:         getSums(&deviceOSSums, p),
+		BrowserNameSums:      getSums(&browserNameSums, p),
+		BrowserVersionSums:   getSums(&browserVersionSums, p),
+		BrowserLanguageSums:  getSums(&browserLanguageSums, p),
+		PageviewCountSums:    getSums(&pageviewCountSums, p),
+		ScreenResolutionSums: getSums(&screenResolutionSums, p),
+		WindowResolutionSums: getSums(&windowResolutionSums, p),
+		CountryCodeSums:      getSums(&countryCodeSums, p),
+		CitySums:             getSums(&citySums, p),
+		ASNameSums:           getSums(&asNameSums, p),
+		ReferrerSums:         getSums(&referrerSums, p),
+
+		VisitorDays:         totalT{visitorDayCount, getGrowthPercent(visitorDayCount, prevVisitorDayCount)},
+		DailyUniqueVisitors: dailyUniques.Close(),
+		WeeklyVisitorDays:   weeklyVisitorDays.Close(),
+		MonthlyVisitorDays:  monthlyVisitorDays.Close(),
+
+		VisitorTypeSums:        getSums(&visitorTypeSums, p),
+		VisitCountSums:         getSums(&visitCountSums, p),
+		DaysSinceLastVisitSums: getSums(&daysSinceLastVisitSums, p),
+
+		SessionPropertySums:  getPropertySums(sessionPropertySums, p),
+		PageviewPropertySums: getPropertySums(pageviewPropertySums, p),
 	}, nil
 }
 
+func addPropertySums(sums map[string]map[string]int, properties []*Property) {
+	for _, p := range properties {
+		if sums[p.Key] == nil {
+			sums[p.Key] = make(map[string]int)
+		}
+		sums[p.Key][p.Value]++
+	}
+}
+
+func getPropertySums(sums map[string]map[string]int, p pagingT) map[string][]sumT {
+	ret := make(map[string][]sumT, len(sums))
+	for key, values := range sums {
+		ret[key] = getSums(&values, p)
+	}
+	return ret
+}
+
 func safeDiv(a, b int) int {
 	if b == 0 {
 		return 0
@@ -686,17 +913,39 @@ func DecodeSessionKey(key string) ([]byte, error) {
 	return base64.StdEncoding.DecodeString(key)
 }
 
-// GetSessions returns the collection's sessions
-func GetSessions(collection *Collection, input *CollectionDataInputT) ([]*SessionDataT, error) {
+// GetSessions returns a page of the collection's sessions in session key order
+// and the count of all the matching sessions
+func GetSessions(collection *Collection, input *CollectionDataInputT) ([]*SessionDataT, int, error) {
 	sdb, err := getShardDB(collection.ID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	var cursor []byte
+	if input.Cursor != "" {
+		cursor, err = DecodeSessionKey(input.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
 	}
 
 	ret := []*SessionDataT{}
+	total := 0
+	skipped := 0
 
 	readSessions(sdb, input.From, input.To, input.Filter,
 		func(session *ExtSession) {
+			total++
+			if cursor != nil && bytes.Compare(session.RawKey, cursor) <= 0 {
+				return
+			}
+			if skipped < input.Offset {
+				skipped++
+				return
+			}
+			if input.Limit > 0 && len(ret) >= input.Limit {
+				return
+			}
 			ret = append(ret, &SessionDataT{
 				Key:              session.Key,
 				Hostname:         session.Hostname,
@@ -721,7 +970,7 @@ func GetSessions(collection *Collection, input *CollectionDataInputT) ([]*Sessio
 			})
 		}, nil)
 
-	return ret, nil
+	return ret, total, nil
 }
 
 // PageviewDataT is the pageview data struct for the clients
@@ -756,3 +1005,38 @@ func GetPageviews(collection *Collection, sessionKey []byte) ([]*PageviewDataT,
 	})
 	return pageviews, nil
 }
+
+// CountMetric returns the count of the sessions started or the pageviews viewed in [from, to).
+// The pageviews of the sessions started before from are counted too, the sessions are scanned
+// from a day or the collection's session timeout before from, whichever is longer.
+func CountMetric(collectionID string, metric string, filter map[string]string, from, to time.Time) (int, error) {
+	collection, err := GetCollection(collectionID)
+	if err != nil {
+		return 0, err
+	}
+	sdb, err := getShardDB(collectionID)
+	if err != nil {
+		return 0, err
+	}
+	lookback := GetSessionTimeout(collection)
+	if lookback < 24*time.Hour {
+		lookback = 24 * time.Hour
+	}
+	count := 0
+	switch metric {
+	case "sessions":
+		readSessions(sdb, from, to, filter,
+			func(session *ExtSession) {
+				count++
+			}, nil)
+	case "pageviews":
+		readSessionsSince(sdb, from.Add(-lookback), from, to, filter,
+			func(session *ExtSession) {},
+			func(pv *ExtPageview) {
+				count++
+			})
+	default:
+		return 0, fmt.Errorf("unknown metric: %v", metric)
+	}
+	return count, nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/soyersoyer/rightana/internal/db/shardbolt"
+)
+
+// The storage backends of the sessions and the pageviews
+const (
+	StorageBolt   = "bolt"
+	StorageMemory = "memory"
+)
+
+// ErrStoreNotSupported is returned by the file based operations on the memory store
+var ErrStoreNotSupported = errors.New("not supported by the memory store")
+
+// ShardStore is a collection's session and pageview store, the keys are sharded by time
+type ShardStore interface {
+	// Get returns the key's value or an error if it doesn't exist
+	Get(bucket []byte, key []byte) ([]byte, error)
+	// Iterate calls fn for the keys between fromKey and toKey in order
+	Iterate(bucket []byte, fromKey []byte, toKey []byte, fn func(k []byte, v []byte))
+	// IteratePrefix calls fn for the keys with the prefix in order
+	IteratePrefix(bucket []byte, prefixKey []byte, fn func(k []byte, v []byte))
+	Begin(writeable bool) ShardTx
+	// Update runs fn in a read-write transaction which is committed if fn succeeds
+	Update(fn func(tx ShardTx) error) error
+	// BatchUpsert puts the value, the concurrent calls may share a transaction
+	BatchUpsert(bucket []byte, key []byte, value []byte) error
+	// BatchUpdate runs fn in a read-write transaction of the key's shard, the concurrent calls may share
+	// a transaction, so fn may be called more than once. The other shards' keys may return
+	// shardbolt.ErrOtherShard, then the caller should retry with Update
+	BatchUpdate(key []byte, fn func(tx ShardTx) error) error
+	DeleteShard(id string) error
+	// DeleteRange deletes the keys between fromKey and toKey and returns their count
+	DeleteRange(bucket []byte, fromKey []byte, toKey []byte) (int, error)
+	// ShardIDs returns the IDs of the shards which may contain keys between fromKey and toKey
+	ShardIDs(fromKey []byte, toKey []byte) []string
+	GetSizes() []shardbolt.ShardSize
+	Close() []error
+}
+
+// ShardTx is a transaction over the shards of a ShardStore
+type ShardTx interface {
+	Put(bucket []byte, key []byte, value []byte) error
+	// Get returns the value of the key, or nil if it doesn't exist
+	Get(bucket []byte, key []byte) ([]byte, error)
+	Delete(bucket []byte, key []byte) error
+	// DeletePrefix deletes the keys with the prefix and returns their count
+	DeletePrefix(bucket []byte, prefix []byte) (int, error)
+	Commit() error
+	Rollback() error
+}
+
+// boltStore is the file based ShardStore, a shard is a bolt file
+type boltStore struct {
+	*shardbolt.DB
+}
+
+func (s boltStore) Begin(writeable bool) ShardTx {
+	return s.DB.Begin(writeable)
+}
+
+func (s boltStore) Update(fn func(tx ShardTx) error) error {
+	return s.DB.Update(func(tx *shardbolt.MultiTx) error {
+		return fn(tx)
+	})
+}
+
+func (s boltStore) BatchUpdate(key []byte, fn func(tx ShardTx) error) error {
+	return s.DB.Batch(key, func(tx *shardbolt.MultiTx) error {
+		return fn(tx)
+	})
+}
+
+// getBoltShardDB returns the collection's shard files, the memory store has none
+func getBoltShardDB(collectionID string) (*shardbolt.DB, error) {
+	sdb, err := getShardDB(collectionID)
+	if err != nil {
+		return nil, err
+	}
+	return boltShards(sdb)
+}
+
+// boltShards returns the shard files of a bolt store
+func boltShards(sdb ShardStore) (*shardbolt.DB, error) {
+	if s, ok := sdb.(boltStore); ok {
+		return s.DB, nil
+	}
+	return nil, ErrStoreNotSupported
+}
@@ -0,0 +1,269 @@
+package db
+
+import (
+	"bytes"
+
+	bolt "github.com/etcd-io/bbolt"
+)
+
+// InsertWebhook inserts a webhook
+func InsertWebhook(webhook *Webhook) error {
+	return cipo.Insert(nil, webhook)
+}
+
+// UpdateWebhook updates a webhook
+func UpdateWebhook(webhook *Webhook) error {
+	return cipo.Update(webhook.ID, webhook)
+}
+
+// GetWebhook returns a webhook with the ID parameter
+func GetWebhook(ID uint64) (*Webhook, error) {
+	webhook := &Webhook{}
+	err := cipo.Get(ID, webhook)
+	return webhook, err
+}
+
+// GetWebhooks returns all the webhooks
+func GetWebhooks() ([]Webhook, error) {
+	webhook := Webhook{}
+	webhooks := []Webhook{}
+	err := cipo.Iterate(&webhook.ID, &webhook, func() error {
+		webhooks = append(webhooks, webhook)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// GetWebhooksByCollectionID returns the collection's webhooks
+func GetWebhooksByCollectionID(collectionID string) ([]Webhook, error) {
+	webhook := Webhook{}
+	webhooks := []Webhook{}
+	err := cipo.Iterate(&webhook.ID, &webhook, func() error {
+		if webhook.CollectionID == collectionID {
+			webhooks = append(webhooks, webhook)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes a webhook with its queued deliveries
+func DeleteWebhook(webhook *Webhook) error {
+	return cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		return deleteWebhookTx(tx, webhook)
+	})
+}
+
+func deleteWebhookTx(tx *bolt.Tx, webhook *Webhook) error {
+	if err := cipo.DeleteTx(tx, webhook.ID, webhook); err != nil {
+		return err
+	}
+	return deleteWebhookDeliveriesTx(tx, webhook.ID, func(*WebhookDelivery) bool { return true })
+}
+
+// deleteWebhookDeliveriesTx deletes the webhook's queued deliveries which match
+func deleteWebhookDeliveriesTx(tx *bolt.Tx, webhookID uint64, match func(delivery *WebhookDelivery) bool) error {
+	prefix := marshaluint64(webhookID)
+	ids := []uint64{}
+	if b := tx.Bucket(BDeliveryByWebhook); b != nil {
+		c := b.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			id, err := unmarshaluint64(k[len(prefix):])
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		delivery := &WebhookDelivery{}
+		if err := cipo.GetTx(tx, id, delivery); err != nil {
+			return err
+		}
+		if !match(delivery) {
+			continue
+		}
+		if err := deleteWebhookDeliveryTx(tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteWebhooksByCollectionIDTx(tx *bolt.Tx, collectionID string) error {
+	ID := uint64(0)
+	v := Webhook{}
+	return cipo.IterateTx(tx, &ID, &v, func() error {
+		if v.CollectionID == collectionID {
+			return deleteWebhookTx(tx, &v)
+		}
+		return nil
+	})
+}
+
+// These are the webhook queue's index buckets, the keys are the webhook ID or
+// the next attempt, followed by the delivery ID
+var (
+	BDeliveryByWebhook     = []byte("WebhookDeliveryByWebhook")
+	BDeliveryByNextAttempt = []byte("WebhookDeliveryByNextAttempt")
+)
+
+func deliveryIndexKeys(delivery *WebhookDelivery) ([]byte, []byte) {
+	id := marshaluint64(delivery.ID)
+	return append(marshaluint64(delivery.WebhookID), id...),
+		append(marshaluint64(uint64(delivery.NextAttempt)), id...)
+}
+
+func indexWebhookDeliveryTx(tx *bolt.Tx, delivery *WebhookDelivery) error {
+	byWebhook, byNextAttempt := deliveryIndexKeys(delivery)
+	b, err := tx.CreateBucketIfNotExists(BDeliveryByWebhook)
+	if err != nil {
+		return err
+	}
+	if err := b.Put(byWebhook, []byte{}); err != nil {
+		return err
+	}
+	if b, err = tx.CreateBucketIfNotExists(BDeliveryByNextAttempt); err != nil {
+		return err
+	}
+	return b.Put(byNextAttempt, []byte{})
+}
+
+func unindexWebhookDeliveryTx(tx *bolt.Tx, delivery *WebhookDelivery) error {
+	byWebhook, byNextAttempt := deliveryIndexKeys(delivery)
+	if b := tx.Bucket(BDeliveryByWebhook); b != nil {
+		if err := b.Delete(byWebhook); err != nil {
+			return err
+		}
+	}
+	if b := tx.Bucket(BDeliveryByNextAttempt); b != nil {
+		return b.Delete(byNextAttempt)
+	}
+	return nil
+}
+
+// indexWebhookDeliveriesTx rebuilds the webhook queue's indexes
+func indexWebhookDeliveriesTx(tx *bolt.Tx) error {
+	for _, b := range [][]byte{BDeliveryByWebhook, BDeliveryByNextAttempt} {
+		if err := tx.DeleteBucket(b); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+	}
+	ID := uint64(0)
+	v := WebhookDelivery{}
+	return cipo.IterateTx(tx, &ID, &v, func() error {
+		return indexWebhookDeliveryTx(tx, &v)
+	})
+}
+
+// countWebhookDeliveriesTx counts the webhook's queued deliveries up to max
+func countWebhookDeliveriesTx(tx *bolt.Tx, webhookID uint64, max int) int {
+	b := tx.Bucket(BDeliveryByWebhook)
+	if b == nil {
+		return 0
+	}
+	prefix := marshaluint64(webhookID)
+	count := 0
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix) && count < max; k, _ = c.Next() {
+		count++
+	}
+	return count
+}
+
+// InsertWebhookDeliveries puts the deliveries into the webhook queue in a transaction, a webhook's
+// queue is capped at max deliveries, the ones above it are dropped, it returns the dropped count
+func InsertWebhookDeliveries(deliveries []*WebhookDelivery, max int) (int, error) {
+	dropped := 0
+	err := cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		dropped = 0
+		queued := map[uint64]int{}
+		for _, d := range deliveries {
+			count, ok := queued[d.WebhookID]
+			if !ok {
+				count = countWebhookDeliveriesTx(tx, d.WebhookID, max)
+			}
+			if count >= max {
+				dropped++
+				continue
+			}
+			queued[d.WebhookID] = count + 1
+			if err := cipo.InsertTx(tx, nil, d); err != nil {
+				return err
+			}
+			if err := indexWebhookDeliveryTx(tx, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return dropped, err
+}
+
+// UpdateWebhookDelivery updates a queued delivery
+func UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	return cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		old := &WebhookDelivery{}
+		if err := cipo.GetTx(tx, delivery.ID, old); err != nil {
+			return err
+		}
+		if err := unindexWebhookDeliveryTx(tx, old); err != nil {
+			return err
+		}
+		if err := cipo.UpdateTx(tx, delivery.ID, delivery); err != nil {
+			return err
+		}
+		return indexWebhookDeliveryTx(tx, delivery)
+	})
+}
+
+// DeleteWebhookDelivery removes a delivery from the webhook queue
+func DeleteWebhookDelivery(delivery *WebhookDelivery) error {
+	return cipo.Bolt().Update(func(tx *bolt.Tx) error {
+		return deleteWebhookDeliveryTx(tx, delivery.ID)
+	})
+}
+
+func deleteWebhookDeliveryTx(tx *bolt.Tx, id uint64) error {
+	delivery := &WebhookDelivery{}
+	if err := cipo.GetTx(tx, id, delivery); err != nil {
+		return err
+	}
+	if err := unindexWebhookDeliveryTx(tx, delivery); err != nil {
+		return err
+	}
+	return cipo.DeleteTx(tx, id, delivery)
+}
+
+// GetDueWebhookDeliveries returns at most limit queued deliveries which should be attempted
+// before the time parameter, the earliest ones first
+func GetDueWebhookDeliveries(before int64, limit int) ([]WebhookDelivery, error) {
+	deliveries := []WebhookDelivery{}
+	err := cipo.Bolt().View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(BDeliveryByNextAttempt)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		end := marshaluint64(uint64(before) + 1)
+		for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0 && len(deliveries) < limit; k, _ = c.Next() {
+			id, err := unmarshaluint64(k[8:])
+			if err != nil {
+				return err
+			}
+			delivery := WebhookDelivery{}
+			if err := cipo.GetTx(tx, id, &delivery); err != nil {
+				return err
+			}
+			deliveries = append(deliveries, delivery)
+		}
+		return nil
+	})
+	return deliveries, err
+}
@@ -64,3 +64,8 @@ func ASNByIP(ipAddr string) *AS {
 	}
 	return &AS{}
 }
+
+// Loaded returns whether the city and the ASN databases are loaded
+func Loaded() (city bool, asn bool) {
+	return cityDB != nil, asnDB != nil
+}
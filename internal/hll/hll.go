@@ -0,0 +1,59 @@
+// Package hll estimates the count of distinct 64 bit hashes with HyperLogLog sketches
+package hll
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	precision = 14
+	registers = 1 << precision
+)
+
+// Sketch is a HyperLogLog sketch, the standard error of its estimate is about 0.8%
+type Sketch struct {
+	registers []uint8
+}
+
+// New returns an empty sketch
+func New() *Sketch {
+	return &Sketch{make([]uint8, registers)}
+}
+
+// Add adds a hash to the sketch, the hashes must be uniformly distributed
+func (s *Sketch) Add(hash uint64) {
+	i := hash >> (64 - precision)
+	rank := uint8(bits.LeadingZeros64(hash<<precision|1<<(precision-1))) + 1
+	if rank > s.registers[i] {
+		s.registers[i] = rank
+	}
+}
+
+// Merge adds the other sketch's hashes to the sketch
+func (s *Sketch) Merge(other *Sketch) {
+	for i, r := range other.registers {
+		if r > s.registers[i] {
+			s.registers[i] = r
+		}
+	}
+}
+
+// Count returns the estimated count of the distinct hashes
+func (s *Sketch) Count() int {
+	sum := 0.0
+	zeros := 0
+	for _, r := range s.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	m := float64(registers)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// linear counting is more accurate for the small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(estimate + 0.5)
+}
@@ -0,0 +1,44 @@
+package hll
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func testCount(t *testing.T, s *Sketch, expected int) {
+	count := s.Count()
+	if diff := float64(count-expected) / float64(expected); diff > 0.03 || diff < -0.03 {
+		t.Error(count, expected)
+	}
+}
+
+func TestCount(t *testing.T) {
+	if New().Count() != 0 {
+		t.Error("empty sketch")
+	}
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{10, 1000, 100000} {
+		s := New()
+		for i := 0; i < n; i++ {
+			h := r.Uint64()
+			s.Add(h)
+			s.Add(h)
+		}
+		testCount(t, s, n)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	a, b := New(), New()
+	for i := 0; i < 20000; i++ {
+		h := r.Uint64()
+		a.Add(h)
+		if i%2 == 0 {
+			b.Add(h)
+		}
+		b.Add(r.Uint64())
+	}
+	a.Merge(b)
+	testCount(t, a, 40000)
+}
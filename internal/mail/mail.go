@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"github.com/go-mail/mail"
+
+	"github.com/soyersoyer/rightana/internal/metrics"
 )
 
 // SMTPConfig holds the configuration
@@ -19,6 +21,9 @@ type SMTPConfig struct {
 
 var (
 	config SMTPConfig
+
+	sendFailures = metrics.NewCounter("rightana_mail_send_failures_total",
+		"The number of the failed email sendings.")
 )
 
 // Configure sets the config variables
@@ -44,8 +49,30 @@ func SendVerifyEmail(userName, recipient, displayName, verificationKey string) e
 	return SendUserEmail(recipient, userName, "Verify your email address", body)
 }
 
-// SendUserEmail sends a html email to an user
-func SendUserEmail(recipient, name, subject, htmlBody string) error {
+// SendAlert sends an alert notification about a collection
+func SendAlert(recipient, displayName, ownerName, collectionName, alertName, message string) error {
+	body, err := getAlertBody(displayName, ownerName, collectionName, alertName, message)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("Alert: %s on %s", alertName, collectionName)
+	return SendUserEmail(recipient, displayName, subject, body)
+}
+
+// SendDigest sends a periodic report about the collections
+func SendDigest(recipient, displayName, frequency, from, to string, collections []DigestCollection) error {
+	body, err := getDigestBody(displayName, frequency, from, to, collections)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("Your %s %s report", config.AppName, frequency)
+	return SendUserEmail(recipient, displayName, subject, body)
+}
+
+// SendUserEmail sends a html email to an user, the tests replace it for capturing the emails
+var SendUserEmail = sendUserEmail
+
+func sendUserEmail(recipient, name, subject, htmlBody string) error {
 	m := mail.NewMessage()
 	m.SetHeader("From", config.Sender)
 	m.SetHeader("To", fmt.Sprintf("%s <%s>", name, recipient))
@@ -58,5 +85,9 @@ func SendUserEmail(recipient, name, subject, htmlBody string) error {
 		config.Password)
 	d.StartTLSPolicy = mail.MandatoryStartTLS
 
-	return d.DialAndSend(m)
+	if err := d.DialAndSend(m); err != nil {
+		sendFailures.Inc()
+		return err
+	}
+	return nil
 }
@@ -2,14 +2,17 @@ package mail
 
 import (
 	"bytes"
+	"fmt"
+	"html/template"
 	"strconv"
-	"text/template"
 	"time"
 )
 
 var (
 	verifyEmail   *template.Template
 	resetPassword *template.Template
+	alertEmail    *template.Template
+	digestEmail   *template.Template
 )
 
 func init() {
@@ -49,6 +52,38 @@ func init() {
 	template.Must(resetPassword.New("header").Parse(header))
 	template.Must(resetPassword.New("resetPasswordLink").Parse(resetPasswordLink))
 	template.Must(resetPassword.New("footer").Parse(footer))
+
+	alertEmail = template.Must(template.New("alertEmail").Parse(`
+		{{template "header" .}}
+		<p>The <b>{{.AlertName}}</b> alert fired on the <b>{{.CollectionName}}</b> collection:</p>
+		<p>{{.Message}}</p>
+		<p><a href="{{template "collectionLink" .}}">{{template "collectionLink" .}}</a></p>
+		{{template "footer" .}}
+	`))
+	collectionLink := `{{.AppURL}}/{{.OwnerName}}/{{.CollectionName}}`
+	template.Must(alertEmail.New("header").Parse(header))
+	template.Must(alertEmail.New("collectionLink").Parse(collectionLink))
+	template.Must(alertEmail.New("footer").Parse(footer))
+
+	digestEmail = template.Must(template.New("digestEmail").Funcs(template.FuncMap{
+		"percent": formatPercent,
+	}).Parse(`
+		{{template "header" .}}
+		<p>Here is your {{.Frequency}} report from {{.From}} to {{.To}}:</p>
+		{{range .Collections}}
+		<h3><a href="{{$.AppURL}}/{{.OwnerName}}/{{.Name}}">{{.Name}}</a></h3>
+		<p>Sessions: <b>{{.SessionCount}}</b> ({{percent .SessionPercent}})<br/>
+		Pageviews: <b>{{.PageviewCount}}</b> ({{percent .PageviewPercent}})</p>
+		{{if .TopPages}}<p>Top pages:</p>
+		<table>{{range .TopPages}}<tr><td>{{.Name}}</td><td>{{.Count}}</td></tr>{{end}}</table>{{end}}
+		{{if .TopReferrers}}<p>Top referrers:</p>
+		<table>{{range .TopReferrers}}<tr><td>{{.Name}}</td><td>{{.Count}}</td></tr>{{end}}</table>{{end}}
+		{{end}}
+		<p>You can unsubscribe in your <a href="{{.AppURL}}/settings/profile">settings</a>.</p>
+		{{template "footer" .}}
+	`))
+	template.Must(digestEmail.New("header").Parse(header))
+	template.Must(digestEmail.New("footer").Parse(footer))
 }
 
 func getResetPasswordBody(userName, displayName, resetKey string, expireMinutes int) (string, error) {
@@ -90,6 +125,70 @@ func getVerifyEmailBody(userName, displayName, verificationKey string) (string,
 	return body.String(), nil
 }
 
+func getAlertBody(displayName, ownerName, collectionName, alertName, message string) (string, error) {
+	type params struct {
+		DisplayName    string
+		OwnerName      string
+		CollectionName string
+		AlertName      string
+		Message        string
+		AppURL         string
+		AppName        string
+		YearStr        string
+	}
+	body := &bytes.Buffer{}
+	err := alertEmail.Execute(body, &params{
+		displayName, ownerName, collectionName, alertName, message,
+		config.AppURL, config.AppName, getYearStr()})
+	if err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
+
+// DigestRow is a name-count pair in the digest report
+type DigestRow struct {
+	Name  string
+	Count int
+}
+
+// DigestCollection contains a collection's numbers for the digest report
+type DigestCollection struct {
+	Name            string
+	OwnerName       string
+	SessionCount    int
+	SessionPercent  float64
+	PageviewCount   int
+	PageviewPercent float64
+	TopPages        []DigestRow
+	TopReferrers    []DigestRow
+}
+
+func getDigestBody(displayName, frequency, from, to string, collections []DigestCollection) (string, error) {
+	type params struct {
+		DisplayName string
+		Frequency   string
+		From        string
+		To          string
+		Collections []DigestCollection
+		AppURL      string
+		AppName     string
+		YearStr     string
+	}
+	body := &bytes.Buffer{}
+	err := digestEmail.Execute(body, &params{
+		displayName, frequency, from, to, collections,
+		config.AppURL, config.AppName, getYearStr()})
+	if err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
+
+func formatPercent(p float64) string {
+	return fmt.Sprintf("%+.1f%%", p*100)
+}
+
 func getYearStr() string {
 	return strconv.Itoa(time.Now().Year())
 }
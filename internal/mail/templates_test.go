@@ -47,3 +47,44 @@ func TestVerifyEmail(t *testing.T) {
 		t.Error(verificationKey)
 	}
 }
+
+func TestAlert(t *testing.T) {
+	display := "display"
+	owner := "owner"
+	collection := "collection.org"
+	alert := "alertname"
+	message := "sessions dropped"
+	tmpl, err := getAlertBody(display, owner, collection, alert, message)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, s := range []string{display, owner, collection, alert, message} {
+		if strings.Index(tmpl, s) == -1 {
+			t.Error(s)
+		}
+	}
+}
+
+func TestDigest(t *testing.T) {
+	display := "display"
+	collections := []DigestCollection{{
+		Name:           "collection.org",
+		OwnerName:      "owner",
+		SessionCount:   1234,
+		SessionPercent: 0.25,
+		TopPages:       []DigestRow{{"/checkout", 42}, {"/<script>alert(1)</script>", 3}},
+		TopReferrers:   []DigestRow{{"https://irl.hu", 7}},
+	}}
+	tmpl, err := getDigestBody(display, "weekly", "2019-06-03", "2019-06-10", collections)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, s := range []string{display, "weekly", "collection.org", "owner", "1234", "&#43;25.0%", "/checkout", "https://irl.hu", "&lt;script&gt;"} {
+		if strings.Index(tmpl, s) == -1 {
+			t.Error(s)
+		}
+	}
+	if strings.Index(tmpl, "<script>") != -1 {
+		t.Error("the tracked names aren't escaped")
+	}
+}
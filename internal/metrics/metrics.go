@@ -0,0 +1,315 @@
+// Package metrics collects the server's metrics and writes them in the Prometheus text format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets are the default histogram buckets in seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metric interface {
+	write(w io.Writer) error
+}
+
+var (
+	registryMutex sync.Mutex
+	registry      = map[string]metric{}
+)
+
+func register(name string, m metric) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Errorf("metrics: %v already registered", name))
+	}
+	registry[name] = m
+}
+
+// Write writes all the registered metrics in the Prometheus text format
+func Write(w io.Writer) error {
+	registryMutex.Lock()
+	names := []string{}
+	for k := range registry {
+		names = append(names, k)
+	}
+	registryMutex.Unlock()
+	sort.Strings(names)
+	for _, name := range names {
+		registryMutex.Lock()
+		m := registry[name]
+		registryMutex.Unlock()
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) writeHeader(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, d.typ)
+	return err
+}
+
+func (d *desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Errorf("metrics: %v needs %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+}
+
+func formatLabels(names []string, values []string, extra ...string) string {
+	pairs := []string{}
+	for i, n := range names {
+		pairs = append(pairs, n+"=\""+escapeLabel(values[i])+"\"")
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"=\""+escapeLabel(extra[i+1])+"\"")
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, "\n", `\n`, -1)
+	return strings.Replace(v, `"`, `\"`, -1)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// Counter is a monotonically increasing metric partitioned by labels
+type Counter struct {
+	desc
+	mutex  sync.Mutex
+	values map[string]float64
+	labelv map[string][]string
+}
+
+// NewCounter creates and registers a counter
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc:   desc{name, help, "counter", labels},
+		values: map[string]float64{},
+		labelv: map[string][]string{},
+	}
+	register(name, c)
+	return c
+}
+
+// Inc increments the counter with the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter with the label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.checkLabels(labelValues)
+	key := labelKey(labelValues)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.labelv[key]; !ok {
+		c.labelv[key] = append([]string{}, labelValues...)
+	}
+	c.values[key] += v
+}
+
+// Value returns the counter's value with the label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.values[labelKey(labelValues)]
+}
+
+func (c *Counter) write(w io.Writer) error {
+	if err := c.writeHeader(w); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, key := range sortedKeys(c.labelv) {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, c.labelv[key]), formatFloat(c.values[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Histogram counts the observations in buckets partitioned by labels
+type Histogram struct {
+	desc
+	buckets []float64
+	mutex   sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// NewHistogram creates and registers a histogram, nil buckets means DefBuckets
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	h := &Histogram{
+		desc:    desc{name, help, "histogram", labels},
+		buckets: buckets,
+		values:  map[string]*histogramValue{},
+	}
+	register(name, h)
+	return h
+}
+
+// Observe adds an observation to the histogram with the label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.checkLabels(labelValues)
+	key := labelKey(labelValues)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{
+			labels: append([]string{}, labelValues...),
+			counts: make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = hv
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			hv.counts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += v
+}
+
+// ObserveSince adds the elapsed seconds since the start time
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Count returns the number of the observations with the label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	hv, ok := h.values[labelKey(labelValues)]
+	if !ok {
+		return 0
+	}
+	return hv.count
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	if err := h.writeHeader(w); err != nil {
+		return err
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	keys := []string{}
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		hv := h.values[key]
+		for i, b := range h.buckets {
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, hv.labels, "le", formatFloat(b)), hv.counts[i]); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, hv.labels, "le", "+Inf"), hv.count); err != nil {
+			return err
+		}
+		labels := formatLabels(h.labels, hv.labels)
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", h.name, labels, formatFloat(hv.sum), h.name, labels, hv.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sample is a gauge value with its label values
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc is a gauge which values are computed at the scrape time
+type GaugeFunc struct {
+	desc
+	fn func() []Sample
+}
+
+// NewGaugeFunc creates and registers a gauge which calls fn at every scrape
+func NewGaugeFunc(name, help string, fn func() []Sample, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{desc{name, help, "gauge", labels}, fn}
+	register(name, g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) error {
+	if err := g.writeHeader(w); err != nil {
+		return err
+	}
+	for _, s := range g.fn() {
+		g.checkLabels(s.LabelValues)
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labels, s.LabelValues), formatFloat(s.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CounterFunc is a counter which values are computed at the scrape time
+type CounterFunc struct {
+	GaugeFunc
+}
+
+// NewCounterFunc creates and registers a counter which calls fn at every scrape
+func NewCounterFunc(name, help string, fn func() []Sample, labels ...string) *CounterFunc {
+	c := &CounterFunc{GaugeFunc{desc{name, help, "counter", labels}, fn}}
+	register(name, c)
+	return c
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
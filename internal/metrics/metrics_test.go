@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	c := NewCounter("test_requests_total", "The test requests.", "code")
+	c.Inc("200")
+	c.Add(2, "500")
+	h := NewHistogram("test_duration_seconds", "The test durations.", []float64{1, 5})
+	h.Observe(0.5)
+	h.Observe(3)
+	NewGaugeFunc("test_open", "The open things.", func() []Sample {
+		return []Sample{{[]string{`a"b`}, 2}}
+	}, "name")
+	NewCounterFunc("test_hits_total", "The test hits.", func() []Sample {
+		return []Sample{{Value: 7}}
+	})
+
+	b := &bytes.Buffer{}
+	if err := Write(b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP test_duration_seconds The test durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="1"} 1
+test_duration_seconds_bucket{le="5"} 2
+test_duration_seconds_bucket{le="+Inf"} 2
+test_duration_seconds_sum 3.5
+test_duration_seconds_count 2
+# HELP test_hits_total The test hits.
+# TYPE test_hits_total counter
+test_hits_total 7
+# HELP test_open The open things.
+# TYPE test_open gauge
+test_open{name="a\"b"} 2
+# HELP test_requests_total The test requests.
+# TYPE test_requests_total counter
+test_requests_total{code="200"} 1
+test_requests_total{code="500"} 2
+`
+	if b.String() != expected {
+		t.Error(b.String())
+	}
+}
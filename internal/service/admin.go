@@ -1,6 +1,8 @@
 package service
 
 import (
+	"strconv"
+
 	"github.com/soyersoyer/rightana/internal/db"
 )
 
@@ -166,7 +168,7 @@ func GetCollections() ([]CollectionInfoT, error) {
 	for _, c := range collections {
 		user, err := db.GetUserByID(c.OwnerID)
 		if err != nil {
-			return nil, ErrDB.T(string(c.OwnerID)).Wrap(err)
+			return nil, ErrDB.T(strconv.FormatUint(c.OwnerID, 10)).Wrap(err)
 		}
 		collectionInfos = append(collectionInfos, CollectionInfoT{
 			c.ID,
@@ -177,3 +179,128 @@ func GetCollections() ([]CollectionInfoT, error) {
 	}
 	return collectionInfos, nil
 }
+
+// CollectionDetailsT contains the collection's owner, teammates and shards
+type CollectionDetailsT struct {
+	CollectionInfoT
+	Teammates []TeammateT     `json:"teammates"`
+	Shards    []db.ShardDataT `json:"shards"`
+}
+
+// GetCollectionDetails returns all collection with the teammates and the shard sizes
+func GetCollectionDetails() ([]CollectionDetailsT, error) {
+	infos, err := GetCollections()
+	if err != nil {
+		return nil, err
+	}
+	ret := []CollectionDetailsT{}
+	for _, info := range infos {
+		collection, err := GetCollection(info.ID)
+		if err != nil {
+			return nil, err
+		}
+		teammates, err := GetCollectionTeammates(collection)
+		if err != nil {
+			return nil, err
+		}
+		shards, err := GetCollectionShards(collection)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, CollectionDetailsT{info, teammates, shards})
+	}
+	return ret, nil
+}
+
+// SetAdmin promotes or demotes the user
+func SetAdmin(name string, isAdmin bool) error {
+	user, err := GetUserByName(name)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		if err := lastAdminCheck(user); err != nil {
+			return err
+		}
+	}
+	user.IsAdmin = isAdmin
+	if err := db.UpdateUser(user); err != nil {
+		return ErrDB.Wrap(err, user)
+	}
+	return nil
+}
+
+// SetCollectionLimit sets the user's collection limit, a negative limit removes it
+func SetCollectionLimit(name string, limit int) error {
+	user, err := GetUserByName(name)
+	if err != nil {
+		return err
+	}
+	user.LimitCollections = limit >= 0
+	user.CollectionLimit = 0
+	if limit >= 0 {
+		user.CollectionLimit = uint32(limit)
+	}
+	if err := db.UpdateUser(user); err != nil {
+		return ErrDB.Wrap(err, user)
+	}
+	return nil
+}
+
+// TransferCollection changes the collection's owner within the new owner's collection limit,
+// the previous owner is kept as a teammate unless keepPreviousOwner is false
+func TransferCollection(collection *Collection, ownerName string, keepPreviousOwner bool) error {
+	owner, err := GetUserByName(ownerName)
+	if err != nil {
+		return err
+	}
+	if collection.OwnerID == owner.ID {
+		return nil
+	}
+	if err := checkCollectionLimit(owner, collection.ID); err != nil {
+		return err
+	}
+	previousOwnerID := collection.OwnerID
+	collection.OwnerID = owner.ID
+	if err := validateCollection(collection); err != nil {
+		collection.OwnerID = previousOwnerID
+		return err
+	}
+	teammates := []*db.Teammate{}
+	for _, t := range collection.Teammates {
+		if t.ID != owner.ID {
+			teammates = append(teammates, t)
+		}
+	}
+	if keepPreviousOwner {
+		teammates = append(teammates, &db.Teammate{ID: previousOwnerID})
+	}
+	collection.Teammates = teammates
+	if err := db.UpdateCollection(collection); err != nil {
+		return ErrDB.Wrap(err, collection)
+	}
+	return nil
+}
+
+// RebuildIndexes rebuilds the user and collection indexes
+func RebuildIndexes() error {
+	if err := db.RebuildIndexes(); err != nil {
+		return ErrDB.Wrap(err)
+	}
+	return nil
+}
+
+// ReshardReportT is the reshard's report
+type ReshardReportT = db.ReshardReportT
+
+// ReshardCollection rewrites the collection's shards in a new granularity
+func ReshardCollection(collection *Collection, granularity string) (*ReshardReportT, error) {
+	if !db.ValidShardGranularity(granularity) {
+		return nil, ErrInvalidShardGranularity.T(granularity)
+	}
+	report, err := db.ReshardCollection(collection, granularity)
+	if err != nil {
+		return nil, ErrDB.Wrap(err, collection.ID, granularity)
+	}
+	return report, nil
+}
@@ -0,0 +1,423 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soyersoyer/rightana/internal/db"
+	"github.com/soyersoyer/rightana/internal/mail"
+)
+
+// AlertT is the alert rule struct for the clients
+type AlertT struct {
+	ID              uint64  `json:"id"`
+	Name            string  `json:"name"`
+	Metric          string  `json:"metric"`
+	Path            string  `json:"path"`
+	WindowMinutes   int32   `json:"window_minutes"`
+	Condition       string  `json:"condition"`
+	Threshold       float64 `json:"threshold"`
+	CooldownMinutes int32   `json:"cooldown_minutes"`
+	Email           string  `json:"email"`
+	WebhookURL      string  `json:"webhook_url"`
+	Disabled        bool    `json:"disabled"`
+	LastFired       int64   `json:"last_fired"`
+}
+
+// AlertEventT is the alert history struct for the clients
+type AlertEventT struct {
+	ID        uint64  `json:"id"`
+	Fired     int64   `json:"fired"`
+	Value     float64 `json:"value"`
+	Reference float64 `json:"reference"`
+	Message   string  `json:"message"`
+	Error     string  `json:"error"`
+}
+
+// The alert conditions
+const (
+	AlertBelow         = "below"
+	AlertAbove         = "above"
+	AlertBelowLastWeek = "below_last_week"
+)
+
+const defaultAlertCooldownMinutes = 60
+
+func toAlertT(a *db.Alert) AlertT {
+	return AlertT{
+		a.ID,
+		a.Name,
+		a.Metric,
+		a.Path,
+		a.WindowMinutes,
+		a.Condition,
+		a.Threshold,
+		a.CooldownMinutes,
+		a.Email,
+		a.WebhookURL,
+		a.Disabled,
+		a.LastFired,
+	}
+}
+
+func setAlert(a *db.Alert, input *AlertT) {
+	a.Name = input.Name
+	a.Metric = input.Metric
+	a.Path = input.Path
+	a.WindowMinutes = input.WindowMinutes
+	a.Condition = input.Condition
+	a.Threshold = input.Threshold
+	a.CooldownMinutes = input.CooldownMinutes
+	if a.CooldownMinutes == 0 {
+		a.CooldownMinutes = defaultAlertCooldownMinutes
+	}
+	a.Email = input.Email
+	a.WebhookURL = input.WebhookURL
+	a.Disabled = input.Disabled
+}
+
+func validateAlert(collection *Collection, a *db.Alert) error {
+	if a.Name == "" {
+		return ErrInvalidAlert.T("name")
+	}
+	if a.Metric != "sessions" && a.Metric != "pageviews" {
+		return ErrInvalidAlert.T(a.Metric)
+	}
+	if a.Condition != AlertBelow && a.Condition != AlertAbove && a.Condition != AlertBelowLastWeek {
+		return ErrInvalidAlert.T(a.Condition)
+	}
+	if a.WindowMinutes <= 0 {
+		return ErrInvalidAlert.T(strconv.Itoa(int(a.WindowMinutes)))
+	}
+	if a.Threshold < 0 {
+		return ErrInvalidAlert.T(strconv.FormatFloat(a.Threshold, 'f', -1, 64))
+	}
+	if a.CooldownMinutes < 0 {
+		return ErrInvalidAlert.T(strconv.Itoa(int(a.CooldownMinutes)))
+	}
+	if a.Email == "" && a.WebhookURL == "" {
+		return ErrInvalidAlert.T("no email or webhook")
+	}
+	emails := splitEmails(a.Email)
+	for _, e := range emails {
+		if !emailCheck(e) {
+			return ErrInvalidEmail.T(e)
+		}
+	}
+	if len(emails) > 0 {
+		members, err := collectionEmails(collection)
+		if err != nil {
+			return err
+		}
+		for _, e := range emails {
+			if !members[strings.ToLower(e)] {
+				return ErrForbiddenTarget.T(e)
+			}
+		}
+	}
+	if a.WebhookURL != "" {
+		u, err := url.Parse(a.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidAlert.T(a.WebhookURL)
+		}
+		if privateTarget(u) {
+			return ErrForbiddenTarget.T(a.WebhookURL)
+		}
+	}
+	return nil
+}
+
+func splitEmails(emails string) []string {
+	ret := []string{}
+	for _, e := range strings.Split(emails, ",") {
+		e = strings.TrimSpace(e)
+		if e != "" {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// GetAlerts returns the collection's alert rules
+func GetAlerts(collection *Collection) ([]AlertT, error) {
+	alerts, err := db.GetAlertsByCollectionID(collection.ID)
+	if err != nil {
+		return nil, ErrDB.Wrap(err, collection.ID)
+	}
+	ret := []AlertT{}
+	for _, a := range alerts {
+		ret = append(ret, toAlertT(&a))
+	}
+	return ret, nil
+}
+
+// CreateAlert creates an alert rule for the collection
+func CreateAlert(collection *Collection, input *AlertT) (*AlertT, error) {
+	alert := &db.Alert{
+		CollectionID: collection.ID,
+		Created:      time.Now().UnixNano(),
+	}
+	setAlert(alert, input)
+	if err := validateAlert(collection, alert); err != nil {
+		return nil, err
+	}
+	if err := db.InsertAlert(alert); err != nil {
+		return nil, ErrDB.Wrap(err, alert)
+	}
+	ret := toAlertT(alert)
+	return &ret, nil
+}
+
+func getAlert(collection *Collection, alertID string) (*db.Alert, error) {
+	ID, err := strconv.ParseUint(alertID, 10, 64)
+	if err != nil {
+		return nil, ErrAlertNotExist.T(alertID).Wrap(err)
+	}
+	alert, err := db.GetAlert(ID)
+	if err != nil {
+		if err == db.ErrKeyNotExists {
+			return nil, ErrAlertNotExist.T(alertID)
+		}
+		return nil, ErrDB.Wrap(err, alertID)
+	}
+	if alert.CollectionID != collection.ID {
+		return nil, ErrAlertNotExist.T(alertID)
+	}
+	return alert, nil
+}
+
+// UpdateAlert updates the collection's alert rule
+func UpdateAlert(collection *Collection, alertID string, input *AlertT) (*AlertT, error) {
+	alert, err := getAlert(collection, alertID)
+	if err != nil {
+		return nil, err
+	}
+	setAlert(alert, input)
+	if err := validateAlert(collection, alert); err != nil {
+		return nil, err
+	}
+	if err := db.UpdateAlert(alert); err != nil {
+		return nil, ErrDB.Wrap(err, alert)
+	}
+	ret := toAlertT(alert)
+	return &ret, nil
+}
+
+// DeleteAlert deletes the collection's alert rule
+func DeleteAlert(collection *Collection, alertID string) error {
+	alert, err := getAlert(collection, alertID)
+	if err != nil {
+		return err
+	}
+	if err := db.DeleteAlert(alert); err != nil {
+		return ErrDB.Wrap(err, alert)
+	}
+	return nil
+}
+
+// GetAlertEvents returns the alert's history
+func GetAlertEvents(collection *Collection, alertID string) ([]AlertEventT, error) {
+	alert, err := getAlert(collection, alertID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := db.GetAlertEvents(alert.ID)
+	if err != nil {
+		return nil, ErrDB.Wrap(err, alert.ID)
+	}
+	ret := []AlertEventT{}
+	for _, e := range events {
+		ret = append(ret, AlertEventT{
+			e.ID,
+			e.Fired,
+			e.Value,
+			e.Reference,
+			e.Message,
+			e.Error,
+		})
+	}
+	return ret, nil
+}
+
+// StartAlertScheduler checks the alert rules periodically in the background
+func StartAlertScheduler(interval time.Duration) {
+	startScheduler("alert check", interval, CheckAlerts)
+}
+
+// CheckAlerts evaluates every enabled alert rule and sends the notifications
+func CheckAlerts(now time.Time) error {
+	alerts, err := db.GetAlerts()
+	if err != nil {
+		return ErrDB.Wrap(err)
+	}
+	for _, a := range alerts {
+		if err := checkAlert(&a, now); err != nil {
+			log.Println("can't check alert", a.ID, "cause:", err)
+		}
+	}
+	return nil
+}
+
+func checkAlert(alert *db.Alert, now time.Time) error {
+	if alert.Disabled {
+		return nil
+	}
+	cooldown := time.Duration(alert.CooldownMinutes) * time.Minute
+	if alert.LastFired != 0 && now.Before(time.Unix(0, alert.LastFired).Add(cooldown)) {
+		return nil
+	}
+
+	filter := map[string]string{}
+	if alert.Path != "" {
+		filter["page"] = alert.Path
+	}
+	window := time.Duration(alert.WindowMinutes) * time.Minute
+	count, err := db.CountMetric(alert.CollectionID, alert.Metric, filter, now.Add(-window), now)
+	if err != nil {
+		return err
+	}
+	value := float64(count)
+	reference := alert.Threshold
+	what := alert.Metric
+	if alert.Path != "" {
+		what += " for " + alert.Path
+	}
+	message := ""
+
+	switch alert.Condition {
+	case AlertBelow:
+		if value >= alert.Threshold {
+			return nil
+		}
+		message = fmt.Sprintf("%s in the last %d minutes: %v, below %v", what, alert.WindowMinutes, value, alert.Threshold)
+	case AlertAbove:
+		if value <= alert.Threshold {
+			return nil
+		}
+		message = fmt.Sprintf("%s in the last %d minutes: %v, above %v", what, alert.WindowMinutes, value, alert.Threshold)
+	case AlertBelowLastWeek:
+		weekAgo := now.AddDate(0, 0, -7)
+		prevCount, err := db.CountMetric(alert.CollectionID, alert.Metric, filter, weekAgo.Add(-window), weekAgo)
+		if err != nil {
+			return err
+		}
+		reference = float64(prevCount)
+		if reference == 0 || value >= alert.Threshold*reference {
+			return nil
+		}
+		message = fmt.Sprintf("%s in the last %d minutes: %v, the same period last week: %v, below %v%%",
+			what, alert.WindowMinutes, value, reference, alert.Threshold*100)
+	default:
+		return ErrInvalidAlert.T(alert.Condition)
+	}
+
+	event := &db.AlertEvent{
+		AlertID:      alert.ID,
+		CollectionID: alert.CollectionID,
+		Fired:        now.UnixNano(),
+		Value:        value,
+		Reference:    reference,
+		Message:      message,
+	}
+	if errs := sendAlert(alert, event); len(errs) > 0 {
+		msgs := []string{}
+		for _, e := range errs {
+			log.Println("can't send alert", alert.ID, "cause:", e)
+			msgs = append(msgs, e.Error())
+		}
+		event.Error = strings.Join(msgs, "; ")
+	}
+	if err := db.InsertAlertEvent(event); err != nil {
+		return err
+	}
+	alert.LastFired = event.Fired
+	stored := &db.Alert{}
+	return db.SetFields(alert.ID, stored, func() { stored.LastFired = event.Fired })
+}
+
+type alertWebhookT struct {
+	AlertID        uint64  `json:"alert_id"`
+	AlertName      string  `json:"alert_name"`
+	CollectionID   string  `json:"collection_id"`
+	CollectionName string  `json:"collection_name"`
+	Fired          int64   `json:"fired"`
+	Value          float64 `json:"value"`
+	Reference      float64 `json:"reference"`
+	Message        string  `json:"message"`
+}
+
+func sendAlert(alert *db.Alert, event *db.AlertEvent) []error {
+	collection, err := db.GetCollection(alert.CollectionID)
+	if err != nil {
+		return []error{err}
+	}
+	owner, err := db.GetUserByID(collection.OwnerID)
+	if err != nil {
+		return []error{err}
+	}
+	errs := []error{}
+	for _, e := range splitEmails(alert.Email) {
+		if err := mail.SendAlert(e, e, owner.Name, collection.Name, alert.Name, event.Message); err != nil {
+			errs = append(errs, ErrEmailSending.T(e).Wrap(err))
+		}
+	}
+	if alert.WebhookURL != "" {
+		payload := alertWebhookT{
+			alert.ID,
+			alert.Name,
+			collection.ID,
+			collection.Name,
+			event.Fired,
+			event.Value,
+			event.Reference,
+			event.Message,
+		}
+		if err := postJSON(alert.WebhookURL, payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{Timeout: 10 * time.Second, Control: checkTargetAddress}).DialContext,
+	},
+}
+
+func postJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return postBody(url, body, nil)
+}
+
+func postBody(url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("content-type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %v returned %v", url, resp.Status)
+	}
+	return nil
+}
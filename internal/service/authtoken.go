@@ -21,9 +21,11 @@ func CreateAuthToken(nameOrEmail string, password string) (string, *User, error)
 		user, err = db.GetUserByName(nameOrEmail)
 	}
 	if err != nil || user == nil {
+		authFailures.Inc("user")
 		return "", nil, ErrUserNotExist.T(nameOrEmail)
 	}
 	if err := compareHashAndPassword(user.Password, password); err != nil {
+		authFailures.Inc("password")
 		return "", nil, ErrPasswordNotMatch
 	}
 	token := db.AuthToken{
@@ -51,11 +53,13 @@ func DeleteAuthToken(tokenID string) error {
 func CheckAuthToken(tokenID string) (uint64, error) {
 	token, err := getAuthToken(tokenID)
 	if err != nil {
+		authFailures.Inc("token")
 		return 0, ErrAuthtokenExpired
 	}
 
 	expiryTime := time.Unix(0, token.Created).Add(time.Duration(token.TTL) * time.Second)
 	if expiryTime.Before(time.Now()) {
+		authFailures.Inc("token")
 		DeleteAuthToken(tokenID)
 		return 0, ErrAuthtokenExpired
 	}
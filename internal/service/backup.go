@@ -1,33 +1,388 @@
 package service
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/soyersoyer/rightana/internal/config"
 	"github.com/soyersoyer/rightana/internal/db"
 )
 
 // Backup stores the backup's properties
 type Backup struct {
-	ID  string `json:"id"`
-	Dir string `json:"dir"`
+	ID         string      `json:"id"`
+	Dir        string      `json:"dir"`
+	Interval   string      `json:"interval"`
+	At         string      `json:"at"`
+	Archive    bool        `json:"archive"`
+	KeepLast   int         `json:"keep_last"`
+	KeepDaily  int         `json:"keep_daily"`
+	KeepWeekly int         `json:"keep_weekly"`
+	Running    bool        `json:"running"`
+	NextRun    int64       `json:"next_run"`
+	LastRun    *BackupRunT `json:"last_run"`
+}
+
+// BackupRunT is the backup history struct for the clients
+type BackupRunT struct {
+	ID       uint64 `json:"id"`
+	Started  int64  `json:"started"`
+	Finished int64  `json:"finished"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Error    string `json:"error"`
+}
+
+const (
+	backupTimeFormat   = "20060102-150405.000"
+	backupArchiveExt   = ".tar.gz"
+	backupHistoryLimit = 100
+)
+
+var (
+	backupMutex          sync.Mutex
+	runningBackups       = map[string]bool{}
+	backupSchedulerStart = time.Now()
+)
+
+func toBackupRunT(r *db.BackupRun) *BackupRunT {
+	return &BackupRunT{
+		r.ID,
+		r.Started,
+		r.Finished,
+		r.Path,
+		r.Size,
+		r.Error,
+	}
+}
+
+// backupTimestamped returns whether the backup goes into timestamped dirs or archives
+func backupTimestamped(b config.BackupConfig) bool {
+	return b.Archive || b.Interval != "" || b.At != "" || b.KeepLast > 0 || b.KeepDaily > 0 || b.KeepWeekly > 0
+}
+
+func lockBackup(backupID string) bool {
+	backupMutex.Lock()
+	defer backupMutex.Unlock()
+	if runningBackups[backupID] {
+		return false
+	}
+	runningBackups[backupID] = true
+	return true
+}
+
+func unlockBackup(backupID string) {
+	backupMutex.Lock()
+	defer backupMutex.Unlock()
+	delete(runningBackups, backupID)
+}
+
+func isBackupRunning(backupID string) bool {
+	backupMutex.Lock()
+	defer backupMutex.Unlock()
+	return runningBackups[backupID]
 }
 
 // RunBackup runs the backup
 func RunBackup(backupID string) error {
-	dir, ok := config.ActualConfig.Backup[backupID]
+	backup, ok := config.ActualConfig.Backup[backupID]
 	if !ok {
 		return ErrBackupNotExist.T(backupID)
 	}
-	if err := db.RunBackup(dir); err != nil {
-		return ErrDB.Wrap(err).T(dir)
+	if !lockBackup(backupID) {
+		return ErrBackupRunning.T(backupID)
+	}
+	defer unlockBackup(backupID)
+
+	start := time.Now()
+	path := backup.Dir
+	if backupTimestamped(backup) {
+		path = filepath.Join(backup.Dir, start.Format(backupTimeFormat))
+		if backup.Archive {
+			path += backupArchiveExt
+		}
+	}
+	run := &db.BackupRun{
+		BackupID: backupID,
+		Started:  start.UnixNano(),
+		Path:     path,
+	}
+	if err := db.InsertBackupRun(run); err != nil {
+		return ErrDB.Wrap(err, run)
+	}
+
+	var err error
+	if backup.Archive {
+		err = db.RunBackupArchive(path)
+	} else {
+		err = db.RunBackup(path)
+	}
+	run.Finished = time.Now().UnixNano()
+	if err != nil {
+		run.Error = err.Error()
+		backupDuration.ObserveSince(start, backupID, "error")
+	} else {
+		run.Size = pathSize(path)
+		backupDuration.ObserveSince(start, backupID, "ok")
+	}
+	if err := db.UpdateBackupRun(run); err != nil {
+		log.Println("can't update backup run", run.ID, "cause:", err)
+	}
+	if err := db.PruneBackupRuns(backupID, backupHistoryLimit); err != nil {
+		log.Println("can't prune the backup history", backupID, "cause:", err)
+	}
+	if err != nil {
+		return ErrDB.Wrap(err).T(path)
+	}
+	if backupTimestamped(backup) {
+		if err := rotateBackups(backup); err != nil {
+			log.Println("can't rotate the backups", backupID, "cause:", err)
+		}
 	}
 	return nil
 }
 
-// GetBackups returns the backup configuration
-func GetBackups() []Backup {
+func pathSize(path string) int64 {
+	size := int64(0)
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+type backupEntryT struct {
+	name    string
+	created time.Time
+}
+
+// rotateBackups deletes the timestamped backups which are not kept by the Keep* rules
+func rotateBackups(backup config.BackupConfig) error {
+	if backup.KeepLast <= 0 && backup.KeepDaily <= 0 && backup.KeepWeekly <= 0 {
+		return nil
+	}
+	files, err := ioutil.ReadDir(backup.Dir)
+	if err != nil {
+		return err
+	}
+	entries := []backupEntryT{}
+	for _, f := range files {
+		name := f.Name()
+		if backup.Archive != strings.HasSuffix(name, backupArchiveExt) {
+			continue
+		}
+		created, err := time.ParseInLocation(backupTimeFormat, strings.TrimSuffix(name, backupArchiveExt), time.Local)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, backupEntryT{name, created})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].created.After(entries[j].created)
+	})
+	keep := keptBackups(entries, backup.KeepLast, backup.KeepDaily, backup.KeepWeekly)
+	for i, e := range entries {
+		if keep[i] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(backup.Dir, e.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keptBackups returns the indexes of the kept backups, the entries must be sorted newest first
+func keptBackups(entries []backupEntryT, last, daily, weekly int) map[int]bool {
+	keep := map[int]bool{}
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	for i, e := range entries {
+		if i < last {
+			keep[i] = true
+		}
+		day := e.created.Format("2006-01-02")
+		if !days[day] && len(days) < daily {
+			days[day] = true
+			keep[i] = true
+		}
+		year, week := e.created.ISOWeek()
+		weekKey := fmt.Sprint(year, "-", week)
+		if !weeks[weekKey] && len(weeks) < weekly {
+			weeks[weekKey] = true
+			keep[i] = true
+		}
+	}
+	return keep
+}
+
+// nextBackupRun returns the next scheduled run after the last one, zero if the backup isn't scheduled
+func nextBackupRun(backup config.BackupConfig, last time.Time) (time.Time, error) {
+	if backup.At != "" {
+		at, err := time.ParseInLocation("15:04", backup.At, last.Location())
+		if err != nil {
+			return time.Time{}, err
+		}
+		next := time.Date(last.Year(), last.Month(), last.Day(), at.Hour(), at.Minute(), 0, 0, last.Location())
+		if !next.After(last) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next, nil
+	}
+	if backup.Interval != "" {
+		interval, err := time.ParseDuration(backup.Interval)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if interval <= 0 {
+			return time.Time{}, fmt.Errorf("invalid interval: %v", backup.Interval)
+		}
+		return last.Add(interval), nil
+	}
+	return time.Time{}, nil
+}
+
+func getLastBackupRun(backupID string) (*db.BackupRun, error) {
+	runs, err := db.GetBackupRuns(backupID)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, nil
+	}
+	return &runs[0], nil
+}
+
+func getNextBackupRun(backup config.BackupConfig, lastRun *db.BackupRun) (time.Time, error) {
+	last := backupSchedulerStart
+	if lastRun != nil {
+		last = time.Unix(0, lastRun.Started)
+	}
+	return nextBackupRun(backup, last)
+}
+
+// StartBackupScheduler runs the scheduled backups in the background
+func StartBackupScheduler() {
+	backupSchedulerStart = time.Now()
+	startScheduler("backup", time.Minute, RunDueBackups)
+}
+
+// RunDueBackups runs the scheduled backups which are due
+func RunDueBackups(now time.Time) error {
+	for id, backup := range config.ActualConfig.Backup {
+		lastRun, err := getLastBackupRun(id)
+		if err != nil {
+			return ErrDB.Wrap(err, id)
+		}
+		next, err := getNextBackupRun(backup, lastRun)
+		if err != nil {
+			log.Println("invalid backup schedule", id, "cause:", err)
+			continue
+		}
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+		if err := RunBackup(id); err != nil {
+			log.Println("scheduled backup failed", id, "cause:", err)
+		}
+	}
+	return nil
+}
+
+// GetBackups returns the backup configuration with the status
+func GetBackups() ([]Backup, error) {
 	backups := []Backup{}
-	for k, v := range config.ActualConfig.Backup {
-		backups = append(backups, Backup{k, v})
+	for id, b := range config.ActualConfig.Backup {
+		backup := Backup{
+			ID:         id,
+			Dir:        b.Dir,
+			Interval:   b.Interval,
+			At:         b.At,
+			Archive:    b.Archive,
+			KeepLast:   b.KeepLast,
+			KeepDaily:  b.KeepDaily,
+			KeepWeekly: b.KeepWeekly,
+			Running:    isBackupRunning(id),
+		}
+		lastRun, err := getLastBackupRun(id)
+		if err != nil {
+			return nil, ErrDB.Wrap(err, id)
+		}
+		if lastRun != nil {
+			backup.LastRun = toBackupRunT(lastRun)
+		}
+		if next, err := getNextBackupRun(b, lastRun); err == nil && !next.IsZero() {
+			backup.NextRun = next.UnixNano()
+		}
+		backups = append(backups, backup)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ID < backups[j].ID
+	})
+	return backups, nil
+}
+
+// GetBackupRuns returns the backup's history
+func GetBackupRuns(backupID string) ([]BackupRunT, error) {
+	if _, ok := config.ActualConfig.Backup[backupID]; !ok {
+		return nil, ErrBackupNotExist.T(backupID)
+	}
+	runs, err := db.GetBackupRuns(backupID)
+	if err != nil {
+		return nil, ErrDB.Wrap(err, backupID)
+	}
+	ret := []BackupRunT{}
+	for _, r := range runs {
+		ret = append(ret, *toBackupRunT(&r))
 	}
-	return backups
+	return ret, nil
+}
+
+// BackupReportT is the db's BackupReportT struct
+type BackupReportT = db.BackupReportT
+
+// RestoreOptionsT is the db's RestoreOptionsT struct
+type RestoreOptionsT = db.RestoreOptionsT
+
+// VerifyBackup checks the backup dir's or archive's consistency
+func VerifyBackup(dir string) (*BackupReportT, error) {
+	report, err := db.VerifyBackup(dir)
+	if err != nil {
+		return nil, ErrDB.Wrap(err).T(dir)
+	}
+	return report, nil
+}
+
+// RestoreBackup restores the backup dir or archive into the data dir
+func RestoreBackup(dir string, options RestoreOptionsT) error {
+	if err := db.RestoreBackup(dir, config.ActualConfig.DataDir, options); err != nil {
+		return ErrDB.Wrap(err).T(dir)
+	}
+	return nil
+}
+
+// WriteBackup streams a tar snapshot of the whole database
+func WriteBackup(w io.Writer, compress bool) error {
+	if err := db.WriteBackup(w, compress); err != nil {
+		return ErrDB.Wrap(err)
+	}
+	return nil
+}
+
+// WriteCollectionBackup streams a tar snapshot of the collection's shards
+func WriteCollectionBackup(w io.Writer, collection *Collection, compress bool) error {
+	if err := db.WriteCollectionBackup(w, collection.ID, compress); err != nil {
+		return ErrDB.Wrap(err, collection.ID)
+	}
+	return nil
 }
@@ -17,39 +17,69 @@ type Collection = db.Collection
 type CollectionDataInputT = db.CollectionDataInputT
 
 // CreateCollection creates a collection
-func CreateCollection(ownerID uint64, name string) (*Collection, error) {
+func CreateCollection(ownerID uint64, name string, granularity string) (*Collection, error) {
 	id := randStringBytes(8)
 	user, err := db.GetUserByID(ownerID)
 	if err != nil {
-		return nil, ErrUserNotExist.T(string(ownerID)).Wrap(err)
+		return nil, ErrUserNotExist.T(strconv.FormatUint(ownerID, 10)).Wrap(err)
 	}
-	return createCollection(id, name, user)
+	return createCollection(id, name, granularity, user)
 }
 
 // CreateCollectionByID creates a collection with a fixed ID
-func CreateCollectionByID(id string, name string, username string) (*Collection, error) {
+func CreateCollectionByID(id string, name string, granularity string, username string) (*Collection, error) {
 	user, err := GetUserByName(username)
 	if err != nil {
 		return nil, err
 	}
-	return createCollection(id, name, user)
+	return createCollection(id, name, granularity, user)
 }
 
-func createCollection(id string, name string, user *User) (*Collection, error) {
-	if user.LimitCollections {
-		collections, err := db.GetCollectionsByUserID(user.ID)
-		if err != nil {
-			return nil, ErrDB.Wrap(err, user.ID)
-		}
-		if len(collections) >= int(user.CollectionLimit) {
-			return nil, ErrCollectionLimitExceeded.T(strconv.Itoa(int(user.CollectionLimit)))
+// GetShardGranularity returns the collection's shard granularity
+func GetShardGranularity(collection *Collection) string {
+	return db.GetShardGranularity(collection)
+}
+
+// checkCollectionLimit checks whether the user can get one more collection,
+// the collection with the except ID is left out from the user's ones
+func checkCollectionLimit(user *User, except string) error {
+	if !user.LimitCollections {
+		return nil
+	}
+	collections, err := db.GetCollectionsByUserID(user.ID)
+	if err != nil {
+		return ErrDB.Wrap(err, user.ID)
+	}
+	count := 0
+	for _, c := range collections {
+		if c.ID != except {
+			count++
 		}
 	}
+	if count >= int(user.CollectionLimit) {
+		return ErrCollectionLimitExceeded.T(strconv.Itoa(int(user.CollectionLimit)))
+	}
+	return nil
+}
+
+func createCollection(id string, name string, granularity string, user *User) (*Collection, error) {
+	if err := checkCollectionLimit(user, ""); err != nil {
+		return nil, err
+	}
+	if _, err := db.GetCollection(id); err == nil {
+		return nil, ErrCollectionExist.T(id)
+	} else if err != db.ErrKeyNotExists {
+		return nil, ErrDB.Wrap(err, id)
+	}
+	if !db.ValidShardGranularity(granularity) {
+		return nil, ErrInvalidShardGranularity.T(granularity)
+	}
 	collection := &Collection{
-		ID:      randStringBytes(8),
-		OwnerID: user.ID,
-		Name:    name,
-		Created: time.Now().UnixNano(),
+		ID:               id,
+		OwnerID:          user.ID,
+		Name:             name,
+		Created:          time.Now().UnixNano(),
+		ShardGranularity: granularity,
 	}
 	if err := validateCollection(collection); err != nil {
 		return nil, err
@@ -193,6 +223,7 @@ func DeleteCollectionShard(collection *Collection, shardID string) error {
 	if err := db.DeleteCollectionShard(collection, shardID); err != nil {
 		return ErrDB.Wrap(err, shardID)
 	}
+	fireWebhooks(collection.ID, WebhookShardDeleted, webhookShardT{shardID})
 	return nil
 }
 
@@ -237,7 +268,7 @@ func GetCollectionTeammates(collection *Collection) ([]TeammateT, error) {
 	for _, v := range collection.Teammates {
 		user, err := GetUserByID(v.ID)
 		if err != nil {
-			return nil, ErrDB.T(string(v.ID)).Wrap(err)
+			return nil, ErrDB.T(strconv.FormatUint(v.ID, 10)).Wrap(err)
 		}
 		tms = append(tms, TeammateT{user.Email})
 	}
@@ -262,13 +293,18 @@ func GetCollectionStatData(collection *Collection, input *CollectionDataInputT)
 	return data, nil
 }
 
-// GetSessions return the collection's sessions
-func GetSessions(collection *Collection, input *CollectionDataInputT) ([]*db.SessionDataT, error) {
-	data, err := db.GetSessions(collection, input)
+// GetSessions return a page of the collection's sessions and the count of all the matching sessions
+func GetSessions(collection *Collection, input *CollectionDataInputT) ([]*db.SessionDataT, int, error) {
+	if input.Cursor != "" {
+		if _, err := db.DecodeSessionKey(input.Cursor); err != nil {
+			return nil, 0, ErrInvalidCursor.T(input.Cursor).Wrap(err)
+		}
+	}
+	data, total, err := db.GetSessions(collection, input)
 	if err != nil {
-		return nil, ErrDB.Wrap(err, collection, input)
+		return nil, 0, ErrDB.Wrap(err, collection, input)
 	}
-	return data, nil
+	return data, total, nil
 }
 
 // GetPageviews return the pageviews for the collection
@@ -294,7 +330,10 @@ func SeedCollection(from time.Time, to time.Time, collectionID string, n int) er
 	return db.Seed(from, to, collectionID, n)
 }
 
-var collectionRegexp = regexp.MustCompile("^[a-z0-9.]+$")
+var (
+	collectionRegexp   = regexp.MustCompile("^[a-z0-9.]+$")
+	collectionIDRegexp = regexp.MustCompile("^[A-Za-z0-9]+$")
+)
 
 func validateCollection(c *Collection) error {
 	if !collectionRegexp.MatchString(c.Name) {
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/soyersoyer/rightana/internal/db"
+	"github.com/soyersoyer/rightana/internal/mail"
+)
+
+// The digest frequencies
+const (
+	DigestWeekly  = "weekly"
+	DigestMonthly = "monthly"
+)
+
+const digestTopN = 5
+
+// DigestT contains the user's digest report settings
+type DigestT struct {
+	Frequency   string   `json:"frequency"`
+	Collections []string `json:"collections"`
+}
+
+// GetDigest returns the user's digest report settings
+func GetDigest(user *User) DigestT {
+	collections := user.DigestCollections
+	if collections == nil {
+		collections = []string{}
+	}
+	return DigestT{user.DigestFrequency, collections}
+}
+
+// UpdateDigest updates the user's digest report settings
+func UpdateDigest(user *User, input *DigestT) error {
+	if input.Frequency != "" && input.Frequency != DigestWeekly && input.Frequency != DigestMonthly {
+		return ErrInvalidDigest.T(input.Frequency)
+	}
+	for _, ID := range input.Collections {
+		collection, err := GetCollection(ID)
+		if err != nil {
+			return err
+		}
+		if err := CollectionReadAccessCheck(collection, user); err != nil {
+			return err
+		}
+	}
+	if input.Frequency != "" && user.DigestFrequency != input.Frequency {
+		user.DigestSentAt = time.Now().UnixNano()
+	}
+	user.DigestFrequency = input.Frequency
+	user.DigestCollections = input.Collections
+	if err := db.UpdateUser(user); err != nil {
+		return ErrDB.Wrap(err, user)
+	}
+	return nil
+}
+
+// StartDigestScheduler sends the due digest reports periodically in the background
+func StartDigestScheduler(interval time.Duration) {
+	startScheduler("digest sending", interval, SendDigests)
+}
+
+// SendDigests sends the digest reports which are due
+func SendDigests(now time.Time) error {
+	users, err := db.GetUsers()
+	if err != nil {
+		return ErrDB.Wrap(err)
+	}
+	for _, u := range users {
+		if u.DigestFrequency == "" || len(u.DigestCollections) == 0 {
+			continue
+		}
+		from, to := getDigestPeriod(u.DigestFrequency, now)
+		if !time.Unix(0, u.DigestSentAt).Before(to) {
+			continue
+		}
+		if err := sendDigest(&u, from, to); err != nil {
+			log.Println("can't send digest to", u.Name, "cause:", err)
+			continue
+		}
+		user := &User{}
+		if err := db.SetFields(u.ID, user, func() { user.DigestSentAt = now.UnixNano() }); err != nil {
+			log.Println("can't update user", u.Name, "cause:", err)
+		}
+	}
+	return nil
+}
+
+// getDigestPeriod returns the last closed week or month before now
+func getDigestPeriod(frequency string, now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if frequency == DigestMonthly {
+		to := today.AddDate(0, 0, 1-today.Day())
+		return to.AddDate(0, -1, 0), to
+	}
+	to := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	return to.AddDate(0, 0, -7), to
+}
+
+func sendDigest(user *User, from, to time.Time) error {
+	collections := []mail.DigestCollection{}
+	for _, ID := range user.DigestCollections {
+		collection, err := db.GetCollection(ID)
+		if err != nil {
+			log.Println("digest collection not found", ID, "cause:", err)
+			continue
+		}
+		if CollectionReadAccessCheck(collection, user) != nil {
+			continue
+		}
+		dc, err := getDigestCollection(collection, from, to)
+		if err != nil {
+			return err
+		}
+		collections = append(collections, *dc)
+	}
+	if len(collections) == 0 {
+		return nil
+	}
+	if err := mail.SendDigest(user.Email, user.Name, user.DigestFrequency,
+		from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02"), collections); err != nil {
+		return ErrEmailSending.Wrap(err)
+	}
+	return nil
+}
+
+func getDigestCollection(collection *Collection, from, to time.Time) (*mail.DigestCollection, error) {
+	owner, err := db.GetUserByID(collection.OwnerID)
+	if err != nil {
+		return nil, ErrDB.Wrap(err, collection.OwnerID)
+	}
+	stat, err := db.GetStatistics(collection, &CollectionDataInputT{
+		From:  from,
+		To:    to,
+		Limit: digestTopN,
+	})
+	if err != nil {
+		return nil, ErrDB.Wrap(err, collection.ID)
+	}
+	dc := &mail.DigestCollection{
+		Name:            collection.Name,
+		OwnerName:       owner.Name,
+		SessionCount:    stat.SessionTotal.Count,
+		SessionPercent:  stat.SessionTotal.DiffPercent,
+		PageviewCount:   stat.PageviewTotal.Count,
+		PageviewPercent: stat.PageviewTotal.DiffPercent,
+	}
+	for _, v := range stat.PageSums {
+		dc.TopPages = append(dc.TopPages, mail.DigestRow{Name: v.Name, Count: v.Count})
+	}
+	for _, v := range stat.ReferrerSums {
+		dc.TopReferrers = append(dc.TopReferrers, mail.DigestRow{Name: v.Name, Count: v.Count})
+	}
+	return dc, nil
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/soyersoyer/rightana/internal/db"
+)
+
+// ErasureInputT is the input of a session erasure, exactly one of the session key, IP and IP range is needed
+type ErasureInputT struct {
+	SessionKey string    `json:"session_key"`
+	IP         string    `json:"ip"`
+	IPRange    string    `json:"ip_range"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+}
+
+// ErasureT is the erasure audit trail struct for the clients, the criterion's value
+// is recorded only as a bcrypt hash
+type ErasureT struct {
+	ID           uint64 `json:"id"`
+	Actor        string `json:"actor"`
+	Created      int64  `json:"created"`
+	Criterion    string `json:"criterion"`
+	CriteriaHash string `json:"criteria_hash"`
+	From         int64  `json:"from"`
+	To           int64  `json:"to"`
+	Sessions     int32  `json:"sessions"`
+	Pageviews    int32  `json:"pageviews"`
+}
+
+func toErasureT(e *db.Erasure) ErasureT {
+	return ErasureT{
+		e.ID,
+		e.Actor,
+		e.Created,
+		e.Criterion,
+		e.CriteriaHash,
+		e.From,
+		e.To,
+		e.Sessions,
+		e.Pageviews,
+	}
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func validateErasure(input *ErasureInputT) error {
+	criteria := 0
+	for _, c := range []string{input.SessionKey, input.IP, input.IPRange} {
+		if c != "" {
+			criteria++
+		}
+	}
+	if criteria != 1 {
+		return ErrInvalidErasure.T("one of session key, ip or ip range is needed")
+	}
+	if input.IP != "" && net.ParseIP(input.IP) == nil {
+		return ErrInvalidErasure.T(input.IP)
+	}
+	if input.IPRange != "" {
+		if _, _, err := net.ParseCIDR(input.IPRange); err != nil {
+			return ErrInvalidErasure.T(input.IPRange).Wrap(err)
+		}
+	}
+	if !input.To.IsZero() && input.To.Before(input.From) {
+		return ErrInvalidErasure.T("to is before from")
+	}
+	return nil
+}
+
+// EraseSessions deletes the matching sessions with their pageviews and records it in the audit trail,
+// a dry run only counts them
+func EraseSessions(collection *Collection, input *ErasureInputT, actor string, dryRun bool) (*ErasureT, error) {
+	if err := validateErasure(input); err != nil {
+		return nil, err
+	}
+	erasure := &db.Erasure{
+		CollectionID: collection.ID,
+		Actor:        actor,
+		SessionKey:   input.SessionKey,
+		IP:           input.IP,
+		IPRange:      input.IPRange,
+		From:         unixNano(input.From),
+		To:           unixNano(input.To),
+	}
+	if err := db.EraseSessions(erasure, dryRun); err != nil {
+		if err == db.ErrInvalidSessionKey {
+			return nil, ErrInvalidErasure.T(input.SessionKey).Wrap(err)
+		}
+		return nil, ErrDB.Wrap(err, collection.ID)
+	}
+	ret := toErasureT(erasure)
+	return &ret, nil
+}
+
+// GetErasures returns the collection's erasure audit trail
+func GetErasures(collection *Collection) ([]ErasureT, error) {
+	erasures, err := db.GetErasures(collection.ID)
+	if err != nil {
+		return nil, ErrDB.Wrap(err, collection.ID)
+	}
+	ret := []ErasureT{}
+	for _, e := range erasures {
+		ret = append(ret, toErasureT(&e))
+	}
+	return ret, nil
+}
+
+// PurgeInputT is the input of a collection purge
+type PurgeInputT struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// PurgeReportT is the purge's report
+type PurgeReportT = db.PurgeReportT
+
+// CompactReportT is a compacted shard's sizes
+type CompactReportT = db.CompactReportT
+
+// PurgeCollection deletes the sessions started between from and to with their pageviews,
+// the compaction of the affected shards is queued when the compaction queue runs
+func PurgeCollection(collection *Collection, input *PurgeInputT) (*PurgeReportT, error) {
+	if input.From.IsZero() || !input.From.Before(input.To) {
+		return nil, ErrInvalidTimeRange.T(fmt.Sprint(input.From, " - ", input.To))
+	}
+	report, err := db.PurgeCollection(collection.ID, input.From, input.To)
+	if err != nil {
+		return nil, ErrDB.Wrap(err, collection.ID, input.From, input.To)
+	}
+	if report.Sessions > 0 || report.Pageviews > 0 {
+		report.CompactionQueued = queueCompaction(compactionT{collection.ID, input.From, input.To})
+	}
+	return report, nil
+}
+
+// CompactCollection compacts the collection's shards between from and to,
+// it can take long on big shards, so the requests only queue it
+func CompactCollection(collection *Collection, input *PurgeInputT) ([]CompactReportT, error) {
+	shards, err := db.CompactCollection(collection.ID, input.From, input.To)
+	if err != nil {
+		return nil, ErrDB.Wrap(err, collection.ID, input.From, input.To)
+	}
+	return shards, nil
+}
+
+const compactionQueueSize = 64
+
+type compactionT struct {
+	collectionID string
+	from         time.Time
+	to           time.Time
+}
+
+// compactions passes the purged time windows to the compaction worker,
+// it's nil while the worker doesn't run
+var compactions chan compactionT
+
+// queueCompaction hands over the compaction to the worker, it doesn't block the caller
+func queueCompaction(c compactionT) bool {
+	if compactions == nil {
+		return false
+	}
+	select {
+	case compactions <- c:
+		return true
+	default:
+		log.Println("the compaction queue is full, not compacting", c.collectionID, c.from, c.to)
+		return false
+	}
+}
+
+// StartCompactionQueue compacts the purged shards in the background until StopSchedulers,
+// the running compaction is finished, the queued ones are dropped
+func StartCompactionQueue() {
+	compactions = make(chan compactionT, compactionQueueSize)
+	schedulerWG.Add(1)
+	go func() {
+		defer schedulerWG.Done()
+		for {
+			select {
+			case c := <-compactions:
+				shards, err := db.CompactCollection(c.collectionID, c.from, c.to)
+				if err != nil {
+					log.Println("compaction of", c.collectionID, "failed:", err)
+				}
+				for _, s := range shards {
+					log.Printf("shard %v/%v compacted: %d -> %d bytes\n", c.collectionID, s.ID, s.Before, s.After)
+				}
+			case <-schedulerQuit:
+				return
+			}
+		}
+	}()
+}
@@ -27,11 +27,26 @@ var (
 	ErrCollectionNotExist      = &Error{"Collection not exist", 404, "", ""}
 	ErrCollectionLimitExceeded = &Error{"Collection limit exceeded", 403, "", ""}
 	ErrCollectionNameExist     = &Error{"Collection name exists", 403, "", ""}
+	ErrCollectionExist         = &Error{"Collection exists", 403, "", ""}
+	ErrInvalidExport           = &Error{"Invalid collection export", 400, "", ""}
+	ErrInvalidShardGranularity = &Error{"Invalid shard granularity", 400, "", ""}
 	ErrSessionNotExist         = &Error{"Session not exist", 404, "", ""}
+	ErrInvalidCursor           = &Error{"Invalid cursor", 400, "", ""}
+	ErrInvalidErasure          = &Error{"Invalid erasure", 400, "", ""}
+	ErrInvalidTimeRange        = &Error{"Invalid time range", 400, "", ""}
+	ErrInvalidSessionSettings  = &Error{"Invalid session settings", 400, "", ""}
+	ErrInvalidPropertyLimits   = &Error{"Invalid property limits", 400, "", ""}
 	ErrTeammateExist           = &Error{"Teammate exist", 403, "", ""}
 	ErrBackupNotExist          = &Error{"Backup not exist", 404, "", ""}
+	ErrBackupRunning           = &Error{"Backup is running", 409, "", ""}
 	ErrEmailSending            = &Error{"Can't send email", 500, "", ""}
 	ErrEmailExpired            = &Error{"Email expired", 403, "", ""}
+	ErrAlertNotExist           = &Error{"Alert not exist", 404, "", ""}
+	ErrInvalidAlert            = &Error{"Invalid alert", 400, "", ""}
+	ErrForbiddenTarget         = &Error{"Forbidden target", 403, "", ""}
+	ErrWebhookNotExist         = &Error{"Webhook not exist", 404, "", ""}
+	ErrInvalidWebhook          = &Error{"Invalid webhook", 400, "", ""}
+	ErrInvalidDigest           = &Error{"Invalid digest frequency", 400, "", ""}
 )
 
 // Error is the Extended error struct
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"io"
+
+	"github.com/soyersoyer/rightana/internal/db"
+)
+
+// ImportReportT is the db's ImportReportT struct
+type ImportReportT = db.ImportReportT
+
+// ExportCollection writes the collection's export stream
+func ExportCollection(w io.Writer, collection *Collection) error {
+	if err := db.ExportCollection(w, collection); err != nil {
+		return ErrDB.Wrap(err, collection.ID)
+	}
+	return nil
+}
+
+// ImportCollection imports an export stream into the collection with the collectionID.
+// The collection is created for the owner when it doesn't exist, an empty collectionID means
+// the exported collection's ID if it's free, and an empty name means the exported collection's name.
+// When the import fails, the created collection is deleted, and an existing collection's report
+// contains the records imported before the error.
+func ImportCollection(r io.Reader, owner *User, collectionID string, name string) (*ImportReportT, error) {
+	er, err := db.NewExportReader(r)
+	if err != nil {
+		return nil, ErrInvalidExport.Wrap(err)
+	}
+	collection, created, err := getImportCollection(er.Header, owner, collectionID, name)
+	if err != nil {
+		return nil, err
+	}
+	report, err := db.ImportCollection(er, collection.ID)
+	if err != nil {
+		if created {
+			if err := db.DeleteCollection(collection); err != nil {
+				return report, ErrDB.Wrap(err, collection.ID)
+			}
+			return nil, ErrInvalidExport.T(collection.ID).Wrap(err)
+		}
+		return report, ErrInvalidExport.T(collection.ID).Wrap(err)
+	}
+	return report, nil
+}
+
+// getImportCollection returns the collection to import into and whether it's created for the import
+func getImportCollection(header *db.ExportHeader, owner *User, collectionID string, name string) (*Collection, bool, error) {
+	if name == "" {
+		name = header.Name
+	}
+	if collectionID == "" {
+		collectionID = header.CollectionID
+		if _, err := db.GetCollection(collectionID); err == nil {
+			collectionID = randStringBytes(8)
+		}
+	}
+	collection, err := db.GetCollection(collectionID)
+	if err == nil {
+		return collection, false, nil
+	}
+	if err != db.ErrKeyNotExists {
+		return nil, false, ErrDB.Wrap(err, collectionID)
+	}
+	if !collectionIDRegexp.MatchString(collectionID) {
+		return nil, false, ErrInvalidExport.T(collectionID)
+	}
+	collection, err = createCollection(collectionID, name, header.ShardGranularity, owner)
+	if err != nil {
+		return nil, false, err
+	}
+	return collection, true, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/soyersoyer/rightana/internal/config"
+	"github.com/soyersoyer/rightana/internal/db"
+	"github.com/soyersoyer/rightana/internal/geoip"
+)
+
+// The readiness statuses
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckT is the result of a readiness check
+type CheckT struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	FreeBytes uint64 `json:"free_bytes,omitempty"`
+}
+
+// ReadinessT is the readiness breakdown for the probes
+type ReadinessT struct {
+	Status string            `json:"status"`
+	Checks map[string]CheckT `json:"checks"`
+}
+
+func newCheck(err error) CheckT {
+	if err != nil {
+		return CheckT{Status: StatusFail, Error: err.Error()}
+	}
+	return CheckT{Status: StatusOK}
+}
+
+func checkDataDir() CheckT {
+	free, err := db.CheckDataDir()
+	if err == nil {
+		minFree := uint64(config.ActualConfig.MinFreeDiskMB) << 20
+		if free < minFree {
+			err = fmt.Errorf("free space %d bytes is below %d bytes", free, minFree)
+		}
+	}
+	check := newCheck(err)
+	check.FreeBytes = free
+	return check
+}
+
+func checkLoaded(loaded bool, file string) CheckT {
+	if !loaded {
+		return newCheck(fmt.Errorf("can't load %v", file))
+	}
+	return newCheck(nil)
+}
+
+// CheckReadiness checks the storages and the GeoIP databases
+func CheckReadiness() ReadinessT {
+	city, asn := geoip.Loaded()
+	checks := map[string]CheckT{
+		"db":         newCheck(db.CheckMain()),
+		"datadir":    checkDataDir(),
+		"geoip_city": checkLoaded(city, config.ActualConfig.GeoIPCityFile),
+		"geoip_asn":  checkLoaded(asn, config.ActualConfig.GeoIPASNFile),
+	}
+	status := StatusOK
+	for _, c := range checks {
+		if c.Status != StatusOK {
+			status = StatusFail
+		}
+	}
+	return ReadinessT{status, checks}
+}
@@ -0,0 +1,13 @@
+package service
+
+import (
+	"github.com/soyersoyer/rightana/internal/metrics"
+)
+
+var (
+	authFailures = metrics.NewCounter("rightana_auth_failures_total",
+		"The number of the failed logins and the rejected auth tokens.", "reason")
+
+	backupDuration = metrics.NewHistogram("rightana_backup_duration_seconds",
+		"The duration of the backups.", []float64{1, 5, 15, 60, 300, 900, 3600}, "backup", "outcome")
+)
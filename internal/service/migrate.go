@@ -0,0 +1,78 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"time"
+
+	"github.com/soyersoyer/rightana/internal/config"
+	"github.com/soyersoyer/rightana/internal/db"
+)
+
+// MigrationPlanT is the db's MigrationPlanT struct
+type MigrationPlanT = db.MigrationPlanT
+
+// MigrationReportT is the migration's report
+type MigrationReportT struct {
+	*MigrationPlanT
+	DryRun bool   `json:"dry_run"`
+	Backup string `json:"backup"`
+}
+
+// Migrate runs the pending migrations after backing up the data dir, the dry run only lists them
+func Migrate(dryRun bool) (*MigrationReportT, error) {
+	plan, err := db.PlanMigrations()
+	if err != nil {
+		return nil, ErrDB.Wrap(err)
+	}
+	report := &MigrationReportT{MigrationPlanT: plan, DryRun: dryRun}
+	if dryRun || !plan.Pending() {
+		return report, nil
+	}
+	report.Backup = fmt.Sprintf("%s.pre-migration-v%d-%s",
+		path.Clean(config.ActualConfig.DataDir), plan.From, time.Now().Format("20060102-150405"))
+	if err := db.RunBackup(report.Backup); err != nil {
+		return nil, ErrDB.Wrap(err).T(report.Backup)
+	}
+	if err := db.Migrate(); err != nil {
+		return nil, ErrDB.Wrap(err)
+	}
+	return report, nil
+}
+
+func errMigrationNeeded(plan *MigrationPlanT) error {
+	return fmt.Errorf("the database needs migration from version %d to %d, run rightana migrate", plan.From, plan.To)
+}
+
+// CheckSchema fails if the database needs migration, only the server migrates it at the start
+func CheckSchema() error {
+	plan, err := db.PlanMigrations()
+	if err != nil {
+		return ErrDB.Wrap(err)
+	}
+	if plan.Pending() {
+		return errMigrationNeeded(plan)
+	}
+	return nil
+}
+
+// MigrateOnStart runs the pending migrations if AutoMigrate is enabled, otherwise fails on them
+func MigrateOnStart() error {
+	plan, err := db.PlanMigrations()
+	if err != nil {
+		return ErrDB.Wrap(err)
+	}
+	if !plan.Pending() {
+		return nil
+	}
+	if !config.ActualConfig.AutoMigrate {
+		return errMigrationNeeded(plan)
+	}
+	report, err := Migrate(false)
+	if err != nil {
+		return err
+	}
+	log.Printf("database migrated from version %d to %d, the backup is in %v", report.From, report.To, report.Backup)
+	return nil
+}
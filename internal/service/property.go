@@ -0,0 +1,73 @@
+package service
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/soyersoyer/rightana/internal/db"
+)
+
+// PropertyLimitsT contains the collection's limits of the custom properties
+type PropertyLimitsT struct {
+	MaxKeys        int `json:"max_keys"`
+	MaxValueLength int `json:"max_value_length"`
+}
+
+// GetPropertyLimits returns the collection's limits of the custom properties
+func GetPropertyLimits(collection *Collection) PropertyLimitsT {
+	keys, length := db.GetPropertyLimits(collection)
+	return PropertyLimitsT{keys, length}
+}
+
+// UpdatePropertyLimits updates the collection's limits of the custom properties, zero means the default
+func UpdatePropertyLimits(collection *Collection, input *PropertyLimitsT) error {
+	if input.MaxKeys < 0 || input.MaxKeys > 50 {
+		return ErrInvalidPropertyLimits.T(strconv.Itoa(input.MaxKeys))
+	}
+	if input.MaxValueLength < 0 || input.MaxValueLength > 1000 {
+		return ErrInvalidPropertyLimits.T(strconv.Itoa(input.MaxValueLength))
+	}
+	collection.MaxPropertyKeys = int32(input.MaxKeys)
+	collection.MaxPropertyValueLength = int32(input.MaxValueLength)
+	if err := db.UpdateCollection(collection); err != nil {
+		return ErrDB.Wrap(err, collection)
+	}
+	return nil
+}
+
+// limitProperties converts the tracker's custom properties, the keys over the collection's limit
+// are dropped in key order, the too long keys and values are truncated
+func limitProperties(collection *Collection, properties map[string]string) []*db.Property {
+	maxKeys, maxLength := db.GetPropertyLimits(collection)
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	ret := []*db.Property{}
+	for _, k := range keys {
+		key := truncate(k, maxLength)
+		if len(ret) > 0 && ret[len(ret)-1].Key == key {
+			continue
+		}
+		if len(ret) >= maxKeys {
+			break
+		}
+		ret = append(ret, &db.Property{Key: key, Value: truncate(properties[k], maxLength)})
+	}
+	return ret
+}
+
+// truncate cuts the string to at most n characters
+func truncate(s string, n int) string {
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos]
+		}
+		i++
+	}
+	return s
+}
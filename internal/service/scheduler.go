@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	schedulerQuit = make(chan struct{})
+	schedulerWG   sync.WaitGroup
+)
+
+// startScheduler runs the job periodically in the background until StopSchedulers
+func startScheduler(name string, interval time.Duration, job func(now time.Time) error) {
+	if interval <= 0 {
+		return
+	}
+	schedulerWG.Add(1)
+	go func() {
+		defer schedulerWG.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-schedulerQuit:
+				return
+			case now := <-ticker.C:
+				if err := job(now); err != nil {
+					log.Println(name, "failed:", err)
+				}
+			}
+		}
+	}()
+}
+
+// StopSchedulers stops the background schedulers and waits for the running jobs until the ctx is done
+func StopSchedulers(ctx context.Context) error {
+	close(schedulerQuit)
+	done := make(chan struct{})
+	go func() {
+		schedulerWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
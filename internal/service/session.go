@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"math/rand"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,6 +23,10 @@ type CreateSessionInputT struct {
 	WindowResolution string
 	DeviceType       string
 	Referrer         string
+	// the tracker's visit counter, 0 means it's disabled
+	VisitCount         int32
+	DaysSinceLastVisit int32
+	Properties         map[string]string
 }
 
 // CreateSession creates a session
@@ -75,17 +80,35 @@ func CreateSession(userAgent string, remoteAddr string, input CreateSessionInput
 		UserAgent:        userAgent,
 		Duration:         0,
 		Referrer:         input.Referrer,
+		VisitorID:        getVisitorID(now, ip, userAgent, collection),
+		Properties:       limitProperties(collection, input.Properties),
+	}
+	if collection.VisitCounter && input.VisitCount > 0 {
+		session.VisitCount = input.VisitCount
+		if input.VisitCount > 1 && input.DaysSinceLastVisit > 0 {
+			session.DaysSinceLastVisit = input.DaysSinceLastVisit
+		}
 	}
 	key := db.GetKey(now, rand.Uint32())
 	if err := db.ShardUpsertBatch(collection.ID, key, session); err != nil {
 		return "", ErrDB.Wrap(err, session)
 	}
 	sessionKey := db.EncodeSessionKey(key)
+	fireWebhooks(collection.ID, WebhookSessionCreated, webhookSessionT{
+		sessionKey,
+		session.Hostname,
+		session.Referrer,
+		session.CountryCode,
+		session.DeviceType,
+		session.BrowserName,
+		session.DeviceOS,
+	})
 	return sessionKey, nil
 }
 
-// UpdateSession updates the session.End field
-func UpdateSession(userAgent string, CollectionID string, sessionKey string) error {
+// SessionHeartbeat records that the session is still active, it extends the session's duration
+func SessionHeartbeat(userAgent string, collectionID string, sessionKey string) error {
+	now := time.Now()
 	ua := user_agent.New(userAgent)
 
 	if ua.Bot() {
@@ -96,15 +119,52 @@ func UpdateSession(userAgent string, CollectionID string, sessionKey string) err
 	if err != nil {
 		return ErrSessionNotExist.T(sessionKey).Wrap(err)
 	}
-	session, err := db.GetSession(CollectionID, key)
+	collection, err := db.GetCollection(collectionID)
 	if err != nil {
-		return ErrSessionNotExist.T(sessionKey).Wrap(err, CollectionID)
+		return ErrCollectionNotExist.T(collectionID).Wrap(err)
 	}
-	sessionBegin := db.GetTimeFromKey(key)
-	session.Duration = int32(time.Now().Sub(sessionBegin).Seconds())
+	if err := db.TouchSession(collection, key, now); err != nil {
+		if err == db.ErrKeyNotExists {
+			return ErrSessionNotExist.T(sessionKey).Wrap(err, collectionID)
+		}
+		return ErrDB.Wrap(err, collectionID, key)
+	}
+	return nil
+}
+
+// SessionSettingsT contains the collection's session continuation rules
+// and whether the tracker's visit counts are recorded
+type SessionSettingsT struct {
+	Timeout          int32  `json:"timeout"`
+	MidnightTimezone string `json:"midnight_timezone"`
+	VisitCounter     bool   `json:"visit_counter"`
+}
+
+// GetSessionSettings returns the collection's session settings
+func GetSessionSettings(collection *Collection) SessionSettingsT {
+	return SessionSettingsT{
+		int32(db.GetSessionTimeout(collection) / time.Minute),
+		collection.SessionMidnightTimezone,
+		collection.VisitCounter,
+	}
+}
 
-	if err := db.ShardUpsertBatch(CollectionID, key, session); err != nil {
-		return ErrDB.Wrap(err, CollectionID, key, session)
+// UpdateSessionSettings updates the collection's session settings,
+// the zero timeout means the default
+func UpdateSessionSettings(collection *Collection, input *SessionSettingsT) error {
+	if input.Timeout < 0 || input.Timeout > 24*60 {
+		return ErrInvalidSessionSettings.T(strconv.Itoa(int(input.Timeout)))
+	}
+	if input.MidnightTimezone != "" {
+		if _, err := time.LoadLocation(input.MidnightTimezone); err != nil {
+			return ErrInvalidSessionSettings.T(input.MidnightTimezone).Wrap(err)
+		}
+	}
+	collection.SessionTimeout = input.Timeout
+	collection.SessionMidnightTimezone = input.MidnightTimezone
+	collection.VisitCounter = input.VisitCounter
+	if err := db.UpdateCollection(collection); err != nil {
+		return ErrDB.Wrap(err, collection)
 	}
 	return nil
 }
@@ -114,39 +174,51 @@ type CreatePageviewInputT struct {
 	CollectionID string
 	SessionKey   string
 	Path         string
+	Properties   map[string]string
 }
 
-// CreatePageview creates a pageview
-func CreatePageview(userAgent string, input CreatePageviewInputT) error {
+// CreatePageview creates a pageview and returns the key of its session,
+// it's a new key when the input's session has expired
+func CreatePageview(userAgent string, input CreatePageviewInputT) (string, error) {
 	now := time.Now()
 	ua := user_agent.New(userAgent)
 
 	if ua.Bot() {
-		return ErrBotsDontMatter
+		return "", ErrBotsDontMatter
 	}
 
 	sessKey, err := base64.StdEncoding.DecodeString(input.SessionKey)
 	if err != nil {
-		return ErrSessionNotExist.T(input.SessionKey).Wrap(err, input.SessionKey)
+		return "", ErrSessionNotExist.T(input.SessionKey).Wrap(err, input.SessionKey)
 	}
-	_, err = db.GetSession(input.CollectionID, sessKey)
+	collection, err := db.GetCollection(input.CollectionID)
 	if err != nil {
-		return ErrSessionNotExist.T(input.SessionKey).Wrap(err, input.CollectionID)
+		return "", ErrCollectionNotExist.T(input.CollectionID).Wrap(err)
 	}
 
-	pvKey := db.GetPVKey(sessKey, now)
-
 	path, queryString := splitURL(input.Path)
 
 	pageview := &db.Pageview{
 		Path:        path,
 		QueryString: queryString,
+		Properties:  limitProperties(collection, input.Properties),
 	}
 
-	if err := db.ShardUpsertBatch(input.CollectionID, pvKey, pageview); err != nil {
-		return ErrDB.Wrap(err, input)
+	// the visitor ID of a continuation session is computed before the shard's write transaction,
+	// a missing session is reported by the AddPageview
+	visitorID := uint64(0)
+	if session, err := db.GetSession(collection.ID, sessKey); err == nil {
+		visitorID = getVisitorID(now, session.UserIP, session.UserAgent, collection)
 	}
-	return nil
+
+	key, err := db.AddPageview(collection, sessKey, now, pageview, visitorID)
+	if err != nil {
+		if err == db.ErrKeyNotExists {
+			return "", ErrSessionNotExist.T(input.SessionKey).Wrap(err, input.CollectionID)
+		}
+		return "", ErrDB.Wrap(err, input)
+	}
+	return db.EncodeSessionKey(key), nil
 }
 
 func getIP(remoteAddr string) (string, error) {
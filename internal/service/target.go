@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"github.com/soyersoyer/rightana/internal/config"
+)
+
+var errPrivateTarget = errors.New("the target address is in a private network")
+
+// privateNetworks are the loopback, private, link local, shared and reserved networks,
+// the alerts and webhooks can't post to them unless the AllowPrivateTargets is set
+var privateNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	ret := []*net.IPNet{}
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		ret = append(ret, n)
+	}
+	return ret
+}
+
+func privateIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// privateTarget checks whether an alert's or a webhook's URL points to localhost or a private IP,
+// the hostnames are checked at the connection by checkTargetAddress
+func privateTarget(u *url.URL) bool {
+	if config.ActualConfig.AllowPrivateTargets {
+		return false
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && privateIP(ip)
+}
+
+// checkTargetAddress refuses the connections to the private IPs, it's the webhook client's
+// dialer control, so the hostnames resolved to them and the redirects are refused too
+func checkTargetAddress(network, address string, c syscall.RawConn) error {
+	if config.ActualConfig.AllowPrivateTargets {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || privateIP(ip) {
+		return errPrivateTarget
+	}
+	return nil
+}
+
+// collectionEmails returns the emails of the collection's owner and teammates,
+// the alerts are sent only to them
+func collectionEmails(collection *Collection) (map[string]bool, error) {
+	owner, err := GetUserByID(collection.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	emails := map[string]bool{strings.ToLower(owner.Email): true}
+	teammates, err := GetCollectionTeammates(collection)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range teammates {
+		emails[strings.ToLower(t.Email)] = true
+	}
+	return emails, nil
+}
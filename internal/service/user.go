@@ -2,6 +2,7 @@ package service
 
 import (
 	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -183,7 +184,7 @@ func GetUserByName(name string) (*User, error) {
 func GetUserByID(ID uint64) (*User, error) {
 	user, err := db.GetUserByID(ID)
 	if err != nil {
-		return nil, ErrUserNotExist.T(string(ID)).Wrap(err)
+		return nil, ErrUserNotExist.T(strconv.FormatUint(ID, 10)).Wrap(err)
 	}
 	return user, nil
 }
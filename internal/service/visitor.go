@@ -0,0 +1,85 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/soyersoyer/rightana/internal/db"
+)
+
+type saltT struct {
+	loc  *time.Location
+	day  string
+	salt []byte
+}
+
+// visitorSalts holds the timezones' salts of the visitor IDs only in memory, so they
+// never get into the database or the backups. A salt is dropped at the midnight of its
+// timezone, so the IDs can't be linked across the days, a restart counts the day's
+// visitors again
+var visitorSalts struct {
+	sync.Mutex
+	salts map[string]saltT
+}
+
+// getVisitorLocation returns the timezone of the collection's days, the midnight of the sessions
+// or the server's timezone, which is the default of the daily statistics
+func getVisitorLocation(collection *Collection) *time.Location {
+	if collection.SessionMidnightTimezone != "" {
+		if loc, err := db.GetLocation(collection.SessionMidnightTimezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+func newVisitorSalt() ([]byte, error) {
+	salt := make([]byte, 32)
+	_, err := rand.Read(salt)
+	return salt, err
+}
+
+func getVisitorSalt(now time.Time, loc *time.Location) ([]byte, error) {
+	timezone := loc.String()
+	day := now.In(loc).Format("2006-01-02")
+	visitorSalts.Lock()
+	defer visitorSalts.Unlock()
+	if s, ok := visitorSalts.salts[timezone]; ok && s.day == day {
+		return s.salt, nil
+	}
+	for tz, s := range visitorSalts.salts {
+		if s.day != now.In(s.loc).Format("2006-01-02") {
+			delete(visitorSalts.salts, tz)
+		}
+	}
+	salt, err := newVisitorSalt()
+	if err != nil {
+		return nil, err
+	}
+	if visitorSalts.salts == nil {
+		visitorSalts.salts = map[string]saltT{}
+	}
+	visitorSalts.salts[timezone] = saltT{loc, day, salt}
+	return salt, nil
+}
+
+// getVisitorID returns the visitor's daily ID without cookies, it's a hash of
+// the IP, the user agent and the collection with the daily salt, 0 means unknown
+func getVisitorID(now time.Time, ip string, userAgent string, collection *Collection) uint64 {
+	salt, err := getVisitorSalt(now, getVisitorLocation(collection))
+	if err != nil {
+		log.Println("can't get the visitor salt, cause:", err)
+		return 0
+	}
+	h := sha256.New()
+	h.Write(salt)
+	for _, s := range []string{ip, userAgent, collection.ID} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
@@ -0,0 +1,487 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/soyersoyer/rightana/internal/db"
+)
+
+// WebhookT is the webhook struct for the clients
+type WebhookT struct {
+	ID       uint64   `json:"id"`
+	URL      string   `json:"url"`
+	Secret   string   `json:"secret"`
+	Events   []string `json:"events"`
+	Disabled bool     `json:"disabled"`
+}
+
+// The webhook events
+const (
+	WebhookSessionCreated = "session.created"
+	WebhookDailySummary   = "summary.daily"
+	WebhookShardDeleted   = "shard.deleted"
+)
+
+var webhookEvents = []string{WebhookSessionCreated, WebhookDailySummary, WebhookShardDeleted}
+
+// The webhook deliveries are retried with exponential backoff
+const (
+	webhookMaxAttempts = 8
+	webhookBackoff     = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+	webhookSecretLen   = 32
+	webhookQueueSize   = 1024
+	// webhookMaxQueued caps a webhook's queued deliveries, the new ones are dropped above it
+	webhookMaxQueued = 1000
+	// webhookDeliveriesPerTick bounds the deliveries of a scheduler run, the rest waits for the next one
+	webhookDeliveriesPerTick = 100
+	webhookDeliveryWorkers   = 8
+)
+
+// The webhook request headers
+const (
+	WebhookEventHeader     = "X-Rightana-Event"
+	WebhookDeliveryHeader  = "X-Rightana-Delivery"
+	WebhookSignatureHeader = "X-Rightana-Signature"
+)
+
+type webhookPayloadT struct {
+	Event        string      `json:"event"`
+	CollectionID string      `json:"collection_id"`
+	Created      int64       `json:"created"`
+	Data         interface{} `json:"data"`
+}
+
+type webhookSessionT struct {
+	SessionKey  string `json:"session_key"`
+	Hostname    string `json:"hostname"`
+	Referrer    string `json:"referrer"`
+	CountryCode string `json:"country_code"`
+	DeviceType  string `json:"device_type"`
+	BrowserName string `json:"browser_name"`
+	DeviceOS    string `json:"device_os"`
+}
+
+type webhookSummaryT struct {
+	From      int64 `json:"from"`
+	To        int64 `json:"to"`
+	Sessions  int   `json:"sessions"`
+	Pageviews int   `json:"pageviews"`
+}
+
+type webhookShardT struct {
+	ShardID string `json:"shard_id"`
+}
+
+func toWebhookT(w *db.Webhook) WebhookT {
+	events := w.Events
+	if events == nil {
+		events = []string{}
+	}
+	return WebhookT{
+		w.ID,
+		w.URL,
+		w.Secret,
+		events,
+		w.Disabled,
+	}
+}
+
+func setWebhook(w *db.Webhook, input *WebhookT) {
+	w.URL = input.URL
+	if input.Secret != "" {
+		w.Secret = input.Secret
+	}
+	w.Events = input.Events
+	w.Disabled = input.Disabled
+}
+
+func validateWebhook(w *db.Webhook) error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhook.T(w.URL)
+	}
+	if privateTarget(u) {
+		return ErrForbiddenTarget.T(w.URL)
+	}
+	if len(w.Events) == 0 {
+		return ErrInvalidWebhook.T("no events")
+	}
+	for _, e := range w.Events {
+		if !isWebhookEvent(e) {
+			return ErrInvalidWebhook.T(e)
+		}
+	}
+	return nil
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range webhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func webhookSubscribed(w *db.Webhook, event string) bool {
+	if w.Disabled {
+		return false
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// GetWebhooks returns the collection's webhooks
+func GetWebhooks(collection *Collection) ([]WebhookT, error) {
+	webhooks, err := db.GetWebhooksByCollectionID(collection.ID)
+	if err != nil {
+		return nil, ErrDB.Wrap(err, collection.ID)
+	}
+	ret := []WebhookT{}
+	for _, w := range webhooks {
+		ret = append(ret, toWebhookT(&w))
+	}
+	return ret, nil
+}
+
+// CreateWebhook creates a webhook for the collection
+func CreateWebhook(collection *Collection, input *WebhookT) (*WebhookT, error) {
+	now := time.Now().UnixNano()
+	webhook := &db.Webhook{
+		CollectionID:  collection.ID,
+		Secret:        randStringBytes(webhookSecretLen),
+		SummarySentAt: now,
+		Created:       now,
+	}
+	setWebhook(webhook, input)
+	if err := validateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	if err := db.InsertWebhook(webhook); err != nil {
+		return nil, ErrDB.Wrap(err, webhook)
+	}
+	ret := toWebhookT(webhook)
+	return &ret, nil
+}
+
+func getWebhook(collection *Collection, webhookID string) (*db.Webhook, error) {
+	ID, err := strconv.ParseUint(webhookID, 10, 64)
+	if err != nil {
+		return nil, ErrWebhookNotExist.T(webhookID).Wrap(err)
+	}
+	webhook, err := db.GetWebhook(ID)
+	if err != nil {
+		if err == db.ErrKeyNotExists {
+			return nil, ErrWebhookNotExist.T(webhookID)
+		}
+		return nil, ErrDB.Wrap(err, webhookID)
+	}
+	if webhook.CollectionID != collection.ID {
+		return nil, ErrWebhookNotExist.T(webhookID)
+	}
+	return webhook, nil
+}
+
+// UpdateWebhook updates the collection's webhook
+func UpdateWebhook(collection *Collection, webhookID string, input *WebhookT) (*WebhookT, error) {
+	webhook, err := getWebhook(collection, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	setWebhook(webhook, input)
+	if err := validateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	if err := db.UpdateWebhook(webhook); err != nil {
+		return nil, ErrDB.Wrap(err, webhook)
+	}
+	ret := toWebhookT(webhook)
+	return &ret, nil
+}
+
+// DeleteWebhook deletes the collection's webhook
+func DeleteWebhook(collection *Collection, webhookID string) error {
+	webhook, err := getWebhook(collection, webhookID)
+	if err != nil {
+		return err
+	}
+	if err := db.DeleteWebhook(webhook); err != nil {
+		return ErrDB.Wrap(err, webhook)
+	}
+	return nil
+}
+
+type firedWebhookT struct {
+	collectionID string
+	event        string
+	data         interface{}
+	fired        time.Time
+}
+
+// webhookFired passes the fired events from the request path to the queueing worker,
+// it's nil while the webhook scheduler doesn't run
+var webhookFired chan firedWebhookT
+
+// fireWebhooks hands over the event to the queueing worker, it doesn't block the caller
+func fireWebhooks(collectionID string, event string, data interface{}) {
+	if webhookFired == nil {
+		return
+	}
+	select {
+	case webhookFired <- firedWebhookT{collectionID, event, data, time.Now()}:
+	default:
+		log.Println("the webhook queue is full, dropping", event, "of", collectionID)
+	}
+}
+
+// queueFiredWebhooks queues the events for the collections' subscribed webhooks in a transaction
+func queueFiredWebhooks(fired []firedWebhookT) {
+	webhooks := map[string][]db.Webhook{}
+	deliveries := []*db.WebhookDelivery{}
+	for _, f := range fired {
+		collectionWebhooks, ok := webhooks[f.collectionID]
+		if !ok {
+			var err error
+			collectionWebhooks, err = db.GetWebhooksByCollectionID(f.collectionID)
+			if err != nil {
+				log.Println("can't get webhooks for", f.collectionID, "cause:", err)
+				continue
+			}
+			webhooks[f.collectionID] = collectionWebhooks
+		}
+		for _, w := range collectionWebhooks {
+			if !webhookSubscribed(&w, f.event) {
+				continue
+			}
+			delivery, err := newWebhookDelivery(&w, f.event, f.data, f.fired)
+			if err != nil {
+				log.Println("can't queue webhook", w.ID, "cause:", err)
+				continue
+			}
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	if err := queueWebhookDeliveries(deliveries); err != nil {
+		log.Println("can't queue webhooks, cause:", err)
+	}
+}
+
+// receiveFiredWebhooks returns the first event with the ones waiting behind it, so they're queued together
+func receiveFiredWebhooks(first firedWebhookT) []firedWebhookT {
+	fired := []firedWebhookT{first}
+	for len(fired) < webhookQueueSize {
+		select {
+		case f := <-webhookFired:
+			fired = append(fired, f)
+		default:
+			return fired
+		}
+	}
+	return fired
+}
+
+// startWebhookQueue queues the fired events in the background until StopSchedulers,
+// the events fired before the stop are queued before it returns
+func startWebhookQueue() {
+	webhookFired = make(chan firedWebhookT, webhookQueueSize)
+	schedulerWG.Add(1)
+	go func() {
+		defer schedulerWG.Done()
+		for {
+			select {
+			case f := <-webhookFired:
+				queueFiredWebhooks(receiveFiredWebhooks(f))
+			case <-schedulerQuit:
+				for {
+					select {
+					case f := <-webhookFired:
+						queueFiredWebhooks(receiveFiredWebhooks(f))
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+func newWebhookDelivery(webhook *db.Webhook, event string, data interface{}, now time.Time) (*db.WebhookDelivery, error) {
+	payload, err := json.Marshal(webhookPayloadT{
+		event,
+		webhook.CollectionID,
+		now.UnixNano(),
+		data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &db.WebhookDelivery{
+		WebhookID:    webhook.ID,
+		CollectionID: webhook.CollectionID,
+		Event:        event,
+		Payload:      payload,
+		NextAttempt:  now.UnixNano(),
+		Created:      now.UnixNano(),
+	}, nil
+}
+
+// queueWebhookDeliveries inserts the deliveries into the queue, the ones above a webhook's cap are dropped
+func queueWebhookDeliveries(deliveries []*db.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	dropped, err := db.InsertWebhookDeliveries(deliveries, webhookMaxQueued)
+	if dropped > 0 {
+		log.Println("the webhook queues are full, dropped", dropped, "deliveries")
+	}
+	return err
+}
+
+// StartWebhookScheduler delivers the queued webhooks and queues the fired events and the daily summaries
+// in the background
+func StartWebhookScheduler(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	startWebhookQueue()
+	startScheduler("webhook delivery", interval, func(now time.Time) error {
+		if err := QueueDailySummaries(now); err != nil {
+			log.Println("daily summary queueing failed:", err)
+		}
+		return DeliverWebhooks(now)
+	})
+}
+
+// QueueDailySummaries queues the previous day's summary for the subscribed webhooks
+func QueueDailySummaries(now time.Time) error {
+	webhooks, err := db.GetWebhooks()
+	if err != nil {
+		return ErrDB.Wrap(err)
+	}
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := to.AddDate(0, 0, -1)
+	for _, w := range webhooks {
+		if !webhookSubscribed(&w, WebhookDailySummary) || !time.Unix(0, w.SummarySentAt).Before(to) {
+			continue
+		}
+		summary, err := getWebhookSummary(w.CollectionID, from, to)
+		if err != nil {
+			log.Println("can't get the daily summary for", w.CollectionID, "cause:", err)
+			continue
+		}
+		delivery, err := newWebhookDelivery(&w, WebhookDailySummary, summary, now)
+		if err == nil {
+			err = queueWebhookDeliveries([]*db.WebhookDelivery{delivery})
+		}
+		if err != nil {
+			log.Println("can't queue webhook", w.ID, "cause:", err)
+			continue
+		}
+		webhook := &db.Webhook{}
+		if err := db.SetFields(w.ID, webhook, func() { webhook.SummarySentAt = now.UnixNano() }); err != nil {
+			log.Println("can't update webhook", w.ID, "cause:", err)
+		}
+	}
+	return nil
+}
+
+func getWebhookSummary(collectionID string, from, to time.Time) (*webhookSummaryT, error) {
+	sessions, err := db.CountMetric(collectionID, "sessions", map[string]string{}, from, to)
+	if err != nil {
+		return nil, err
+	}
+	pageviews, err := db.CountMetric(collectionID, "pageviews", map[string]string{}, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookSummaryT{from.UnixNano(), to.UnixNano(), sessions, pageviews}, nil
+}
+
+// DeliverWebhooks sends the earliest due deliveries from the queue concurrently, at most
+// webhookDeliveriesPerTick of them, the failed ones are rescheduled
+func DeliverWebhooks(now time.Time) error {
+	deliveries, err := db.GetDueWebhookDeliveries(now.UnixNano(), webhookDeliveriesPerTick)
+	if err != nil {
+		return ErrDB.Wrap(err)
+	}
+	due := make(chan *db.WebhookDelivery)
+	var wg sync.WaitGroup
+	for i := 0; i < webhookDeliveryWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range due {
+				if err := deliverWebhook(d, now); err != nil {
+					log.Println("can't deliver webhook", d.WebhookID, "cause:", err)
+				}
+			}
+		}()
+	}
+	for i := range deliveries {
+		due <- &deliveries[i]
+	}
+	close(due)
+	wg.Wait()
+	return nil
+}
+
+func deliverWebhook(delivery *db.WebhookDelivery, now time.Time) error {
+	webhook, err := db.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		if err == db.ErrKeyNotExists {
+			return db.DeleteWebhookDelivery(delivery)
+		}
+		return err
+	}
+	headers := map[string]string{
+		WebhookEventHeader:     delivery.Event,
+		WebhookDeliveryHeader:  strconv.FormatUint(delivery.ID, 10),
+		WebhookSignatureHeader: "sha256=" + SignWebhook(webhook.Secret, delivery.Payload),
+	}
+	err = postBody(webhook.URL, delivery.Payload, headers)
+	if err == nil {
+		return db.DeleteWebhookDelivery(delivery)
+	}
+	delivery.Attempts++
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= webhookMaxAttempts {
+		log.Println("dropping webhook delivery", delivery.ID, "after", delivery.Attempts, "attempts")
+		if err := db.DeleteWebhookDelivery(delivery); err != nil {
+			return err
+		}
+		return err
+	}
+	delivery.NextAttempt = now.Add(getWebhookBackoff(delivery.Attempts)).UnixNano()
+	if err := db.UpdateWebhookDelivery(delivery); err != nil {
+		return err
+	}
+	return err
+}
+
+func getWebhookBackoff(attempts int32) time.Duration {
+	backoff := webhookBackoff << uint(attempts-1)
+	if backoff <= 0 || backoff > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return backoff
+}
+
+// SignWebhook returns the hex encoded HMAC-SHA256 signature of the payload
+func SignWebhook(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
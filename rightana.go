@@ -25,6 +25,67 @@ var (
 	createCollectionID   = createCollection.Arg("id", "Collection's ID").Required().String()
 	createCollectionName = createCollection.Arg("name", "Collection's name").Required().String()
 	createCollectionUser = createCollection.Arg("user", "Owner's username").Required().String()
+	createCollectionGran = createCollection.Flag("shards", "Shard granularity: day, week, month or year").Default("month").String()
+	jsonOutput           = app.Flag("json", "JSON output for scripting").Bool()
+	users                = app.Command("users", "List the users")
+	collections          = app.Command("collections", "List the collections with the owners, teammates and shard sizes")
+	deleteUser           = app.Command("delete-user", "Delete a user")
+	deleteUserName       = deleteUser.Arg("name", "username for user.").Required().String()
+	deleteCollection     = app.Command("delete-collection", "Delete a collection")
+	deleteCollectionID   = deleteCollection.Arg("id", "Collection's ID").Required().String()
+	promote              = app.Command("promote", "Make a user admin")
+	promoteName          = promote.Arg("name", "username for user.").Required().String()
+	demote               = app.Command("demote", "Revoke a user's admin rights")
+	demoteName           = demote.Arg("name", "username for user.").Required().String()
+	setLimit             = app.Command("set-collection-limit", "Set a user's collection limit")
+	setLimitName         = setLimit.Arg("name", "username for user.").Required().String()
+	setLimitCount        = setLimit.Arg("limit", "Collection limit, negative removes the limit").Required().Int()
+	addTeammate          = app.Command("add-teammate", "Add a teammate to a collection")
+	addTeammateID        = addTeammate.Arg("id", "Collection's ID").Required().String()
+	addTeammateEmail     = addTeammate.Arg("email", "Teammate's email").Required().String()
+	removeTeammate       = app.Command("remove-teammate", "Remove a teammate from a collection")
+	removeTeammateID     = removeTeammate.Arg("id", "Collection's ID").Required().String()
+	removeTeammateEmail  = removeTeammate.Arg("email", "Teammate's email").Required().String()
+	transfer             = app.Command("transfer-collection", "Transfer a collection to another user")
+	transferID           = transfer.Arg("id", "Collection's ID").Required().String()
+	transferUser         = transfer.Arg("user", "New owner's username").Required().String()
+	transferRemove       = transfer.Flag("remove-previous-owner", "Don't keep the previous owner as a teammate").Bool()
+	exportCmd            = app.Command("export-collection", "Export a collection's metadata, sessions and pageviews")
+	exportID             = exportCmd.Arg("id", "Collection's ID").Required().String()
+	exportFile           = exportCmd.Arg("file", "Export file, - means stdout").Default("-").String()
+	importCmd            = app.Command("import-collection", "Import a collection export into a new or an existing collection")
+	importFile           = importCmd.Arg("file", "Export file, - means stdin").Required().String()
+	importUser           = importCmd.Arg("user", "Owner's username when a new collection is created").Required().String()
+	importID             = importCmd.Flag("collection", "Target collection's ID, defaults to the exported ID if it's free").String()
+	importName           = importCmd.Flag("name", "New collection's name, defaults to the exported name").String()
+	erase                = app.Command("erase", "Delete sessions with their pageviews for a right to erasure request")
+	eraseID              = erase.Arg("id", "Collection's ID").Required().String()
+	eraseSession         = erase.Flag("session", "Session key").String()
+	eraseIP              = erase.Flag("ip", "Delete the sessions from this IP address").String()
+	eraseIPRange         = erase.Flag("ip-range", "Delete the sessions from this CIDR range").String()
+	eraseFrom            = erase.Flag("from", "Only the sessions started after this date (YYYY-MM-DD)").String()
+	eraseTo              = erase.Flag("to", "Only the sessions started before this date (YYYY-MM-DD)").String()
+	eraseDryRun          = erase.Flag("dry-run", "Only count the matching sessions").Bool()
+	erasures             = app.Command("erasures", "List the erasure audit trail of a collection")
+	erasuresID           = erasures.Arg("id", "Collection's ID").Required().String()
+	purge                = app.Command("purge", "Delete the sessions started in a time window with their pageviews and compact the shards")
+	purgeID              = purge.Arg("id", "Collection's ID").Required().String()
+	purgeFrom            = purge.Arg("from", "Start of the window (YYYY-MM-DD or YYYY-MM-DD HH:MM)").Required().String()
+	purgeTo              = purge.Arg("to", "End of the window, exclusive (YYYY-MM-DD or YYYY-MM-DD HH:MM)").Required().String()
+	reshard              = app.Command("reshard", "Rewrite a collection's shards in a new granularity, the server must be stopped")
+	reshardID            = reshard.Arg("id", "Collection's ID").Required().String()
+	reshardGran          = reshard.Arg("granularity", "Shard granularity: day, week, month or year").Required().String()
+	rebuildIndexes       = app.Command("rebuild-indexes", "Rebuild the user and collection indexes")
+	migrate              = app.Command("migrate", "Back up the data dir and run the pending database migrations")
+	migrateDryRun        = migrate.Flag("dry-run", "Only list the pending migrations").Bool()
+	backup               = app.Command("backup", "Backup tools")
+	backupVerify         = backup.Command("verify", "Verify a backup")
+	backupVerifyDir      = backupVerify.Arg("path", "Backup directory or tar/tar.gz archive").Required().String()
+	restore              = app.Command("restore", "Restore a backup into the data dir, the server must be stopped")
+	restoreDir           = restore.Arg("path", "Backup directory or tar/tar.gz archive").Required().String()
+	restoreCollection    = restore.Flag("collection", "Restore only this collection").String()
+	restoreShard         = restore.Flag("shard", "Restore only this shard of the collection (eg 2019-06)").String()
+	restoreForce         = restore.Flag("force", "Replace the existing instance").Bool()
 )
 
 func main() {
@@ -43,7 +104,47 @@ func main() {
 	case "passwd":
 		ChangePassword(*passwdName)
 	case "create-collection":
-		CreateCollection(*createCollectionID, *createCollectionName, *createCollectionUser)
+		CreateCollection(*createCollectionID, *createCollectionName, *createCollectionGran, *createCollectionUser)
+	case "users":
+		ListUsers(*jsonOutput)
+	case "collections":
+		ListCollections(*jsonOutput)
+	case "delete-user":
+		DeleteUser(*deleteUserName, *jsonOutput)
+	case "delete-collection":
+		DeleteCollection(*deleteCollectionID, *jsonOutput)
+	case "promote":
+		SetAdmin(*promoteName, true, *jsonOutput)
+	case "demote":
+		SetAdmin(*demoteName, false, *jsonOutput)
+	case "set-collection-limit":
+		SetCollectionLimit(*setLimitName, *setLimitCount, *jsonOutput)
+	case "add-teammate":
+		AddTeammate(*addTeammateID, *addTeammateEmail, *jsonOutput)
+	case "remove-teammate":
+		RemoveTeammate(*removeTeammateID, *removeTeammateEmail, *jsonOutput)
+	case "transfer-collection":
+		TransferCollection(*transferID, *transferUser, *transferRemove, *jsonOutput)
+	case "export-collection":
+		ExportCollection(*exportID, *exportFile)
+	case "import-collection":
+		ImportCollection(*importFile, *importUser, *importID, *importName, *jsonOutput)
+	case "migrate":
+		Migrate(*migrateDryRun, *jsonOutput)
+	case "erase":
+		EraseSessions(*eraseID, *eraseSession, *eraseIP, *eraseIPRange, *eraseFrom, *eraseTo, *eraseDryRun, *jsonOutput)
+	case "erasures":
+		ListErasures(*erasuresID, *jsonOutput)
+	case "purge":
+		PurgeCollection(*purgeID, *purgeFrom, *purgeTo, *jsonOutput)
+	case "reshard":
+		ReshardCollection(*reshardID, *reshardGran, *jsonOutput)
+	case "rebuild-indexes":
+		RebuildIndexes()
+	case "backup verify":
+		VerifyBackup(*backupVerifyDir, *jsonOutput)
+	case "restore":
+		RestoreBackup(*restoreDir, *restoreCollection, *restoreShard, *restoreForce)
 	}
 
 }
@@ -25,10 +25,9 @@ export class AdminBackupsComponent implements OnInit {
 
   run(b: Backup) {
     this.backend.runBackup(b.id)
-      .subscribe(_ => this.toasty.success(`Backup (${b.id}) success`));
-  }
-
-  get origin(): string {
-    return window.location.origin;
+      .subscribe(_ => {
+        this.toasty.success(`Backup (${b.id}) success`);
+        this.getUsers();
+      });
   }
 }
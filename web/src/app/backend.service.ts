@@ -133,9 +133,27 @@ export class Shard {
   size: number;
 }
 
+export class BackupRun {
+  id: number;
+  started: number;
+  finished: number;
+  path: string;
+  size: number;
+  error: string;
+}
+
 export class Backup {
   id: string;
   dir: string;
+  interval: string;
+  at: string;
+  archive: boolean;
+  keep_last: number;
+  keep_daily: number;
+  keep_weekly: number;
+  running: boolean;
+  next_run: number;
+  last_run: BackupRun;
 }
 
 @Injectable()
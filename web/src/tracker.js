@@ -2,21 +2,34 @@
 
 window.rightana = function() {
   var sessionStorageKey = 'rightana-session-key',
+  visitsStorageKey = 'rightana-visits',
   trackerUrl = '',
   collectionId = '',
   debug = false,
+  visitCounter = false,
+  sessionProperties = null,
+  // the hiding and the unloading fire more events, one heartbeat is sent until the page is shown again
+  heartbeatSent = false,
 
-  setup = function(trackerUrl_, collectionId_, debug_) {
+  setup = function(trackerUrl_, collectionId_, debug_, options) {
     trackerUrl = trackerUrl_;
     collectionId = collectionId_;
     debug = debug_ || false;
+    visitCounter = !!(options && options.visitCounter);
   },
 
-  trackPageview = function() {
+  // setProperties sets the custom properties of the session, it must precede the first trackPageview
+  setProperties = function(properties) {
+    sessionProperties = properties;
+  },
+
+  trackPageview = function(properties) {
     if (navigator.doNotTrack === '1') {
       return;
     }
-    getSessionKey(sendPageView);
+    getSessionKey(function() {
+      sendPageView(properties);
+    });
   },
 
   getSessionKey = function(cb) {
@@ -37,6 +50,14 @@ window.rightana = function() {
       wr: window.innerWidth + 'x' + window.innerHeight,
       dt: getDeviceType(),
       r: document.referrer,
+      pr: sessionProperties,
+    }
+    if (visitCounter && navigator.doNotTrack !== '1') {
+      var visits = countVisit();
+      if (visits) {
+        d.vc = visits.count;
+        d.vd = visits.days;
+      }
     }
     postDataTo(d, '/sessions', true, function(response) {
       var key = JSON.parse(response);
@@ -48,7 +69,21 @@ window.rightana = function() {
     });
   },
 
-  sendPageView = function() {
+  // countVisit counts the visits in the localStorage, it returns the visit's number
+  // and the days since the previous visit
+  countVisit = function() {
+    try {
+      var now = Date.now(),
+        visits = JSON.parse(localStorage[visitsStorageKey] || '{"c":0,"t":0}'),
+        days = visits.c > 0 ? Math.floor((now - visits.t) / 86400000) : 0;
+      localStorage[visitsStorageKey] = JSON.stringify({c: visits.c + 1, t: now});
+      return {count: visits.c + 1, days: Math.max(days, 0)};
+    } catch (e) {
+      return null;
+    }
+  },
+
+  sendPageView = function(properties) {
     var sessionKey = sessionStorage[sessionStorageKey];
     if (!sessionKey) {
       return;
@@ -64,15 +99,21 @@ window.rightana = function() {
       c: collectionId,
       s: sessionKey,
       p: path,
+      pr: properties,
     };
-    postDataTo(d, '/pageviews', true, function() {
+    postDataTo(d, '/pageviews', true, function(response) {
+      // the server starts a new session when the old one has expired
+      var key = JSON.parse(response);
+      if (key) {
+        sessionStorage[sessionStorageKey] = key;
+      }
       if (debug) {
-        console.log('post to pageviews success', path);
+        console.log('post to pageviews success', path, key);
       }
     });
   },
 
-  updateSessionEnd = function() {
+  sendHeartbeat = function() {
     var sessionKey = sessionStorage[sessionStorageKey];
     if (!sessionKey) {
       return;
@@ -81,13 +122,25 @@ window.rightana = function() {
       c: collectionId,
       s: sessionKey,
     };
-    postDataTo(d, '/sessions/update', false, function() {
+    // the beacons are delivered even when the page is being unloaded
+    if (navigator.sendBeacon && navigator.sendBeacon(trackerUrl + '/sessions/heartbeat', JSON.stringify(d))) {
+      return;
+    }
+    postDataTo(d, '/sessions/heartbeat', false, function() {
       if (debug) {
-        console.log('session updated');
+        console.log('session heartbeat sent');
       }
     });
   },
 
+  sendHeartbeatOnce = function() {
+    if (heartbeatSent) {
+      return;
+    }
+    heartbeatSent = true;
+    sendHeartbeat();
+  },
+
   postDataTo = function(data, url, async, cb) {
     var httpRequest = new XMLHttpRequest();
 
@@ -120,6 +173,7 @@ window.rightana = function() {
 
   commands = {
     'trackPageview': trackPageview,
+    'setProperties': setProperties,
     'setup': setup,
   },
 
@@ -129,8 +183,24 @@ window.rightana = function() {
     commands[c].apply(this, args);
   };
 
+  document.addEventListener('visibilitychange', function() {
+    if (document.visibilityState === 'hidden') {
+      sendHeartbeatOnce();
+    } else {
+      heartbeatSent = false;
+    }
+  });
+
+  window.addEventListener('pagehide', function(event) {
+    sendHeartbeatOnce();
+  });
+
   window.addEventListener('beforeunload', function(event) {
-    updateSessionEnd();
+    sendHeartbeatOnce();
+  });
+
+  window.addEventListener('pageshow', function(event) {
+    heartbeatSent = false;
   });
 
   (window.rightana && window.rightana.q || []).forEach(function(i) {